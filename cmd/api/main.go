@@ -24,9 +24,13 @@ func main() {
 		log.Fatalf("could not get seed: %s", err)
 	}
 
-	engine := engine.NewEngine(cfg, seed, ctx)
+	manager := engine.NewManager(cfg, ctx)
+	_, err = manager.Create(engine.DefaultRoom, engine.RoomConfig{}, seed)
+	if err != nil {
+		log.Fatalf("could not create engine: %s", err)
+	}
 
-	s := server.NewServer(cfg, dbs, engine, ctx)
+	s := server.NewServer(cfg, dbs, manager, ctx)
 
 	errChan := make(chan error, 1)
 	go func() {
@@ -18,7 +18,7 @@ func main() {
 		}
 		defer f.Close()
 	}
-	p := tea.NewProgram(&tui.UIModel{}, tea.WithAltScreen(), tea.WithMouseCellMotion())
+	p := tea.NewProgram(&tui.UIModel{Room: os.Getenv("ROOM")}, tea.WithAltScreen(), tea.WithMouseCellMotion())
 	if _, err := p.Run(); err != nil {
 		log.Printf("Error running terminal UI: %v", err)
 		os.Exit(1)
@@ -7,6 +7,7 @@ import (
 	"math"
 	"syscall/js"
 
+	"github.com/JackWithOneEye/conwaymore/internal/conway"
 	"github.com/JackWithOneEye/conwaymore/internal/lrucache"
 	"github.com/JackWithOneEye/conwaymore/internal/protocol"
 )
@@ -18,7 +19,7 @@ type CanvasDrawer interface {
 	SetCellSize(cellSize, mouseX, mouseY int)
 	SetDimensions(height, width int)
 	SetSettings(age bool, grid bool)
-	SumCoords(coords ...uint16) uint16
+	WrapCoords(x, y int) (uint16, uint16)
 }
 
 const (
@@ -36,19 +37,22 @@ const (
 var global = js.Global()
 
 type canvasDrawer struct {
-	axisLength  uint16
 	canvas      js.Value // OffscreenCanvas
 	cellSize    int
 	cellSizeInv float64
 	ctx         js.Value // OffscreenCanvasRenderingContext2D
-	wrapMask    uint16
+	shape       conway.Grid
+	stateColour []uint32 // by cell state, only for rule tables
+	worldWidth  uint16   // in cells
+	worldHeight uint16
 
 	xOffset float64
 	yOffset float64
 
-	xBoundary coordBoundary
-	yBoundary coordBoundary
-	worldSize int
+	xBoundary     coordBoundary
+	yBoundary     coordBoundary
+	worldWidthPx  int
+	worldHeightPx int
 
 	drawMode drawMode
 	grid     bool
@@ -64,20 +68,23 @@ type canvasDrawer struct {
 	colorCache lrucache.LruCache[uint32, string]
 }
 
-func NewCanvasDrawer(canvas js.Value, axisLength, cellSize, height, width int) CanvasDrawer {
+func NewCanvasDrawer(canvas js.Value, worldWidth, worldHeight, cellSize, height, width int, shape conway.Grid, stateColour []uint32) CanvasDrawer {
 	ctx := canvas.Call("getContext", "2d", map[string]any{"alpha": false})
 
 	cd := &canvasDrawer{
-		axisLength:  uint16(axisLength),
 		canvas:      canvas,
 		cellSize:    cellSize,
 		cellSizeInv: 1 / float64(cellSize),
 		ctx:         ctx,
-		wrapMask:    uint16(axisLength) - 1,
+		shape:       shape,
+		stateColour: stateColour,
+		worldWidth:  uint16(worldWidth),
+		worldHeight: uint16(worldHeight),
 
-		xBoundary: coordBoundary{within: true},
-		yBoundary: coordBoundary{within: true},
-		worldSize: cellSize * axisLength,
+		xBoundary:     coordBoundary{within: true},
+		yBoundary:     coordBoundary{within: true},
+		worldWidthPx:  cellSize * worldWidth,
+		worldHeightPx: cellSize * worldHeight,
 
 		drawMode: drawColour,
 		grid:     true,
@@ -120,7 +127,7 @@ func (cd *canvasDrawer) Draw(cells []protocol.Cell) {
 	cd.ctx.Call("putImageData", cd.imageData, 0, 0)
 
 	// Draw grid after ImageData (so it appears on top)
-	showGrid := cd.grid && cd.cellSize >= gridMinPx
+	showGrid := cd.grid && cd.cellSize >= gridMinPx && cd.shape == conway.SquareGrid
 	if showGrid {
 		cd.ctx.Call("beginPath")
 		cd.ctx.Set("strokeStyle", "#cccccc") // Light gray grid
@@ -131,22 +138,22 @@ func (cd *canvasDrawer) Draw(cells []protocol.Cell) {
 
 func (cd *canvasDrawer) IncrementOffset(x, y float64) {
 	ox := cd.xOffset + x
-	for math.Abs(ox) >= float64(cd.worldSize) {
+	for math.Abs(ox) >= float64(cd.worldWidthPx) {
 		sgn := 1.0
 		if math.Signbit(ox) {
 			sgn = -1.0
 		}
-		ox = sgn * (math.Abs(ox) - float64(cd.worldSize))
+		ox = sgn * (math.Abs(ox) - float64(cd.worldWidthPx))
 	}
 	cd.xOffset = ox
 
 	oy := cd.yOffset + y
-	for math.Abs(oy) >= float64(cd.worldSize) {
+	for math.Abs(oy) >= float64(cd.worldHeightPx) {
 		sgn := 1.0
 		if math.Signbit(oy) {
 			sgn = -1.0
 		}
-		oy = sgn * (math.Abs(oy) - float64(cd.worldSize))
+		oy = sgn * (math.Abs(oy) - float64(cd.worldHeightPx))
 	}
 	cd.yOffset = oy
 
@@ -154,8 +161,11 @@ func (cd *canvasDrawer) IncrementOffset(x, y float64) {
 }
 
 func (cd *canvasDrawer) PixelToCellCoord(px, py int) (x, y uint16) {
-	x = uint16(math.Floor((float64(px)-cd.xOffset)*cd.cellSizeInv)) & cd.wrapMask
-	y = uint16(math.Floor((float64(py)-cd.yOffset)*cd.cellSizeInv)) & cd.wrapMask
+	y = wrapCoord(int(math.Floor((float64(py)-cd.yOffset)*cd.cellSizeInv)), cd.worldHeight)
+	if cd.shape == conway.HexagonalGrid && y&1 == 1 {
+		px -= cd.cellSize / 2
+	}
+	x = wrapCoord(int(math.Floor((float64(px)-cd.xOffset)*cd.cellSizeInv)), cd.worldWidth)
 	return
 }
 
@@ -180,7 +190,8 @@ func (cd *canvasDrawer) SetCellSize(cellSize, mouseX, mouseY int) {
 	// Update cell size related properties
 	cd.cellSize = cellSize
 	cd.cellSizeInv = 1.0 / float64(cellSize)
-	cd.worldSize = cellSize * int(cd.axisLength)
+	cd.worldWidthPx = cellSize * int(cd.worldWidth)
+	cd.worldHeightPx = cellSize * int(cd.worldHeight)
 
 	// Use IncrementOffset to adjust the offsets
 	cd.IncrementOffset(newXOffset, newYOffset)
@@ -211,30 +222,24 @@ func (cd *canvasDrawer) SetSettings(age bool, drawGrid bool) {
 	cd.grid = drawGrid
 }
 
-func (cd *canvasDrawer) SumCoords(coords ...uint16) uint16 {
-	res := coords[0] & cd.wrapMask
-	for i := 1; i < len(coords); i++ {
-		res += coords[i]
-		res &= cd.wrapMask
-	}
-
-	return res
+func (cd *canvasDrawer) WrapCoords(x, y int) (uint16, uint16) {
+	return wrapCoord(x, cd.worldWidth), wrapCoord(y, cd.worldHeight)
 }
 
 func (cd *canvasDrawer) calcVisibleCoordinates() {
 	cd.xBoundary.calc(
 		cd.canvasWidth,
-		cd.worldSize,
+		cd.worldWidthPx,
 		cd.xOffset,
 		cd.cellSizeInv,
-		cd.axisLength,
+		cd.worldWidth,
 	)
 	cd.yBoundary.calc(
 		cd.canvasHeight,
-		cd.worldSize,
+		cd.worldHeightPx,
 		cd.yOffset,
 		cd.cellSizeInv,
-		cd.axisLength,
+		cd.worldHeight,
 	)
 }
 
@@ -261,67 +266,88 @@ func (cd *canvasDrawer) drawCellToBuffer(cell *protocol.Cell) {
 	// Convert cell coordinates to pixel coordinates
 	pxStartX := int(cell.X)*cd.cellSize + int(cd.xOffset)
 	pxStartY := int(cell.Y)*cd.cellSize + int(cd.yOffset)
+	if cd.shape == conway.HexagonalGrid && cell.Y&1 == 1 {
+		// Odd rows of a hexagonal grid are shifted right by half a cell
+		pxStartX += cd.cellSize / 2
+	}
 
 	// Handle X wrapping - may need to draw in 1 or 2 locations
+	// (local is the offset of the drawn part within the cell)
 	type xPosition struct {
-		start, width int
+		start, width, local int
 	}
 	xPositions := []xPosition{}
 
 	if pxStartX < 0 && pxStartX+cd.cellSize > 0 {
 		// Cell straddles left edge
-		xPositions = append(xPositions, xPosition{0, pxStartX + cd.cellSize})
-		xPositions = append(xPositions, xPosition{cd.worldSize + pxStartX, -pxStartX})
+		xPositions = append(xPositions, xPosition{0, pxStartX + cd.cellSize, -pxStartX})
+		xPositions = append(xPositions, xPosition{cd.worldWidthPx + pxStartX, -pxStartX, 0})
 	} else if pxStartX < 0 {
 		// Cell is completely off left edge, wrap to right
-		xPositions = append(xPositions, xPosition{cd.worldSize + pxStartX, cd.cellSize})
-	} else if pxStartX >= cd.worldSize {
+		xPositions = append(xPositions, xPosition{cd.worldWidthPx + pxStartX, cd.cellSize, 0})
+	} else if pxStartX >= cd.worldWidthPx {
 		// Cell is completely off right edge, wrap to left
-		xPositions = append(xPositions, xPosition{pxStartX - cd.worldSize, cd.cellSize})
-	} else if pxStartX+cd.cellSize > cd.worldSize {
+		xPositions = append(xPositions, xPosition{pxStartX - cd.worldWidthPx, cd.cellSize, 0})
+	} else if pxStartX+cd.cellSize > cd.worldWidthPx {
 		// Cell straddles right edge
-		rightWidth := cd.worldSize - pxStartX
-		xPositions = append(xPositions, xPosition{pxStartX, rightWidth})
-		xPositions = append(xPositions, xPosition{0, cd.cellSize - rightWidth})
+		rightWidth := cd.worldWidthPx - pxStartX
+		xPositions = append(xPositions, xPosition{pxStartX, rightWidth, 0})
+		xPositions = append(xPositions, xPosition{0, cd.cellSize - rightWidth, rightWidth})
 	} else {
 		// Cell is completely visible, no wrapping needed
-		xPositions = append(xPositions, xPosition{pxStartX, cd.cellSize})
+		xPositions = append(xPositions, xPosition{pxStartX, cd.cellSize, 0})
 	}
 
 	type yPosition struct {
-		start, height int
+		start, height, local int
 	}
 	// Handle Y wrapping - may need to draw in 1 or 2 locations
 	yPositions := []yPosition{}
 
 	if pxStartY < 0 && pxStartY+cd.cellSize > 0 {
 		// Cell straddles top edge
-		yPositions = append(yPositions, yPosition{0, pxStartY + cd.cellSize})
-		yPositions = append(yPositions, yPosition{cd.worldSize + pxStartY, -pxStartY})
+		yPositions = append(yPositions, yPosition{0, pxStartY + cd.cellSize, -pxStartY})
+		yPositions = append(yPositions, yPosition{cd.worldHeightPx + pxStartY, -pxStartY, 0})
 	} else if pxStartY < 0 {
 		// Cell is completely off top edge, wrap to bottom
-		yPositions = append(yPositions, yPosition{cd.worldSize + pxStartY, cd.cellSize})
-	} else if pxStartY >= cd.worldSize {
+		yPositions = append(yPositions, yPosition{cd.worldHeightPx + pxStartY, cd.cellSize, 0})
+	} else if pxStartY >= cd.worldHeightPx {
 		// Cell is completely off bottom edge, wrap to top
-		yPositions = append(yPositions, yPosition{pxStartY - cd.worldSize, cd.cellSize})
-	} else if pxStartY+cd.cellSize > cd.worldSize {
+		yPositions = append(yPositions, yPosition{pxStartY - cd.worldHeightPx, cd.cellSize, 0})
+	} else if pxStartY+cd.cellSize > cd.worldHeightPx {
 		// Cell straddles bottom edge
-		bottomHeight := cd.worldSize - pxStartY
-		yPositions = append(yPositions, yPosition{pxStartY, bottomHeight})
-		yPositions = append(yPositions, yPosition{0, cd.cellSize - bottomHeight})
+		bottomHeight := cd.worldHeightPx - pxStartY
+		yPositions = append(yPositions, yPosition{pxStartY, bottomHeight, 0})
+		yPositions = append(yPositions, yPosition{0, cd.cellSize - bottomHeight, bottomHeight})
 	} else {
 		// Cell is completely visible, no wrapping needed
-		yPositions = append(yPositions, yPosition{pxStartY, cd.cellSize})
+		yPositions = append(yPositions, yPosition{pxStartY, cd.cellSize, 0})
 	}
 
-	r := (cell.Colour >> 16) & 0xff
-	g := (cell.Colour >> 8) & 0xff
-	b := cell.Colour & 0xff
+	r, g, b := fadeColour(cell.Colour, cell.State)
+	if len(cd.stateColour) > 0 {
+		// cells of rule tables are coloured by their state
+		r, g, b = fadeColour(cd.stateColour[min(int(cell.State), len(cd.stateColour)-1)], 0)
+	}
 
 	// Draw all combinations of X and Y positions
 	for _, xPos := range xPositions {
 		for _, yPos := range yPositions {
-			cd.fillRect(xPos.start, yPos.start, xPos.width, yPos.height, r, g, b)
+			if cd.shape != conway.TriangularGrid {
+				cd.fillRect(xPos.start, yPos.start, xPos.width, yPos.height, r, g, b)
+				continue
+			}
+
+			// Triangles point up on even and down on odd cells
+			up := (cell.X+cell.Y)&1 == 0
+			for row := range yPos.height {
+				spanStart, spanWidth := triangleSpan(yPos.local+row, cd.cellSize, up)
+				from := max(spanStart, xPos.local)
+				to := min(spanStart+spanWidth, xPos.local+xPos.width)
+				if from < to {
+					cd.fillRect(xPos.start+from-xPos.local, yPos.start+row, to-from, 1, r, g, b)
+				}
+			}
 		}
 	}
 }
@@ -353,8 +379,8 @@ func (cd *canvasDrawer) drawGrid() {
 		return
 	}
 
-	height := float64(min(cd.canvasHeight, cd.worldSize))
-	width := float64(min(cd.canvasWidth, cd.worldSize))
+	height := float64(min(cd.canvasHeight, cd.worldHeightPx))
+	width := float64(min(cd.canvasWidth, cd.worldWidthPx))
 
 	// Fix math.Remainder potentially returning negative values
 	xRem := math.Mod(cd.xOffset, float64(cd.cellSize))
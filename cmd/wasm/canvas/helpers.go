@@ -32,6 +32,41 @@ func (b *coordBoundary) calc(visiblePx, actualPx int, offsetPx, cellSizeInv floa
 	}
 }
 
+// wrapCoord maps a cell coordinate that may lie beyond the edges of the world back into it
+func wrapCoord(v int, axisLen uint16) uint16 {
+	v %= int(axisLen)
+	if v < 0 {
+		v += int(axisLen)
+	}
+	return uint16(v)
+}
+
+// fadeColour blends the colour of a decaying cell towards the white background
+func fadeColour(colour uint32, stage uint8) (r, g, b uint32) {
+	r = (colour >> 16) & 0xff
+	g = (colour >> 8) & 0xff
+	b = colour & 0xff
+	if stage == 0 {
+		return
+	}
+	d := uint32(stage) + 1
+	r = 0xff - (0xff-r)/d
+	g = 0xff - (0xff-g)/d
+	b = 0xff - (0xff-b)/d
+	return
+}
+
+// triangleSpan returns the horizontal span of a triangle inscribed in a cell for the given row
+func triangleSpan(row, cellSize int, up bool) (start, width int) {
+	if up {
+		width = row + 1
+	} else {
+		width = cellSize - row
+	}
+	start = (cellSize - width) / 2
+	return
+}
+
 func absInt(v int) (int, int) {
 	if v < 0 {
 		return -1, -v
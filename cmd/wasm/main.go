@@ -8,9 +8,12 @@ import (
 	"fmt"
 	"log"
 	"math"
+	"net/url"
+	"strings"
 	"syscall/js"
 
 	"github.com/JackWithOneEye/conwaymore/cmd/wasm/canvas"
+	"github.com/JackWithOneEye/conwaymore/internal/conway"
 	"github.com/JackWithOneEye/conwaymore/internal/patterns"
 	"github.com/JackWithOneEye/conwaymore/internal/protocol"
 	"github.com/coder/websocket"
@@ -20,6 +23,7 @@ var (
 	initialised = false
 
 	cellsCache []protocol.Cell = nil
+	sequence   uint32          // of the last output drawn, 0 before the first one
 
 	ctx  = context.Background()
 	conn *websocket.Conn
@@ -55,6 +59,9 @@ const (
 	msgSetPattern
 	msgSetSpeed
 	msgSettingsChange
+	msgSetTopology
+	msgRandomise
+	msgKillCells
 )
 
 func main() {
@@ -68,7 +75,7 @@ func main() {
 
 	var err error
 
-	conn, _, err = websocket.Dial(ctx, "/play", &websocket.DialOptions{})
+	conn, _, err = websocket.Dial(ctx, playPath(global)+"?stats=1", &websocket.DialOptions{})
 	if err != nil {
 		log.Fatalf("websocket dial failed: %s", err)
 	}
@@ -80,14 +87,39 @@ func main() {
 
 	for {
 		var o protocol.Output
-		_, b, err := conn.Read(ctx)
+		typ, b, err := conn.Read(ctx)
 		if err != nil {
 			log.Fatalf("could not read from websocket: %s", err)
 		}
+		if typ == websocket.MessageText {
+			// the stats of the generation or the progress of a fast-forward as JSON
+			msg := global.Get("JSON").Call("parse", string(b))
+			if ff := msg.Get("fast_forward"); ff.Truthy() {
+				global.Call("postMessage", []any{
+					map[string]any{
+						"type":     6,
+						"progress": ff,
+					},
+				})
+				continue
+			}
+			global.Call("postMessage", []any{
+				map[string]any{
+					"type":  5,
+					"stats": msg,
+				},
+			})
+			continue
+		}
 		err = o.Decode(b)
 		if err != nil {
 			log.Fatalf("could not decode data: %s", err)
 		}
+		if sequence != 0 && !o.After(sequence) {
+			// an older output that arrived late
+			continue
+		}
+		sequence = o.Sequence
 
 		global.Call(
 			"postMessage",
@@ -100,6 +132,24 @@ func main() {
 					"type":  2,
 					"speed": o.Speed,
 				},
+				map[string]any{
+					"type":     3,
+					"topology": o.Topology,
+				},
+				map[string]any{
+					"type":       4,
+					"period":     o.Period,
+					"cellsCount": o.CellsCount,
+				},
+				map[string]any{
+					"type": 7,
+					"rate": o.GenerationsPerSecond,
+				},
+				map[string]any{
+					"type":       8,
+					"generation": o.Generation,
+					"rule":       o.Rule,
+				},
 			},
 		)
 		cellsCache = o.Cells
@@ -107,6 +157,15 @@ func main() {
 	}
 }
 
+// playPath returns the path of the websocket of the room the worker was started for.
+func playPath(global js.Value) string {
+	query, err := url.ParseQuery(strings.TrimPrefix(global.Get("location").Get("search").String(), "?"))
+	if err != nil || query.Get("room") == "" {
+		return "/play"
+	}
+	return "/play/" + url.PathEscape(query.Get("room"))
+}
+
 func draw() {
 	if !drawHandle.IsNull() {
 		cancelAnimationFrame.Invoke(drawHandle)
@@ -130,8 +189,11 @@ func handleCellSizeChange(data js.Value) {
 }
 
 func handleCommand(data js.Value) js.Value {
-	cmd := data.Get("cmd").Int()
-	err := sendClientMessage(&protocol.Command{Cmd: protocol.CommandType(cmd)})
+	c := &protocol.Command{Cmd: protocol.CommandType(data.Get("cmd").Int())}
+	if arg := data.Get("arg"); arg.Truthy() {
+		c.Arg = uint64(arg.Float())
+	}
+	err := sendClientMessage(c)
 	if err != nil {
 		return makeError(fmt.Sprintf("command write failed: %s", err)).Value
 	}
@@ -142,17 +204,80 @@ func handleInit(data js.Value) js.Value {
 	if initialised {
 		return makeError("already initialised").Value
 	}
+	var stateColours []uint32
+	if sc := data.Get("stateColours"); sc.Truthy() {
+		stateColours = make([]uint32, sc.Length())
+		for i := range stateColours {
+			stateColours[i] = uint32(sc.Index(i).Int())
+		}
+	}
 	drawer = canvas.NewCanvasDrawer(
 		data.Get("canvas"),
-		data.Get("worldSize").Int(),
+		data.Get("worldWidth").Int(),
+		data.Get("worldHeight").Int(),
 		int(scaleCellSize(data.Get("cellSize").Float())),
 		data.Get("height").Int(),
 		data.Get("width").Int(),
+		conway.Grid(data.Get("grid").Int()),
+		stateColours,
 	)
 	initialised = true
 	return js.Undefined()
 }
 
+func handleRandomise(data js.Value) js.Value {
+	s := &protocol.Soup{
+		Seed:     conway.SeedFromString(data.Get("seed").String()),
+		Density:  uint8(data.Get("density").Int()),
+		Symmetry: uint8(data.Get("symmetry").Int()),
+		X:        uint16(data.Get("x").Int()),
+		Y:        uint16(data.Get("y").Int()),
+		Width:    uint16(data.Get("width").Int()),
+		Height:   uint16(data.Get("height").Int()),
+	}
+	if palette := data.Get("palette"); palette.Truthy() {
+		s.Palette = make([]uint32, palette.Length())
+		for i := range s.Palette {
+			s.Palette[i] = uint32(palette.Index(i).Int())
+		}
+	}
+	err := sendClientMessage(s)
+	if err != nil {
+		return makeError(fmt.Sprintf("randomise write failed: %s", err)).Value
+	}
+	return js.Undefined()
+}
+
+func handleKillCells(data js.Value) js.Value {
+	count := data.Get("count").Int()
+	originPx := data.Get("originPx").Int()
+	originPy := data.Get("originPy").Int()
+	cs := make([]byte, count*4)
+	cl := js.CopyBytesToGo(cs, data.Get("coordinates"))
+	originCx, originCy := drawer.PixelToCellCoord(originPx, originPy)
+
+	if cl != len(cs) {
+		log.Printf("killCells: byte length (%d) does not match cells count (%d)", cl, len(cs))
+		return makeError("killCells: byte length does not match cells count").Value
+	}
+	kc := &protocol.KillCells{
+		Count:  uint16(count),
+		Coords: make([]protocol.Coord, count),
+	}
+	for i := range kc.Coords {
+		x, y := drawer.WrapCoords(
+			int(originCx)+int(uint16(cs[4*i])<<8|uint16(cs[4*i+1])),
+			int(originCy)+int(uint16(cs[4*i+2])<<8|uint16(cs[4*i+3])),
+		)
+		kc.Coords[i] = protocol.Coord{X: x, Y: y}
+	}
+	err := sendClientMessage(kc)
+	if err != nil {
+		return makeError(fmt.Sprintf("killCells write failed: %s", err)).Value
+	}
+	return js.Undefined()
+}
+
 func handleResize(data js.Value) {
 	drawer.SetDimensions(
 		data.Get("height").Int(),
@@ -163,6 +288,7 @@ func handleResize(data js.Value) {
 func handleSetCells(data js.Value) js.Value {
 	count := data.Get("count").Int()
 	colour := uint32(data.Get("colour").Int())
+	state := uint8(data.Get("state").Int())
 	originPx := data.Get("originPx").Int()
 	originPy := data.Get("originPy").Int()
 	cs := make([]byte, count*4)
@@ -179,10 +305,15 @@ func handleSetCells(data js.Value) js.Value {
 	}
 	var sci uint
 	for i := 0; i < len(cs); i += 4 {
+		x, y := drawer.WrapCoords(
+			int(originCx)+int((uint16(cs[i])<<8)&0xff00|uint16(cs[i+1])&0xff),
+			int(originCy)+int((uint16(cs[i+2])<<8)&0xff00|uint16(cs[i+3])&0xff),
+		)
 		sc.Cells[sci] = protocol.Cell{
-			X:      originCx + ((uint16(cs[i])<<8)&0xff00 | uint16(cs[i+1])&0xff),
-			Y:      originCy + ((uint16(cs[i+2])<<8)&0xff00 | uint16(cs[i+3])&0xff),
+			X:      x,
+			Y:      y,
 			Colour: colour,
+			State:  state,
 		}
 		sci += 1
 	}
@@ -195,6 +326,7 @@ func handleSetCells(data js.Value) js.Value {
 
 func handleSetPattern(data js.Value) js.Value {
 	colour := uint32(data.Get("colour").Int())
+	state := uint8(data.Get("state").Int())
 	originPx := data.Get("originPx").Int()
 	originPy := data.Get("originPy").Int()
 	patternType := data.Get("patternType").String()
@@ -209,12 +341,18 @@ func handleSetPattern(data js.Value) js.Value {
 	sc := &protocol.SetCells{
 		Count: uint16(count),
 		Cells: make([]protocol.Cell, count),
+		Mode:  protocol.PasteMode(data.Get("pasteMode").Int()),
 	}
 	for i, c := range pattern.Cells {
+		x, y := drawer.WrapCoords(
+			int(originCx)-int(pattern.CenterX)+int(c.X),
+			int(originCy)-int(pattern.CenterY)+int(c.Y),
+		)
 		sc.Cells[i] = protocol.Cell{
-			X:      drawer.SumCoords(originCx-pattern.CenterX, c.X),
-			Y:      drawer.SumCoords(originCy-pattern.CenterY, c.Y),
+			X:      x,
+			Y:      y,
 			Colour: colour,
+			State:  state,
 		}
 	}
 	err := sendClientMessage(sc)
@@ -235,6 +373,17 @@ func handleSetSpeed(data js.Value) js.Value {
 	return js.Undefined()
 }
 
+func handleSetTopology(data js.Value) js.Value {
+	st := &protocol.SetTopology{
+		Topology: uint8(data.Get("topology").Int()),
+	}
+	err := sendClientMessage(st)
+	if err != nil {
+		return makeError(fmt.Sprintf("setTopology write failed: %s", err)).Value
+	}
+	return js.Undefined()
+}
+
 func handleSettingsChange(data js.Value) {
 	drawer.SetSettings(data.Get("drawAge").Bool(), data.Get("drawGrid").Bool())
 }
@@ -262,6 +411,12 @@ func onMessage(msgEvt js.Value) js.Value {
 		return handleSetSpeed(data)
 	case msgSettingsChange:
 		handleSettingsChange(data)
+	case msgSetTopology:
+		return handleSetTopology(data)
+	case msgRandomise:
+		return handleRandomise(data)
+	case msgKillCells:
+		return handleKillCells(data)
 	default:
 		log.Printf("unknown message type: %v", data)
 		return makeError(fmt.Sprintf("unknown message type: %v", data)).Value
@@ -26,6 +26,50 @@ export function showError(message) {
   }, 5000);
 }
 
+/**
+ * Describe the period of the world for the status bar
+ * @param {number} period - 0 until the world repeats, 1 if it is static
+ * @param {number} cellsCount
+ * @returns {string}
+ */
+export function periodStatus(period, cellsCount) {
+  if (period === 0) {
+    return '';
+  }
+  if (cellsCount === 0) {
+    return 'dead';
+  }
+  if (period === 1) {
+    return 'static';
+  }
+  return `period ${period}`;
+}
+
+/**
+ * Draw the population of the last generations as a line, scaled to the highest one
+ * @param {HTMLCanvasElement} canvas
+ * @param {number[]} populations - one per pixel column, the oldest first
+ */
+export function drawPopulationGraph(canvas, populations) {
+  const ctx = canvas.getContext('2d');
+  if (!ctx) {
+    return;
+  }
+  ctx.clearRect(0, 0, canvas.width, canvas.height);
+  const highest = Math.max(1, ...populations);
+  ctx.beginPath();
+  ctx.strokeStyle = '#d1d5db';
+  populations.forEach((p, x) => {
+    const y = canvas.height - 1 - (p / highest) * (canvas.height - 2);
+    if (x === 0) {
+      ctx.moveTo(x, y);
+    } else {
+      ctx.lineTo(x, y);
+    }
+  });
+  ctx.stroke();
+}
+
 /**
  * @typedef {Partial<{
  *   cellSize: number,
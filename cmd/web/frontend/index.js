@@ -1,6 +1,6 @@
 /** @import { CanvasDragState, Globals, PatternDragState } from './types/ui' */
 /** @import { CanvasWorkerEvent, CanvasWorkerInitMessage, CanvasWorkerMessage } from './types/worker' */
-import { getElementByIdOrDie, showError, UserPreferences } from './helpers';
+import { drawPopulationGraph, getElementByIdOrDie, periodStatus, showError, UserPreferences } from './helpers';
 import { getPatterns } from './patterns';
 import { computed, effect, reactive, signal } from './signals';
 import { CanvasWorkerEventType, CanvasWorkerMessageType, Command } from './types/enums';
@@ -24,10 +24,12 @@ try {
 }
 
 /** @type {Globals} */
-const { WorldSize } = globals;
+const { Grid, Room, Rule, StateColours, WorldHeight, WorldWidth } = globals;
 const Patterns = getPatterns();
 
-const canvasWorker = new Worker('/assets/js/worker.js', { type: 'module' });
+// the worker connects to the room it is given, or to the default room
+const workerUrl = Room ? `/assets/js/worker.js?room=${encodeURIComponent(Room)}` : '/assets/js/worker.js';
+const canvasWorker = new Worker(workerUrl, { type: 'module' });
 
 /**
  * Send a message to the canvas worker with error handling
@@ -50,15 +52,40 @@ const App = {
     patternMenu: getElementByIdOrDie('pattern-menu'),
     patternMenuToggle: getElementByIdOrDie('pattern-menu-toggle'),
     patternDragContainer: getElementByIdOrDie('pattern-drag-container'),
+    pasteMode: /** @type {HTMLSelectElement} */ (getElementByIdOrDie('paste-mode')),
 
     clear: /** @type {HTMLButtonElement} */ (getElementByIdOrDie('clear')),
     next: /** @type {HTMLButtonElement} */ (getElementByIdOrDie('next')),
+    previous: /** @type {HTMLButtonElement} */ (getElementByIdOrDie('previous')),
+    jump: /** @type {HTMLButtonElement} */ (getElementByIdOrDie('jump')),
+    jumpGenerations: /** @type {HTMLInputElement} */ (getElementByIdOrDie('jump-generations')),
     playPause: /** @type {HTMLButtonElement} */ (getElementByIdOrDie('play-pause')),
     save: /** @type {HTMLButtonElement} */ (getElementByIdOrDie('save-game')),
     random: /** @type {HTMLButtonElement} */ (getElementByIdOrDie('random')),
+    undo: /** @type {HTMLButtonElement} */ (getElementByIdOrDie('undo')),
+    redo: /** @type {HTMLButtonElement} */ (getElementByIdOrDie('redo')),
+    soupSeed: /** @type {HTMLInputElement} */ (getElementByIdOrDie('soup-seed')),
+    soupDensity: /** @type {HTMLInputElement} */ (getElementByIdOrDie('soup-density')),
+    soupSymmetry: /** @type {HTMLSelectElement} */ (getElementByIdOrDie('soup-symmetry')),
+    soupOwnColour: /** @type {HTMLInputElement} */ (getElementByIdOrDie('soup-own-colour')),
+    soupX: /** @type {HTMLInputElement} */ (getElementByIdOrDie('soup-x')),
+    soupY: /** @type {HTMLInputElement} */ (getElementByIdOrDie('soup-y')),
+    soupWidth: /** @type {HTMLInputElement} */ (getElementByIdOrDie('soup-width')),
+    soupHeight: /** @type {HTMLInputElement} */ (getElementByIdOrDie('soup-height')),
 
     cellColour: /** @type {HTMLInputElement} */ (getElementByIdOrDie('cell-colour')),
     randomColour: /** @type {HTMLButtonElement} */ (getElementByIdOrDie('random-colour')),
+    cellState: /** @type {HTMLSelectElement} */ (getElementByIdOrDie('cell-state')),
+    cellStateContainer: getElementByIdOrDie('cell-state-container'),
+    eraser: /** @type {HTMLInputElement} */ (getElementByIdOrDie('eraser')),
+
+    rule: getElementByIdOrDie('rule'),
+    generation: getElementByIdOrDie('generation'),
+    period: getElementByIdOrDie('period'),
+    rate: getElementByIdOrDie('rate'),
+    population: getElementByIdOrDie('population'),
+    populationGraph: /** @type {HTMLCanvasElement} */ (getElementByIdOrDie('population-graph')),
+    topology: /** @type {HTMLSelectElement} */ (getElementByIdOrDie('topology')),
 
     cellSize: /** @type {HTMLInputElement} */ (getElementByIdOrDie('cell-size')),
     cellSizeLabel: getElementByIdOrDie('cell-size-label'),
@@ -91,23 +118,103 @@ const App = {
       App.speed.state.update(prefs.speed);
     }
 
+    App.$.rule.textContent = Rule;
+    if (StateColours?.length) {
+      // rule tables colour cells by state, so the state to paint is picked instead
+      StateColours.forEach((colour, state) => {
+        const option = document.createElement('option');
+        option.value = String(state);
+        option.textContent = `State ${state + 1}`;
+        option.style.backgroundColor = `#${colour.toString(16).padStart(6, '0')}`;
+        App.$.cellState.append(option);
+      });
+      App.$.cellStateContainer.classList.replace('hidden', 'flex');
+    }
     App.$.save.removeAttribute('disabled');
     App.$.clear.addEventListener('click', () => canvasWorkerMessage({
       type: CanvasWorkerMessageType.Command,
       cmd: Command.Clear
     }));
+    App.$.undo.addEventListener('click', () => canvasWorkerMessage({
+      type: CanvasWorkerMessageType.Command,
+      cmd: Command.Undo
+    }));
+    App.$.redo.addEventListener('click', () => canvasWorkerMessage({
+      type: CanvasWorkerMessageType.Command,
+      cmd: Command.Redo
+    }));
+    document.addEventListener('keydown', (e) => {
+      if (!(e.ctrlKey || e.metaKey) || e.target instanceof HTMLInputElement) {
+        return;
+      }
+      const key = e.key.toLowerCase();
+      if (key === 'z' || key === 'y') {
+        e.preventDefault();
+        // the own edits of this client are undone and redone
+        canvasWorkerMessage({
+          type: CanvasWorkerMessageType.Command,
+          cmd: key === 'y' || e.shiftKey ? Command.Redo : Command.Undo
+        });
+      }
+    });
     App.$.next.addEventListener('click', () => canvasWorkerMessage({
       type: CanvasWorkerMessageType.Command,
       cmd: Command.Next
     }));
-    App.$.playPause.addEventListener('click', () => canvasWorkerMessage({
+    App.$.previous.addEventListener('click', () => canvasWorkerMessage({
       type: CanvasWorkerMessageType.Command,
-      cmd: App.playback.state() ? Command.Pause : Command.Play
+      cmd: Command.Previous
     }));
-    App.$.random.addEventListener('click', () => canvasWorkerMessage({
+    App.$.jump.addEventListener('click', () => {
+      if (App.fastForward.state()) {
+        canvasWorkerMessage({ type: CanvasWorkerMessageType.Command, cmd: Command.CancelFastForward });
+        return;
+      }
+      const generations = Math.floor(Number(App.$.jumpGenerations.value));
+      if (!(generations >= 1 && generations <= Number.MAX_SAFE_INTEGER)) {
+        showError(`Number of generations must be between 1 and ${Number.MAX_SAFE_INTEGER}`);
+        return;
+      }
+      // computed without an output for each generation, reporting the progress meanwhile
+      canvasWorkerMessage({ type: CanvasWorkerMessageType.Command, cmd: Command.StepN, arg: generations });
+    });
+    App.$.playPause.addEventListener('click', () => canvasWorkerMessage({
       type: CanvasWorkerMessageType.Command,
-      cmd: Command.Randomise
+      cmd: App.playback.state() ? Command.Pause : Command.Play
     }));
+    App.$.random.addEventListener('click', () => {
+      const density = Math.floor(Number(App.$.soupDensity.value));
+      if (!(density >= 1 && density <= 100)) {
+        showError('Density must be between 1 and 100');
+        return;
+      }
+      // empty fields cover the whole world
+      const [x, y, width, height] = [App.$.soupX, App.$.soupY, App.$.soupWidth, App.$.soupHeight]
+        .map((input) => Math.floor(Number(input.value)));
+      if (!(x >= 0 && x < WorldWidth && y >= 0 && y < WorldHeight)) {
+        showError(`The soup must start within the world of ${WorldWidth}x${WorldHeight} cells`);
+        return;
+      }
+      if (!(width >= 0 && width <= WorldWidth && height >= 0 && height <= WorldHeight)) {
+        showError(`The soup cannot be larger than the world of ${WorldWidth}x${WorldHeight} cells`);
+        return;
+      }
+      // the seed is shown, so that the soup can be shared and played again
+      if (!App.$.soupSeed.value) {
+        App.$.soupSeed.value = Math.random().toString(36).slice(2, 10);
+      }
+      canvasWorkerMessage({
+        type: CanvasWorkerMessageType.Randomise,
+        seed: App.$.soupSeed.value,
+        density,
+        symmetry: Number(App.$.soupSymmetry.value),
+        palette: App.$.soupOwnColour.checked ? [App.cellColour.state()] : [],
+        x,
+        y,
+        width,
+        height,
+      });
+    });
 
     let patternMenuOpen = false;
     App.$.patternMenuToggle.addEventListener('click', () => {
@@ -181,6 +288,10 @@ const App = {
       canvasWorkerMessage({ type: CanvasWorkerMessageType.SetSpeed, speed });
     });
 
+    App.$.topology.addEventListener('change', () => {
+      canvasWorkerMessage({ type: CanvasWorkerMessageType.SetTopology, topology: Number(App.$.topology.value) });
+    });
+
     document.addEventListener('mouseup', () => {
       App.moveCanvas.state.mouseState = 'idle';
     });
@@ -265,6 +376,8 @@ const App = {
         type: CanvasWorkerMessageType.SetPattern,
         patternType,
         colour: App.cellColour.state(),
+        state: Number(App.$.cellState.value) || 0,
+        pasteMode: Number(App.$.pasteMode.value),
         originPx: e.offsetX,
         originPy: e.offsetY
       });
@@ -314,6 +427,27 @@ const App = {
           case CanvasWorkerEventType.SpeedChanged:
             App.speed.state.update(ev.speed);
             break;
+          case CanvasWorkerEventType.TopologyChanged:
+            App.$.topology.value = String(ev.topology);
+            break;
+          case CanvasWorkerEventType.PeriodChanged:
+            App.$.period.textContent = periodStatus(ev.period, ev.cellsCount);
+            break;
+          case CanvasWorkerEventType.StatsChanged:
+            App.population.update(ev.stats);
+            break;
+          case CanvasWorkerEventType.RateChanged:
+            App.$.rate.textContent = `${ev.rate} gen/s`;
+            break;
+          case CanvasWorkerEventType.GenerationChanged:
+            App.$.generation.textContent = `Gen ${ev.generation}`;
+            if (ev.rule) {
+              App.$.rule.textContent = ev.rule;
+            }
+            break;
+          case CanvasWorkerEventType.FastForwardProgress:
+            App.fastForward.state.update(ev.progress.done ? null : ev.progress);
+            break;
           default:
             console.error('unknown worker event type', ev);
         }
@@ -327,7 +461,10 @@ const App = {
       cellSize: Number(App.$.cellSize.value),
       height: App.$.canvasWrapper.offsetHeight,
       width: App.$.canvasWrapper.offsetWidth,
-      worldSize: WorldSize
+      grid: Grid,
+      stateColours: StateColours,
+      worldHeight: WorldHeight,
+      worldWidth: WorldWidth
     }), [osCanvas]);
 
     effect(() => {
@@ -352,13 +489,29 @@ const App = {
       }
     });
 
+    effect(() => {
+      const progress = App.fastForward.state();
+      if (progress) {
+        const percent = Math.floor(100 * progress.generation / progress.target);
+        App.$.jump.textContent = `CANCEL ${percent}%`;
+        App.$.jump.setAttribute('aria-label', `Cancel fast-forward at generation ${progress.generation} of ${progress.target}`);
+      } else {
+        App.$.jump.textContent = 'JUMP';
+        App.$.jump.setAttribute('aria-label', 'Jump ahead by the number of generations');
+      }
+    });
+
     effect(() => {
       if (App.playback.state()) {
         App.$.next.disabled = true;
+        App.$.previous.disabled = true;
+        App.$.jump.disabled = true;
         App.$.playPause.textContent = 'PAUSE';
         App.$.playPause.setAttribute('aria-label', 'Pause simulation');
       } else {
         App.$.next.disabled = false;
+        App.$.previous.disabled = false;
+        App.$.jump.disabled = false;
         App.$.playPause.textContent = 'PLAY';
         App.$.playPause.setAttribute('aria-label', 'Start simulation');
       }
@@ -377,7 +530,7 @@ const App = {
     effect(() => {
       const speed = App.speed.state();
       App.$.speed.value = `${Math.pow((1000 - speed) * 0.01, 2)}`;
-      App.$.speedLabel.textContent = `${speed.toFixed(0)} ms`;
+      App.$.speedLabel.textContent = speed === 0 ? 'max' : `${speed.toFixed(0)} ms`;
     });
   },
 
@@ -542,11 +695,20 @@ const App = {
      */
     end(x, y) {
       const st = App.moveCanvas.state;
-      if (st.mouseState === 'down') {
+      if (st.mouseState === 'down' && App.$.eraser.checked) {
+        canvasWorkerMessage({
+          type: CanvasWorkerMessageType.KillCells,
+          count: 1,
+          coordinates: new Uint8Array([0, 0, 0, 0]),
+          originPx: x,
+          originPy: y
+        });
+      } else if (st.mouseState === 'down') {
         canvasWorkerMessage({
           type: CanvasWorkerMessageType.SetCells,
           count: 1,
           colour: App.cellColour.state(),
+          state: Number(App.$.cellState.value) || 0,
           coordinates: new Uint8Array([0, 0, 0, 0]),
           originPx: x,
           originPy: y
@@ -555,9 +717,32 @@ const App = {
       st.mouseState = 'idle';
     }
   },
+  fastForward: {
+    state: signal(/** @type {import('./types/worker').Progress | null} */(null)),
+  },
   playback: {
     state: signal(false),
   },
+  population: {
+    /** @type {{ generation: number, population: number }[]} */
+    series: [],
+    /**
+     * @param {import('./types/worker').Stats} stats
+     */
+    update(stats) {
+      const series = App.population.series;
+      // the world was changed or went back, so later generations are gone
+      while (series.length && series[series.length - 1].generation >= stats.generation) {
+        series.pop();
+      }
+      series.push({ generation: stats.generation, population: stats.population });
+      if (series.length > App.$.populationGraph.width) {
+        series.shift();
+      }
+      App.$.population.textContent = `gen ${stats.generation} · pop ${stats.population} +${stats.births} −${stats.deaths}`;
+      drawPopulationGraph(App.$.populationGraph, series.map(s => s.population));
+    }
+  },
   settings: {
     state: reactive({ drawGrid: true, drawAge: false })
   },
@@ -566,10 +751,10 @@ const App = {
     /**
      * Convert speed slider value to milliseconds delay
      * @param {string} attrValue - The slider value as a string
-     * @returns {number} - The delay in milliseconds (1-1000)
+     * @returns {number} - The delay in milliseconds (0-1000, 0 runs as fast as possible)
      */
     convertSpeed(attrValue) {
-      return Math.max(1, 1000 - Math.sqrt(Number(attrValue)) * 100);
+      return Math.max(0, 1000 - Math.sqrt(Number(attrValue)) * 100);
     }
   }
 };
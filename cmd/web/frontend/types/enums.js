@@ -7,7 +7,10 @@ export const CanvasWorkerMessageType = /** @type {const} */ ({
   SetCells: 5,
   SetPattern: 6,
   SetSpeed: 7,
-  SettingsChange: 8
+  SettingsChange: 8,
+  SetTopology: 9,
+  Randomise: 10,
+  KillCells: 11
 });
 
 export const Command = /** @type {const} */ ({
@@ -16,10 +19,22 @@ export const Command = /** @type {const} */ ({
   Pause: 2,
   Clear: 3,
   Randomise: 4,
+  Previous: 5,
+  StepN: 6,
+  JumpTo: 7,
+  CancelFastForward: 8,
+  Undo: 9,
+  Redo: 10,
 });
 
 export const CanvasWorkerEventType = /** @type {const} */ ({
   Ready: 0,
   PlaybackStateChanged: 1,
   SpeedChanged: 2,
+  TopologyChanged: 3,
+  PeriodChanged: 4,
+  StatsChanged: 5,
+  FastForwardProgress: 6,
+  RateChanged: 7,
+  GenerationChanged: 8,
 });
@@ -1,7 +1,12 @@
 import type { PatternType } from '../patterns';
 
 export declare type Globals = {
-    WorldSize: number
+    Grid: number
+    Room: string
+    Rule: string
+    StateColours: number[] | null
+    WorldHeight: number
+    WorldWidth: number
 };
 
 export declare type CanvasMouseState = 'idle' | 'down' | 'dragging';
@@ -8,7 +8,10 @@ export declare type CanvasWorkerInitMessage = {
   cellSize: number;
   height: number;
   width: number;
-  worldSize: number;
+  grid: number;
+  stateColours: number[] | null;
+  worldHeight: number;
+  worldWidth: number;
 };
 
 export declare type CanvasDragMessage = {
@@ -27,6 +30,7 @@ export declare type CellSizeChangeMessage = {
 export declare type CommandMessage = {
   type: typeof CanvasWorkerMessageType.Command;
   cmd: typeof Command[keyof typeof Command];
+  arg?: number; // generations of StepN, target generation of JumpTo
 };
 
 export declare type ResizeMessage = {
@@ -39,6 +43,7 @@ export declare type SetCellsMessage = {
   type: typeof CanvasWorkerMessageType.SetCells;
   count: number;
   colour: number;
+  state: number;
   coordinates: Uint8Array; // x | y
   originPx: number;
   originPy: number;
@@ -47,7 +52,9 @@ export declare type SetCellsMessage = {
 export declare type SetPatternMessage = {
   type: typeof CanvasWorkerMessageType.SetPattern;
   colour: number;
+  state: number;
   patternType: string;
+  pasteMode: number;
   originPx: number;
   originPy: number;
 };
@@ -63,6 +70,31 @@ export declare type SettingsChangeMessage = {
   drawGrid: boolean;
 };
 
+export declare type SetTopologyMessage = {
+  type: typeof CanvasWorkerMessageType.SetTopology;
+  topology: number;
+};
+
+export declare type RandomiseMessage = {
+  type: typeof CanvasWorkerMessageType.Randomise;
+  seed: string;
+  density: number;
+  symmetry: number;
+  palette: number[];
+  x: number;
+  y: number;
+  width: number; // 0 reaches to the right edge of the world
+  height: number; // 0 reaches to the bottom edge of the world
+};
+
+export declare type KillCellsMessage = {
+  type: typeof CanvasWorkerMessageType.KillCells;
+  count: number;
+  coordinates: Uint8Array; // x | y
+  originPx: number;
+  originPy: number;
+};
+
 export declare type CanvasWorkerMessage = CanvasWorkerInitMessage
   | CanvasDragMessage
   | CellSizeChangeMessage
@@ -71,7 +103,10 @@ export declare type CanvasWorkerMessage = CanvasWorkerInitMessage
   | SetCellsMessage
   | SetPatternMessage
   | SetSpeedMessage
-  | SettingsChangeMessage;
+  | SettingsChangeMessage
+  | SetTopologyMessage
+  | RandomiseMessage
+  | KillCellsMessage;
 
 // #endregion canvas worker message
 
@@ -91,6 +126,62 @@ export declare type SpeedChangedEvent = {
   speed: number;
 };
 
-export declare type CanvasWorkerEvent = PlaybackStateChangedEvent | ReadyEvent | SpeedChangedEvent;
+export declare type TopologyChangedEvent = {
+  type: typeof CanvasWorkerEventType.TopologyChanged;
+  topology: number;
+};
+
+export declare type PeriodChangedEvent = {
+  type: typeof CanvasWorkerEventType.PeriodChanged;
+  period: number;
+  cellsCount: number;
+};
+
+export declare type Stats = {
+  generation: number;
+  births: number;
+  deaths: number;
+  population: number;
+  bounding_box: { x: number; y: number; width: number; height: number };
+  colours: { colour: number; count: number }[] | null;
+  ages: number[];
+};
+
+export declare type StatsChangedEvent = {
+  type: typeof CanvasWorkerEventType.StatsChanged;
+  stats: Stats;
+};
+
+export declare type Progress = {
+  generation: number;
+  target: number;
+  done: boolean;
+};
+
+export declare type FastForwardProgressEvent = {
+  type: typeof CanvasWorkerEventType.FastForwardProgress;
+  progress: Progress;
+};
+
+export declare type RateChangedEvent = {
+  type: typeof CanvasWorkerEventType.RateChanged;
+  rate: number; // generations per second
+};
+
+export declare type GenerationChangedEvent = {
+  type: typeof CanvasWorkerEventType.GenerationChanged;
+  generation: number;
+  rule: string; // empty for outputs that predate the header
+};
+
+export declare type CanvasWorkerEvent = PlaybackStateChangedEvent
+  | ReadyEvent
+  | SpeedChangedEvent
+  | TopologyChangedEvent
+  | PeriodChangedEvent
+  | StatsChangedEvent
+  | FastForwardProgressEvent
+  | RateChangedEvent
+  | GenerationChangedEvent;
 
 // #endregion canvas worker event
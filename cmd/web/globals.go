@@ -1,5 +1,12 @@
 package web
 
+import "github.com/JackWithOneEye/conwaymore/internal/conway"
+
 type Globals struct {
-	WorldSize uint
+	Grid         conway.Grid
+	Room         string // empty for the default room
+	Rule         string
+	StateColours []uint32 // colour of each cell state, empty if cells carry their own colour
+	WorldHeight  uint
+	WorldWidth   uint
 }
@@ -3,13 +3,25 @@ package config
 import (
 	"fmt"
 
+	"github.com/JackWithOneEye/conwaymore/internal/conway"
 	"github.com/spf13/viper"
 )
 
 type env struct {
-	DBUrl     string `mapstructure:"DB_URL"`
-	Port      uint   `mapstructure:"PORT"`
-	WorldSize uint   `mapstructure:"WORLD_SIZE"`
+	AutoPause         bool   `mapstructure:"AUTO_PAUSE"`
+	Backend           string `mapstructure:"BACKEND"`
+	BroadcastFPS      uint   `mapstructure:"BROADCAST_FPS"`
+	ColourInheritance string `mapstructure:"COLOUR_INHERITANCE"`
+	DBUrl             string `mapstructure:"DB_URL"`
+	HistoryDepth      uint   `mapstructure:"HISTORY_DEPTH"`
+	MaxRooms          uint   `mapstructure:"MAX_ROOMS"`
+	MaxRoomSize       uint   `mapstructure:"MAX_ROOM_SIZE"`
+	Port              uint   `mapstructure:"PORT"`
+	Rule              string `mapstructure:"RULE"`
+	Topology          string `mapstructure:"TOPOLOGY"`
+	WorldHeight       uint   `mapstructure:"WORLD_HEIGHT"`
+	WorldSize         uint   `mapstructure:"WORLD_SIZE"` // fallback for a missing width or height
+	WorldWidth        uint   `mapstructure:"WORLD_WIDTH"`
 }
 
 type Config struct {
@@ -27,6 +39,17 @@ func NewConfig() *Config {
 	viper.SetConfigFile(".env")
 	viper.SetConfigType("env")
 	viper.AutomaticEnv()
+	viper.SetDefault("AUTO_PAUSE", false)
+	viper.SetDefault("BACKEND", conway.DefaultBackend)
+	viper.SetDefault("BROADCAST_FPS", 30)
+	viper.SetDefault("COLOUR_INHERITANCE", conway.DefaultColourInheritance)
+	viper.SetDefault("HISTORY_DEPTH", 100)
+	viper.SetDefault("MAX_ROOMS", 16)
+	viper.SetDefault("MAX_ROOM_SIZE", 2048)
+	viper.SetDefault("RULE", conway.DefaultRule)
+	viper.SetDefault("TOPOLOGY", conway.DefaultTopology)
+	viper.SetDefault("WORLD_HEIGHT", 0)
+	viper.SetDefault("WORLD_WIDTH", 0)
 	err := viper.ReadInConfig()
 	if err != nil {
 		panic(fmt.Sprintf("error loading config: %s", err))
@@ -41,14 +64,60 @@ func NewConfig() *Config {
 	return cfgInstance
 }
 
+func (c *Config) AutoPause() bool {
+	return c.env.AutoPause
+}
+
+func (c *Config) Backend() string {
+	return c.env.Backend
+}
+
+func (c *Config) BroadcastFPS() uint {
+	return c.env.BroadcastFPS
+}
+
+func (c *Config) ColourInheritance() string {
+	return c.env.ColourInheritance
+}
+
 func (c *Config) DBUrl() string {
 	return c.env.DBUrl
 }
 
+func (c *Config) HistoryDepth() uint {
+	return c.env.HistoryDepth
+}
+
+func (c *Config) MaxRooms() uint {
+	return c.env.MaxRooms
+}
+
+func (c *Config) MaxRoomSize() uint {
+	return c.env.MaxRoomSize
+}
+
 func (c *Config) Port() uint {
 	return c.env.Port
 }
 
-func (c *Config) WorldSize() uint {
-	return c.env.WorldSize
+func (c *Config) Rule() string {
+	return c.env.Rule
+}
+
+func (c *Config) Topology() string {
+	return c.env.Topology
+}
+
+func (c *Config) WorldHeight() uint {
+	if c.env.WorldHeight == 0 {
+		return c.env.WorldSize
+	}
+	return c.env.WorldHeight
+}
+
+func (c *Config) WorldWidth() uint {
+	if c.env.WorldWidth == 0 {
+		return c.env.WorldSize
+	}
+	return c.env.WorldWidth
 }
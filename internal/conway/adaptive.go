@@ -0,0 +1,147 @@
+package conway
+
+// Population densities at which the adaptive backend switches between its sparse and
+// dense implementation. They are apart so that a world near one of them does not
+// switch back and forth.
+const (
+	denseAbove  = 1.0 / 1024
+	sparseBelow = 1.0 / 4096
+)
+
+// adaptive switches between the sparse and the bit-packed implementation depending
+// on the population density of the world.
+type adaptive struct {
+	Conway
+	dense      bool
+	changes    changes // of the last generation, which a switch would lose
+	record     bool
+	recorded   []CellChange // of the last generation
+	recordedOK bool
+	width      int
+	height     int
+	area       float64
+	newSparse  func() Conway
+	newDense   func() Conway
+}
+
+func newAdaptive(width, height int, newSparse, newDense func() Conway) *adaptive {
+	return &adaptive{
+		Conway:    newSparse(),
+		width:     width,
+		height:    height,
+		area:      float64(width * height),
+		newSparse: newSparse,
+		newDense:  newDense,
+	}
+}
+
+func (a *adaptive) Advance(generations uint64) {
+	for range generations {
+		a.NextGen()
+	}
+}
+
+func (a *adaptive) Changes() (uint, uint) {
+	return a.changes.births, a.changes.deaths
+}
+
+func (a *adaptive) Clear() {
+	a.Conway.Clear()
+	a.changes = changes{}
+	a.recordedOK = false
+}
+
+func (a *adaptive) ClearRegion(x, y, width, height uint16) {
+	a.changes = changes{}
+	a.recordedOK = false
+	a.Conway.ClearRegion(x, y, width, height)
+	a.adapt()
+}
+
+func (a *adaptive) KillCell(x, y uint16) {
+	a.changes = changes{}
+	a.recordedOK = false
+	a.Conway.KillCell(x, y)
+	if a.dense {
+		a.adapt()
+	}
+}
+
+func (a *adaptive) NextGen() {
+	a.Conway.NextGen()
+	a.changes.births, a.changes.deaths = a.Conway.Changes()
+	a.recordedOK = false
+	if r, ok := a.Conway.(ChangeRecorder); ok {
+		a.recorded, a.recordedOK = r.RecordedChanges()
+	}
+	a.adapt()
+}
+
+func (a *adaptive) RecordChanges(on bool) {
+	a.record = on
+	a.recordedOK = false
+	if r, ok := a.Conway.(ChangeRecorder); ok {
+		r.RecordChanges(on)
+	}
+}
+
+func (a *adaptive) Recording() bool {
+	r, ok := a.Conway.(ChangeRecorder)
+	return ok && r.Recording()
+}
+
+func (a *adaptive) RecordedChanges() ([]CellChange, bool) {
+	return a.recorded, a.recordedOK
+}
+
+func (a *adaptive) Randomise(s Soup) {
+	// start with the implementation that suits the density the soup is expected to have
+	_, _, w, h := s.rect(a.width, a.height)
+	a.switchTo(s.Density*float64(w*h)/a.area > denseAbove)
+	a.Conway.Randomise(s)
+	a.changes = changes{}
+	a.recordedOK = false
+	a.adapt()
+}
+
+func (a *adaptive) SetCell(x, y uint16, colour uint32, age uint16, state uint8) {
+	a.changes = changes{}
+	a.recordedOK = false
+	a.Conway.SetCell(x, y, colour, age, state)
+	if !a.dense {
+		a.adapt()
+	}
+}
+
+func (a *adaptive) adapt() {
+	density := float64(a.CellsCount()) / a.area
+	switch {
+	case !a.dense && density > denseAbove:
+		a.switchTo(true)
+	case a.dense && density < sparseBelow:
+		a.switchTo(false)
+	}
+}
+
+// switchTo moves all cells into a new instance of the sparse or dense implementation.
+func (a *adaptive) switchTo(dense bool) {
+	if a.dense == dense {
+		return
+	}
+	var next Conway
+	if dense {
+		next = a.newDense()
+	} else {
+		next = a.newSparse()
+	}
+	next.SetTopology(a.Topology())
+	if r, ok := next.(ChangeRecorder); ok {
+		r.RecordChanges(a.record)
+	}
+	for _, cell := range a.Cells() {
+		x, y, colour, age := cell.Values()
+		next.SetCell(x, y, colour, age, cell.State())
+	}
+	a.Conway = next
+	a.dense = dense
+}
@@ -0,0 +1,144 @@
+package conway
+
+import (
+	"strconv"
+	"strings"
+)
+
+// wechslerDigits encode the columns of a strip of 5 rows and the lengths of runs of
+// empty columns.
+const wechslerDigits = "0123456789abcdefghijklmnopqrstuvwxyz"
+
+// lifeObjectNames are the names of well-known objects of Life by their apgcode.
+var lifeObjectNames = map[string]string{
+	"xs4_33":       "block",
+	"xs4_252":      "tub",
+	"xs5_253":      "boat",
+	"xs6_356":      "ship",
+	"xs6_696":      "beehive",
+	"xs7_2596":     "loaf",
+	"xs8_6996":     "pond",
+	"xp2_7":        "blinker",
+	"xp2_7e":       "toad",
+	"xp2_318c":     "beacon",
+	"xq4_153":      "glider",
+	"xq4_6frc":     "lightweight spaceship",
+	"xq4_27dee6":   "middleweight spaceship",
+	"xq4_27deee6":  "heavyweight spaceship",
+	"xp15_4r4z4r4": "pentadecathlon",
+}
+
+// apgcode returns the apgcode of an object from its normalised phases: the prefix of
+// its kind, and the smallest extended Wechsler format of any phase in any orientation.
+// Only square grids are rotated and reflected. The states of multi-state rules are not
+// encoded, any cell that is not dead counts as alive.
+func apgcode(kind ObjectKind, period, population int, phases [][]islandCell, square bool) string {
+	var prefix string
+	switch kind {
+	case StillLife:
+		prefix = "xs" + strconv.Itoa(population)
+	case Oscillator:
+		prefix = "xp" + strconv.Itoa(period)
+	default:
+		prefix = "xq" + strconv.Itoa(period)
+	}
+
+	orientations := 1
+	if square {
+		orientations = 8
+	}
+	best := ""
+	for _, phase := range phases {
+		for o := range orientations {
+			code := wechsler(orient(phase, o))
+			if best == "" || len(code) < len(best) || (len(code) == len(best) && code < best) {
+				best = code
+			}
+		}
+	}
+	return prefix + "_" + best
+}
+
+// orient returns the cells reflected and rotated by one of the 8 symmetries of the
+// square, moved back to the top left corner.
+func orient(cells []islandCell, o int) [][2]int {
+	oriented := make([][2]int, len(cells))
+	x0, y0 := 0, 0
+	for i, c := range cells {
+		x, y := c.x, c.y
+		if o&4 != 0 {
+			x, y = y, x
+		}
+		if o&1 != 0 {
+			x = -x
+		}
+		if o&2 != 0 {
+			y = -y
+		}
+		oriented[i] = [2]int{x, y}
+		if i == 0 || x < x0 {
+			x0 = x
+		}
+		if i == 0 || y < y0 {
+			y0 = y
+		}
+	}
+	for i := range oriented {
+		oriented[i][0] -= x0
+		oriented[i][1] -= y0
+	}
+	return oriented
+}
+
+// wechsler returns the extended Wechsler format of cells in the top left corner. Each
+// strip of 5 rows is written as a digit per column, the top row being the lowest bit,
+// without the trailing empty columns. Runs of empty columns are shortened to "w" for
+// two, "x" for three and "y" followed by the number of columns beyond four. Strips are
+// separated by "z".
+func wechsler(cells [][2]int) string {
+	var width, height int
+	for _, c := range cells {
+		width, height = max(width, c[0]+1), max(height, c[1]+1)
+	}
+	strips := (height + 4) / 5
+	columns := make([]byte, strips*width)
+	for _, c := range cells {
+		columns[c[1]/5*width+c[0]] |= 1 << (c[1] % 5)
+	}
+
+	var b strings.Builder
+	for s := range strips {
+		if s > 0 {
+			b.WriteByte('z')
+		}
+		strip := columns[s*width : (s+1)*width]
+		end := len(strip)
+		for end > 0 && strip[end-1] == 0 {
+			end -= 1
+		}
+		for i := 0; i < end; {
+			if strip[i] != 0 {
+				b.WriteByte(wechslerDigits[strip[i]])
+				i += 1
+				continue
+			}
+			run := 0
+			for i+run < end && strip[i+run] == 0 && run < 4+len(wechslerDigits)-1 {
+				run += 1
+			}
+			switch run {
+			case 1:
+				b.WriteByte('0')
+			case 2:
+				b.WriteByte('w')
+			case 3:
+				b.WriteByte('x')
+			default:
+				b.WriteByte('y')
+				b.WriteByte(wechslerDigits[run-4])
+			}
+			i += run
+		}
+	}
+	return b.String()
+}
@@ -0,0 +1,435 @@
+package conway
+
+import (
+	"iter"
+	"math"
+	"math/bits"
+)
+
+// bitPacked is a dense implementation for rules with a Moore or von Neumann
+// neighbourhood of range 1. Alive cells are packed into 64 bit words per row, so the
+// neighbour counts and the rule are computed for 64 cells at once. Colour, age and
+// decay stage are kept in side arrays and only touched for cells that change.
+type bitPacked struct {
+	width       int
+	height      int
+	stride      int    // words per row
+	lastMask    uint64 // valid bits of the last word of a row
+	rule        Rule
+	topology    Topology
+	inheritance ColourInheritance
+	offsets     []offset
+	generation  uint32
+	count       uint
+	changes     changes
+	bands       []changes // of the generation being computed
+	bandRecorder
+
+	alive        []uint64
+	decaying     []uint64
+	nextAlive    []uint64
+	nextDecaying []uint64
+	colours      []uint32
+	born         []uint32 // generation in which the cell was born, for its age
+	states       []uint8
+
+	birthCounts    []int // neighbour counts that give birth
+	survivalCounts []int
+}
+
+func newBitPacked(width, height int, rule Rule, topology Topology, inheritance ColourInheritance) *bitPacked {
+	stride := (width + 63) / 64
+	words := stride * height
+	b := &bitPacked{
+		width:        width,
+		height:       height,
+		stride:       stride,
+		lastMask:     math.MaxUint64 >> (stride*64 - width),
+		rule:         rule,
+		topology:     topology,
+		inheritance:  inheritance,
+		offsets:      rule.offsets(0),
+		alive:        make([]uint64, words),
+		decaying:     make([]uint64, words),
+		nextAlive:    make([]uint64, words),
+		nextDecaying: make([]uint64, words),
+		colours:      make([]uint32, width*height),
+		born:         make([]uint32, width*height),
+		states:       make([]uint8, width*height),
+	}
+	for n := range rule.maxCount() + 1 {
+		if rule.birth[n] {
+			b.birthCounts = append(b.birthCounts, n)
+		}
+		if rule.survival[n] {
+			b.survivalCounts = append(b.survivalCounts, n)
+		}
+	}
+	return b
+}
+
+func (b *bitPacked) Advance(generations uint64) {
+	for range generations {
+		b.NextGen()
+	}
+}
+
+func (b *bitPacked) CanSetCell(x, y uint16) bool {
+	if int(x) >= b.width || int(y) >= b.height {
+		return false
+	}
+	w, bit := b.bit(int(x), int(y))
+	return (b.alive[w]|b.decaying[w])&bit == 0
+}
+
+func (b *bitPacked) Cells() iter.Seq2[uint, Cell] {
+	return func(yield func(uint, Cell) bool) {
+		var i uint
+		for y := range b.height {
+			for wx := range b.stride {
+				w := y*b.stride + wx
+				occupied := b.alive[w] | b.decaying[w]
+				for occupied != 0 {
+					x := wx*64 + bits.TrailingZeros64(occupied)
+					occupied &= occupied - 1
+					idx := y*b.width + x
+					age := min(b.generation-b.born[idx], math.MaxUint16)
+					if !yield(i, &aliveCell{uint16(x), uint16(y), b.colours[idx], uint16(age), b.states[idx]}) {
+						return
+					}
+					i += 1
+				}
+			}
+		}
+	}
+}
+
+func (b *bitPacked) CellAt(x, y uint16) (Cell, bool) {
+	if int(x) >= b.width || int(y) >= b.height {
+		return nil, false
+	}
+	w, bit := b.bit(int(x), int(y))
+	if (b.alive[w]|b.decaying[w])&bit == 0 {
+		return nil, false
+	}
+	idx := int(y)*b.width + int(x)
+	age := min(b.generation-b.born[idx], math.MaxUint16)
+	return &aliveCell{x, y, b.colours[idx], uint16(age), b.states[idx]}, true
+}
+
+func (b *bitPacked) CellsCount() uint {
+	return b.count
+}
+
+func (b *bitPacked) Changes() (uint, uint) {
+	return b.changes.births, b.changes.deaths
+}
+
+func (b *bitPacked) Clear() {
+	clear(b.alive)
+	clear(b.decaying)
+	b.count = 0
+	b.edited()
+}
+
+func (b *bitPacked) NextGen() {
+	next := b.generation + 1
+	b.bands = resetBandChanges(b.bands)
+	b.begin()
+	count := forEachBand(b.width, b.height, func(band, from, to int) uint {
+		return b.nextRows(band, from, to, next)
+	})
+	b.finish()
+
+	b.alive, b.nextAlive = b.nextAlive, b.alive
+	b.decaying, b.nextDecaying = b.nextDecaying, b.decaying
+	b.generation = next
+	b.count = count
+	b.changes = sumChanges(b.bands)
+}
+
+// nextRows computes the rows from..to of the next generation, counts the cells born
+// and died in them and returns the number of cells in them.
+func (b *bitPacked) nextRows(band, from, to int, next uint32) uint {
+	ch := &b.bands[band]
+	var count uint
+	maxStage := b.rule.maxStage()
+	// rows above and below the world flipped by a twisted edge
+	reversed := [2][]uint64{make([]uint64, b.stride), make([]uint64, b.stride)}
+
+	for y := from; y < to; y++ {
+		var rows [3][]uint64 // above, the row itself and below, nil beyond dead edges
+		var west, east [3]uint64
+		for r := range rows {
+			rows[r] = b.neighbourRow(y+r-1, reversed[r/2])
+			if b.aliveAt(-1, y+r-1) {
+				west[r] = 1
+			}
+			if b.aliveAt(b.width, y+r-1) {
+				east[r] = 1
+			}
+		}
+
+		for wx := range b.stride {
+			w := y*b.stride + wx
+			var shifted [3][3]uint64 // per row: cells to the west, the cells themselves and to the east
+			for r, row := range rows {
+				if row == nil {
+					continue
+				}
+				shifted[r] = b.shiftRow(row, wx, west[r], east[r])
+			}
+
+			var s0, s1, s2, s3 uint64
+			add := func(v uint64) {
+				c0 := s0 & v
+				s0 ^= v
+				c1 := s1 & c0
+				s1 ^= c0
+				c2 := s2 & c1
+				s2 ^= c1
+				s3 |= c2
+			}
+			if b.rule.neighbourhood == moore {
+				add(shifted[0][0])
+				add(shifted[0][2])
+				add(shifted[2][0])
+				add(shifted[2][2])
+			}
+			add(shifted[0][1])
+			add(shifted[1][0])
+			add(shifted[1][2])
+			add(shifted[2][1])
+
+			countIs := func(n int) uint64 {
+				m := ^uint64(0)
+				for i, s := range [4]uint64{s0, s1, s2, s3} {
+					if n&(1<<i) != 0 {
+						m &= s
+					} else {
+						m &^= s
+					}
+				}
+				return m
+			}
+			var birth, survival uint64
+			for _, n := range b.birthCounts {
+				birth |= countIs(n)
+			}
+			for _, n := range b.survivalCounts {
+				survival |= countIs(n)
+			}
+
+			alive, decaying := b.alive[w], b.decaying[w]
+			nextAlive := (alive & survival) | (birth &^ alive &^ decaying)
+			if wx == b.stride-1 {
+				nextAlive &= b.lastMask
+			}
+
+			var nextDecaying uint64
+			for d := decaying; d != 0; d &= d - 1 {
+				x := wx*64 + bits.TrailingZeros64(d)
+				idx := y*b.width + x
+				b.recordChange(band, x, y, false)
+				if b.states[idx] < maxStage {
+					b.states[idx] += 1
+					b.born[idx] += 1 // decaying cells do not age
+					nextDecaying |= d & -d
+				}
+			}
+			for d := alive &^ nextAlive; d != 0 && (maxStage > 0 || b.record); d &= d - 1 {
+				x := wx*64 + bits.TrailingZeros64(d)
+				idx := y*b.width + x
+				b.recordChange(band, x, y, false)
+				if maxStage > 0 {
+					b.states[idx] = 1
+					b.born[idx] += 1
+					nextDecaying |= d & -d
+				}
+			}
+			for n := nextAlive &^ alive; n != 0; n &= n - 1 {
+				x := wx*64 + bits.TrailingZeros64(n)
+				idx := y*b.width + x
+				b.recordChange(band, x, y, true)
+				b.colours[idx] = b.inheritColour(x, y)
+				b.born[idx] = next
+				b.states[idx] = 0
+			}
+
+			b.nextAlive[w] = nextAlive
+			b.nextDecaying[w] = nextDecaying
+			ch.births += uint(bits.OnesCount64(nextAlive &^ alive))
+			ch.deaths += uint(bits.OnesCount64(alive &^ nextAlive))
+			count += uint(bits.OnesCount64(nextAlive | nextDecaying))
+		}
+	}
+	return count
+}
+
+func (b *bitPacked) ClearRegion(x, y, width, height uint16) {
+	for cy := int(y); cy < min(int(y)+int(height), b.height); cy++ {
+		for cx := int(x); cx < min(int(x)+int(width), b.width); cx++ {
+			b.KillCell(uint16(cx), uint16(cy))
+		}
+	}
+	b.edited()
+}
+
+func (b *bitPacked) KillCell(x, y uint16) {
+	b.edited()
+	if int(x) >= b.width || int(y) >= b.height {
+		return
+	}
+	w, bit := b.bit(int(x), int(y))
+	if (b.alive[w]|b.decaying[w])&bit != 0 {
+		b.count -= 1
+	}
+	b.alive[w] &^= bit
+	b.decaying[w] &^= bit
+}
+
+func (b *bitPacked) Randomise(s Soup) {
+	s.fill(b, b.width, b.height, 1)
+}
+
+func (b *bitPacked) Rule() Rule {
+	return b.rule
+}
+
+func (b *bitPacked) SetCell(x, y uint16, colour uint32, age uint16, state uint8) {
+	b.edited()
+	w, bit := b.bit(int(x), int(y))
+	if (b.alive[w]|b.decaying[w])&bit == 0 {
+		b.count += 1
+	}
+	state = min(state, b.rule.maxStage())
+	if state == 0 {
+		b.alive[w] |= bit
+		b.decaying[w] &^= bit
+	} else {
+		b.alive[w] &^= bit
+		b.decaying[w] |= bit
+	}
+
+	idx := int(y)*b.width + int(x)
+	b.colours[idx] = colour
+	b.born[idx] = b.generation - uint32(age)
+	b.states[idx] = state
+}
+
+func (b *bitPacked) SetTopology(t Topology) error {
+	b.topology = t
+	return nil
+}
+
+func (b *bitPacked) Topology() Topology {
+	return b.topology
+}
+
+// edited forgets the changes of the last generation, as the world was changed by other
+// means than a step.
+func (b *bitPacked) edited() {
+	b.changes = changes{}
+	b.forget()
+}
+
+// recordChange collects the cell at (x, y) as it is in the current generation, or its
+// position if it is born, if changes are recorded.
+func (b *bitPacked) recordChange(band, x, y int, born bool) {
+	if !b.record {
+		return
+	}
+	if born {
+		b.add(band, CellChange{X: uint16(x), Y: uint16(y), Born: true})
+		return
+	}
+	idx := y*b.width + x
+	age := min(b.generation-b.born[idx], math.MaxUint16)
+	b.add(band, CellChange{uint16(x), uint16(y), b.colours[idx], uint16(age), b.states[idx], false})
+}
+
+// aliveAt reports whether the cell at (x, y), which may lie beyond the edges of the
+// world, is alive.
+func (b *bitPacked) aliveAt(x, y int) bool {
+	x, y, ok := b.topology.wrap(x, y, b.width, b.height)
+	if !ok {
+		return false
+	}
+	w, bit := b.bit(x, y)
+	return b.alive[w]&bit != 0
+}
+
+func (b *bitPacked) bit(x, y int) (int, uint64) {
+	return y*b.stride + x/64, 1 << (x % 64)
+}
+
+// inheritColour returns the colour of a cell born at (x, y) from its alive neighbours.
+func (b *bitPacked) inheritColour(x, y int) uint32 {
+	var parents [maxParents]uint32
+	n := 0
+	for _, o := range b.offsets {
+		nx, ny, ok := b.topology.wrap(x+o.dx, y+o.dy, b.width, b.height)
+		if !ok {
+			continue
+		}
+		if w, bit := b.bit(nx, ny); b.alive[w]&bit != 0 {
+			parents[n] = b.colours[ny*b.width+nx]
+			n += 1
+			if n == len(parents) {
+				break
+			}
+		}
+	}
+	return b.inheritance.Inherit(uint16(x), uint16(y), parents[:n])
+}
+
+// neighbourRow returns the alive cells of row y, which may lie just beyond the top or
+// bottom edge of the world, or nil if the row is beyond a dead edge. Rows joined with
+// a twist are flipped into the scratch buffer.
+func (b *bitPacked) neighbourRow(y int, scratch []uint64) []uint64 {
+	if y >= 0 && y < b.height {
+		return b.alive[y*b.stride:][:b.stride]
+	}
+	switch b.topology {
+	case Plane, Cylinder:
+		return nil
+	case Torus:
+		y = mod(y, b.height)
+		return b.alive[y*b.stride:][:b.stride]
+	}
+
+	y = mod(y, b.height)
+	src := b.alive[y*b.stride:][:b.stride]
+	dst := scratch
+	for i := range dst {
+		dst[i] = bits.Reverse64(src[b.stride-1-i])
+	}
+	// the reversed row is aligned to the end of the last word
+	if s := b.stride*64 - b.width; s > 0 {
+		for i := range dst {
+			dst[i] >>= s
+			if i+1 < len(dst) {
+				dst[i] |= dst[i+1] << (64 - s)
+			}
+		}
+	}
+	return dst
+}
+
+// shiftRow returns the cells to the west of, at and to the east of the cells of word
+// wx of the row. west and east are the cells just beyond the edges of the row.
+func (b *bitPacked) shiftRow(row []uint64, wx int, west, east uint64) [3]uint64 {
+	c := row[wx]
+	var w, e uint64
+	if wx > 0 {
+		w = row[wx-1] >> 63
+	} else {
+		w = west
+	}
+	if wx < b.stride-1 {
+		e = row[wx+1] << 63
+	} else {
+		e = east << ((b.width - 1) % 64)
+	}
+	return [3]uint64{c<<1 | w, c, c>>1 | e}
+}
@@ -0,0 +1,37 @@
+package conway
+
+import (
+	"maps"
+	"testing"
+)
+
+func TestBitPackedMatchesSparse(t *testing.T) {
+	rules := []string{"B3/S23", "B36/S23", "B2/S/C4", "B1/S1V", "B03/S23"}
+	for _, topology := range Topologies() {
+		for _, rule := range rules {
+			t.Run(topology.String()+" "+rule, func(t *testing.T) {
+				// not a multiple of the word size, so the words of a row are partly used
+				cfg := testConfig{"bitpacked", "channel-mix", rule, topology.String(), 70, 45}
+				soup := Soup{Seed: 7, Density: 0.4, Palette: []uint32{0xff0000, 0x00ff00, 0x0000ff}}
+				sparse, bitPacked := newTestWorlds(t, cfg, soup)
+				for gen := range 60 {
+					sparse.NextGen()
+					bitPacked.NextGen()
+					if !maps.Equal(cellsByPosition(sparse), cellsByPosition(bitPacked)) {
+						t.Fatalf("the worlds differ in generation %d", gen+1)
+					}
+					sb, sd := sparse.Changes()
+					bb, bd := bitPacked.Changes()
+					if sb != bb || sd != bd {
+						t.Fatalf("the changes differ in generation %d: %d/%d and %d/%d", gen+1, sb, sd, bb, bd)
+					}
+				}
+			})
+		}
+	}
+}
+
+func TestBitPackedKnownPatterns(t *testing.T) {
+	// across the last word of a row, which is partly used, and the edges of the world
+	testKnownPatterns(t, "bitpacked", 70, 45, 60, 36)
+}
@@ -3,6 +3,7 @@ package conway
 import "iter"
 
 type Cell interface {
+	State() uint8
 	Values() (x, y uint16, colour uint32, age uint16)
 }
 
@@ -11,6 +12,11 @@ type aliveCell struct {
 	y      uint16
 	colour uint32
 	age    uint16
+	state  uint8 // 0 = alive, > 0 = decay stage (Generations rules)
+}
+
+func (ac *aliveCell) State() uint8 {
+	return ac.state
 }
 
 func (ac *aliveCell) Values() (x, y uint16, colour uint32, age uint16) {
@@ -55,6 +61,10 @@ func (sws *swapSet[V]) get(x, y uint16) (V, bool) {
 	return d, ok
 }
 
+func (sws *swapSet[V]) remove(x, y uint16) {
+	delete(sws.sets[sws.current], toCoord(x, y))
+}
+
 func (sws *swapSet[V]) size() int {
 	return len(sws.sets[sws.current])
 }
@@ -0,0 +1,284 @@
+package conway
+
+import (
+	"cmp"
+	"slices"
+	"strings"
+)
+
+// maxCensusPeriod is the longest period of the objects the census recognises.
+const maxCensusPeriod = 64
+
+// maxIslandCells is the largest island the census runs in isolation. Larger islands
+// are still settling, and would take too long to run.
+const maxIslandCells = 1 << 12
+
+// ObjectKind tells how an object of the census behaves.
+type ObjectKind string
+
+const (
+	StillLife  ObjectKind = "still life"
+	Oscillator ObjectKind = "oscillator"
+	Spaceship  ObjectKind = "spaceship"
+	Unsettled  ObjectKind = "unsettled" // did not repeat within maxCensusPeriod generations
+)
+
+// unsettledCode is the apgcode of all islands that do not repeat, like apgsearch names
+// the objects it cannot classify.
+const unsettledCode = "PATHOLOGICAL"
+
+// Census counts the objects of a world.
+type Census struct {
+	Generation uint64   `json:"generation"`
+	Islands    uint     `json:"islands"`
+	Objects    []Object `json:"objects"` // most frequent first
+}
+
+// Object is a kind of object found by the census, identified by its apgcode.
+type Object struct {
+	Code   string     `json:"code"`           // e.g. "xs4_33" for the block
+	Name   string     `json:"name,omitempty"` // only for well-known objects of Life
+	Kind   ObjectKind `json:"kind"`
+	Period uint       `json:"period"` // 0 for unsettled objects
+	DX     int        `json:"dx"`     // displacement per period, only for spaceships
+	DY     int        `json:"dy"`
+	Count  uint       `json:"count"`
+}
+
+// Islands are the groups of alive cells of a world that are close enough to interact.
+type Islands struct {
+	rule    Rule
+	islands [][]islandCell
+}
+
+// islandCell is a cell of an island. Its coordinates are unwrapped, so an island that
+// crosses an edge of the world keeps its shape, apart from the mirroring of twisted
+// edges.
+type islandCell struct {
+	x, y  int
+	state uint8
+}
+
+// WorldCells is a copy of the alive cells of a world, which can be split into islands
+// while the world goes on.
+type WorldCells struct {
+	rule     Rule
+	topology Topology
+	width    int
+	height   int
+	cells    []islandCell
+}
+
+// CopyCells copies the alive cells of a world of the given size.
+func CopyCells(c Conway, width, height int) WorldCells {
+	cells := make([]islandCell, 0, c.CellsCount())
+	for _, cell := range c.Cells() {
+		x, y, _, _ := cell.Values()
+		cells = append(cells, islandCell{int(x), int(y), cell.State()})
+	}
+	return WorldCells{c.Rule(), c.Topology(), width, height, cells}
+}
+
+// FindIslands splits the alive cells of a world of the given size into islands.
+func FindIslands(c Conway, width, height int) Islands {
+	return CopyCells(c, width, height).Islands()
+}
+
+// Islands splits the cells into islands. Cells at most twice the range of the rule
+// apart belong to the same island, so cells of different islands cannot influence each
+// other in the next generation.
+func (w WorldCells) Islands() Islands {
+	cells := w.cells
+	index := make(map[uint32]int, len(cells))
+	for i, cell := range cells {
+		index[toCoord(uint16(cell.x), uint16(cell.y))] = i
+	}
+
+	d := 2 * w.rule.rng
+	visited := make([]bool, len(cells))
+	unwrapped := make([][2]int, len(cells))
+	var queue []int
+	islands := Islands{rule: w.rule}
+	for i := range cells {
+		if visited[i] {
+			continue
+		}
+		visited[i] = true
+		unwrapped[i] = [2]int{cells[i].x, cells[i].y}
+		queue = append(queue[:0], i)
+		for q := 0; q < len(queue); q++ {
+			j := queue[q]
+			for dy := -d; dy <= d; dy++ {
+				for dx := -d; dx <= d; dx++ {
+					x, y, ok := w.topology.wrap(cells[j].x+dx, cells[j].y+dy, w.width, w.height)
+					if !ok {
+						continue
+					}
+					k, ok := index[toCoord(uint16(x), uint16(y))]
+					if !ok || visited[k] {
+						continue
+					}
+					visited[k] = true
+					unwrapped[k] = [2]int{unwrapped[j][0] + dx, unwrapped[j][1] + dy}
+					queue = append(queue, k)
+				}
+			}
+		}
+
+		island := make([]islandCell, len(queue))
+		for n, k := range queue {
+			island[n] = islandCell{unwrapped[k][0], unwrapped[k][1], cells[k].state}
+		}
+		islands.islands = append(islands.islands, island)
+	}
+	return islands
+}
+
+// Census runs each island in isolation and counts the objects by their apgcode. Islands
+// of the same shape are run only once, islands larger than maxIslandCells not at all.
+func (is Islands) Census() Census {
+	census := Census{Islands: uint(len(is.islands))}
+	known := make(map[string]int) // index of the object of each shape
+	objects := make(map[string]int)
+	for _, island := range is.islands {
+		key := unsettledCode
+		var cells []islandCell
+		if len(island) <= maxIslandCells {
+			cells = is.normalise(island)
+			key = shapeKey(cells)
+		}
+		i, ok := known[key]
+		if !ok {
+			obj := Object{Code: unsettledCode, Kind: Unsettled}
+			if cells != nil {
+				obj = is.classify(cells)
+			}
+			i, ok = objects[obj.Code]
+			if !ok {
+				i = len(census.Objects)
+				objects[obj.Code] = i
+				census.Objects = append(census.Objects, obj)
+			}
+			known[key] = i
+		}
+		census.Objects[i].Count += 1
+	}
+
+	slices.SortFunc(census.Objects, func(a, b Object) int {
+		return cmp.Or(cmp.Compare(b.Count, a.Count), strings.Compare(a.Code, b.Code))
+	})
+	return census
+}
+
+// normalise moves the cells to the top left corner and sorts them by row. The cells are
+// moved by a position of parity 0, so that hexagonal and triangular cells keep their
+// neighbourhoods.
+func (is Islands) normalise(cells []islandCell) []islandCell {
+	x0, y0 := cells[0].x, cells[0].y
+	for _, c := range cells {
+		x0, y0 = min(x0, c.x), min(y0, c.y)
+	}
+	if is.rule.parity(x0, y0) != 0 {
+		y0 -= 1
+	}
+	normalised := make([]islandCell, len(cells))
+	for i, c := range cells {
+		normalised[i] = islandCell{c.x - x0, c.y - y0, c.state}
+	}
+	sortCells(normalised)
+	return normalised
+}
+
+// classify runs normalised cells in an empty world until they repeat, possibly moved.
+// The world is large enough that a spaceship moving at the speed of light does not
+// reach its edges.
+func (is Islands) classify(cells []islandCell) Object {
+	margin := (maxCensusPeriod + 2) * is.rule.rng
+	var w, h int
+	for _, c := range cells {
+		w, h = max(w, c.x+1), max(h, c.y+1)
+	}
+	// even sizes suit every grid, and the even margin keeps the parity of the cells
+	w, h = (w+2*margin+1)&^1, (h+2*margin+1)&^1
+	c := is.isolated(w, h)
+	for _, cell := range cells {
+		c.SetCell(uint16(cell.x+margin), uint16(cell.y+margin), 0xffffff, 0, cell.state)
+	}
+
+	phases := [][]islandCell{cells}
+	x0, y0 := margin, margin
+	for period := 1; period <= maxCensusPeriod; period++ {
+		c.NextGen()
+		if c.CellsCount() == 0 {
+			break
+		}
+		next := make([]islandCell, 0, c.CellsCount())
+		for _, cell := range c.Cells() {
+			x, y, _, _ := cell.Values()
+			next = append(next, islandCell{int(x), int(y), cell.State()})
+		}
+		x1, y1 := next[0].x, next[0].y
+		for _, n := range next {
+			x1, y1 = min(x1, n.x), min(y1, n.y)
+		}
+		if is.rule.parity(x1, y1) != 0 {
+			y1 -= 1
+		}
+		for i := range next {
+			next[i].x -= x1
+			next[i].y -= y1
+		}
+		sortCells(next)
+
+		if slices.Equal(next, cells) {
+			obj := Object{Period: uint(period), DX: x1 - x0, DY: y1 - y0}
+			switch {
+			case obj.DX != 0 || obj.DY != 0:
+				obj.Kind = Spaceship
+			case period == 1:
+				obj.Kind = StillLife
+			default:
+				obj.Kind = Oscillator
+			}
+			obj.Code = apgcode(obj.Kind, period, len(cells), phases, is.rule.Grid() == SquareGrid)
+			if is.rule.String() == "B3/S23" {
+				obj.Name = lifeObjectNames[obj.Code]
+			}
+			return obj
+		}
+		phases = append(phases, next)
+	}
+	return Object{Code: unsettledCode, Kind: Unsettled}
+}
+
+// isolated returns an empty world with dead edges for the rule of the islands, run by
+// the backend that suits small patterns best.
+func (is Islands) isolated(width, height int) Conway {
+	switch {
+	case is.rule.table != nil:
+		return newMultiState(width, height, is.rule, Plane)
+	case !is.rule.isLifeLike():
+		return newLargerThanLife(width, height, is.rule, Plane, channelMix{})
+	}
+	return newSparse(width, height, is.rule, Plane, channelMix{})
+}
+
+func sortCells(cells []islandCell) {
+	slices.SortFunc(cells, func(a, b islandCell) int {
+		return cmp.Or(cmp.Compare(a.y, b.y), cmp.Compare(a.x, b.x))
+	})
+}
+
+// shapeKey identifies normalised cells.
+func shapeKey(cells []islandCell) string {
+	var b strings.Builder
+	b.Grow(5 * len(cells))
+	for _, c := range cells {
+		b.WriteByte(byte(c.x >> 8))
+		b.WriteByte(byte(c.x))
+		b.WriteByte(byte(c.y >> 8))
+		b.WriteByte(byte(c.y))
+		b.WriteByte(c.state)
+	}
+	return b.String()
+}
@@ -0,0 +1,101 @@
+package conway
+
+import (
+	"testing"
+)
+
+func TestCensus(t *testing.T) {
+	type cells [][2]uint16
+	block := cells{{0, 0}, {1, 0}, {0, 1}, {1, 1}}
+	blinker := cells{{0, 1}, {1, 1}, {2, 1}}
+	glider := cells{{1, 0}, {2, 1}, {0, 2}, {1, 2}, {2, 2}}
+	lwss := cells{{1, 0}, {4, 0}, {0, 1}, {0, 2}, {4, 2}, {0, 3}, {1, 3}, {2, 3}, {3, 3}}
+
+	// transform returns the cells rotated or reflected and moved to (x, y)
+	transform := func(cs cells, x, y uint16, f func(x, y uint16) (uint16, uint16)) cells {
+		moved := make(cells, len(cs))
+		for i, c := range cs {
+			cx, cy := f(c[0], c[1])
+			moved[i] = [2]uint16{(x + cx) % 64, (y + cy) % 64}
+		}
+		return moved
+	}
+	identity := func(x, y uint16) (uint16, uint16) { return x, y }
+	rotate := func(x, y uint16) (uint16, uint16) { return 10 - y, x }
+	flip := func(x, y uint16) (uint16, uint16) { return 10 - x, y }
+
+	tests := []struct {
+		name   string
+		cells  cells
+		code   string
+		kind   ObjectKind
+		period uint
+		speed  int // |dx| + |dy| per period
+	}{
+		{"block", transform(block, 5, 5, identity), "xs4_33", StillLife, 1, 0},
+		{"block across the edges", transform(block, 63, 63, identity), "xs4_33", StillLife, 1, 0},
+		{"blinker", transform(blinker, 20, 20, identity), "xp2_7", Oscillator, 2, 0},
+		{"rotated blinker", transform(blinker, 20, 20, rotate), "xp2_7", Oscillator, 2, 0},
+		{"glider", transform(glider, 30, 10, identity), "xq4_153", Spaceship, 4, 2},
+		{"rotated glider", transform(glider, 30, 10, rotate), "xq4_153", Spaceship, 4, 2},
+		{"flipped glider", transform(glider, 30, 10, flip), "xq4_153", Spaceship, 4, 2},
+		{"lightweight spaceship", transform(lwss, 12, 40, identity), "xq4_6frc", Spaceship, 4, 2},
+		{"flipped lightweight spaceship", transform(lwss, 12, 40, flip), "xq4_6frc", Spaceship, 4, 2},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := newTestConway(t, testConfig{"sparse", "channel-mix", "B3/S23", "torus", 64, 64})
+			for _, cell := range tt.cells {
+				c.SetCell(cell[0], cell[1], 0xffffff, 0, 0)
+			}
+
+			census := FindIslands(c, 64, 64).Census()
+			if census.Islands != 1 || len(census.Objects) != 1 {
+				t.Fatalf("expected one object, got %+v", census)
+			}
+			o := census.Objects[0]
+			if o.Code != tt.code || o.Kind != tt.kind || o.Period != tt.period || o.Count != 1 {
+				t.Fatalf("expected %s %s of period %d, got %+v", tt.kind, tt.code, tt.period, o)
+			}
+			if o.Name != lifeObjectNames[tt.code] {
+				t.Fatalf("expected the name %q, got %q", lifeObjectNames[tt.code], o.Name)
+			}
+			if speed := abs(o.DX) + abs(o.DY); speed != tt.speed {
+				t.Fatalf("expected a displacement of %d, got (%d, %d)", tt.speed, o.DX, o.DY)
+			}
+		})
+	}
+}
+
+func TestCensusCounts(t *testing.T) {
+	c := newTestConway(t, testConfig{"sparse", "channel-mix", "B3/S23", "torus", 64, 64})
+	set := func(x, y uint16, cs ...[2]uint16) {
+		for _, cell := range cs {
+			c.SetCell(x+cell[0], y+cell[1], 0xffffff, 0, 0)
+		}
+	}
+	block := [][2]uint16{{0, 0}, {1, 0}, {0, 1}, {1, 1}}
+	set(2, 2, block...)
+	set(20, 2, block...)
+	set(40, 40, block...)
+	set(2, 40, [2]uint16{0, 1}, [2]uint16{1, 1}, [2]uint16{2, 1})
+	// the R-pentomino settles only after 1103 generations
+	set(30, 20, [2]uint16{1, 0}, [2]uint16{2, 0}, [2]uint16{0, 1}, [2]uint16{1, 1}, [2]uint16{1, 2})
+
+	census := FindIslands(c, 64, 64).Census()
+	if census.Islands != 5 {
+		t.Fatalf("expected 5 islands, got %d", census.Islands)
+	}
+	expected := []struct {
+		code  string
+		count uint
+	}{{"xs4_33", 3}, {unsettledCode, 1}, {"xp2_7", 1}}
+	if len(census.Objects) != len(expected) {
+		t.Fatalf("expected %d objects, got %+v", len(expected), census.Objects)
+	}
+	for i, e := range expected {
+		if o := census.Objects[i]; o.Code != e.code || o.Count != e.count {
+			t.Fatalf("expected %d of %s at %d, got %+v", e.count, e.code, i, o)
+		}
+	}
+}
@@ -0,0 +1,153 @@
+package conway
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+const DefaultColourInheritance = "channel-mix"
+
+// defaultMutationRate is the mutation rate used when "mutation" is given without one.
+const defaultMutationRate = 0.01
+
+// maxParents is the number of alive neighbours a newborn cell inherits its colour
+// from, which covers the largest neighbourhood of range 1. In larger neighbourhoods
+// the first ones in the order of the neighbourhood are taken.
+const maxParents = 12
+
+// ColourInheritance decides the colour of a newborn cell from the colours of its alive
+// neighbours, which are given in the order of the neighbourhood. The colour must only
+// depend on the position of the cell and its parents, so that the same world always
+// evolves the same way and bands of cells can be computed concurrently.
+type ColourInheritance interface {
+	Inherit(x, y uint16, parents []uint32) uint32
+}
+
+// ParseColourInheritance parses the name of a colour inheritance strategy: one of
+// "channel-mix", "average", "dominant", "random-parent", "mutation", which may be
+// followed by its rate like "mutation:0.05", and "none".
+func ParseColourInheritance(s string) (ColourInheritance, error) {
+	name, arg, hasArg := strings.Cut(strings.ToLower(strings.TrimSpace(s)), ":")
+	if hasArg && name != "mutation" {
+		return nil, fmt.Errorf("colour inheritance %q takes no argument", name)
+	}
+	switch name {
+	case "channel-mix":
+		return channelMix{}, nil
+	case "average":
+		return average{}, nil
+	case "dominant":
+		return dominant{}, nil
+	case "random-parent":
+		return randomParent{}, nil
+	case "mutation":
+		rate := defaultMutationRate
+		if hasArg {
+			var err error
+			rate, err = strconv.ParseFloat(arg, 64)
+			if err != nil || rate < 0 || rate > 1 {
+				return nil, fmt.Errorf("mutation rate %q must be between 0 and 1", arg)
+			}
+		}
+		return mutation{rate}, nil
+	case "none":
+		return noInheritance{}, nil
+	}
+	return nil, fmt.Errorf("unknown colour inheritance %q", s)
+}
+
+// channelMix takes the red, green and blue channel from the first, second and third
+// parent. With fewer than three parents the channels are taken from the available
+// ones in turn.
+type channelMix struct{}
+
+func (channelMix) Inherit(_, _ uint16, parents []uint32) uint32 {
+	n := len(parents)
+	if n == 0 {
+		return 0xffffff
+	}
+	return (parents[0] & 0xff0000) | (parents[1%n] & 0x00ff00) | (parents[2%n] & 0x0000ff)
+}
+
+// average takes the mean of each channel over all parents.
+type average struct{}
+
+func (average) Inherit(_, _ uint16, parents []uint32) uint32 {
+	if len(parents) == 0 {
+		return 0xffffff
+	}
+	var r, g, b uint32
+	for _, p := range parents {
+		r += p >> 16 & 0xff
+		g += p >> 8 & 0xff
+		b += p & 0xff
+	}
+	n := uint32(len(parents))
+	return (r/n)<<16 | (g/n)<<8 | b/n
+}
+
+// dominant takes the colour most of the parents have, the first of them on a tie.
+type dominant struct{}
+
+func (dominant) Inherit(_, _ uint16, parents []uint32) uint32 {
+	if len(parents) == 0 {
+		return 0xffffff
+	}
+	colour, best := parents[0], 0
+	for i, p := range parents {
+		count := 0
+		for _, q := range parents[i:] {
+			if q == p {
+				count += 1
+			}
+		}
+		if count > best {
+			colour, best = p, count
+		}
+	}
+	return colour
+}
+
+// randomParent takes the colour of one of the parents.
+type randomParent struct{}
+
+func (randomParent) Inherit(x, y uint16, parents []uint32) uint32 {
+	if len(parents) == 0 {
+		return 0xffffff
+	}
+	return parents[birthHash(x, y, parents)%uint64(len(parents))]
+}
+
+// mutation mixes the channels of the parents, but replaces one channel by a random
+// value for the given share of newborn cells.
+type mutation struct {
+	rate float64
+}
+
+func (m mutation) Inherit(x, y uint16, parents []uint32) uint32 {
+	colour := channelMix{}.Inherit(x, y, parents)
+	v := birthHash(x, y, parents)
+	if float64(v>>11)/(1<<53) >= m.rate {
+		return colour
+	}
+	v = splitMix(v)
+	shift := 8 * (v % 3)
+	return colour&^(0xff<<shift) | uint32(v>>8&0xff)<<shift
+}
+
+// noInheritance makes all newborn cells white.
+type noInheritance struct{}
+
+func (noInheritance) Inherit(_, _ uint16, _ []uint32) uint32 {
+	return 0xffffff
+}
+
+// birthHash derives a pseudo random number from a newborn cell and its parents.
+func birthHash(x, y uint16, parents []uint32) uint64 {
+	v := splitMix(uint64(x)<<16 | uint64(y))
+	for _, p := range parents {
+		v = splitMix(v ^ uint64(p))
+	}
+	return v
+}
@@ -0,0 +1,116 @@
+package conway
+
+import (
+	"maps"
+	"slices"
+	"testing"
+)
+
+func TestParseColourInheritance(t *testing.T) {
+	tests := []struct {
+		s        string
+		expected ColourInheritance
+		err      bool
+	}{
+		{s: "channel-mix", expected: channelMix{}},
+		{s: " Average ", expected: average{}},
+		{s: "dominant", expected: dominant{}},
+		{s: "random-parent", expected: randomParent{}},
+		{s: "mutation", expected: mutation{defaultMutationRate}},
+		{s: "mutation:0.25", expected: mutation{0.25}},
+		{s: "none", expected: noInheritance{}},
+		{s: "mutation:2", err: true},
+		{s: "average:1", err: true},
+		{s: "rainbow", err: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.s, func(t *testing.T) {
+			ci, err := ParseColourInheritance(tt.s)
+			if tt.err {
+				if err == nil {
+					t.Fatalf("expected an error, got %v", ci)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if ci != tt.expected {
+				t.Fatalf("expected %v, got %v", tt.expected, ci)
+			}
+		})
+	}
+}
+
+func TestInherit(t *testing.T) {
+	tests := []struct {
+		name        string
+		inheritance ColourInheritance
+		parents     []uint32
+		expected    uint32
+	}{
+		{"channel-mix", channelMix{}, []uint32{0x112233, 0x445566, 0x778899}, 0x115599},
+		{"channel-mix of two parents", channelMix{}, []uint32{0x112233, 0x445566}, 0x115533},
+		{"channel-mix without parents", channelMix{}, nil, 0xffffff},
+		{"average", average{}, []uint32{0x102030, 0x302010}, 0x202020},
+		{"dominant", dominant{}, []uint32{0x0000ff, 0xff0000, 0xff0000}, 0xff0000},
+		{"dominant on a tie", dominant{}, []uint32{0x0000ff, 0xff0000}, 0x0000ff},
+		{"none", noInheritance{}, []uint32{0xff0000, 0xff0000, 0xff0000}, 0xffffff},
+		{"mutation never", mutation{0}, []uint32{0x112233, 0x445566, 0x778899}, 0x115599},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if colour := tt.inheritance.Inherit(3, 4, tt.parents); colour != tt.expected {
+				t.Fatalf("expected %06x, got %06x", tt.expected, colour)
+			}
+		})
+	}
+}
+
+func TestRandomInheritanceIsDeterministic(t *testing.T) {
+	parents := []uint32{0xff0000, 0x00ff00, 0x0000ff}
+	randomParent, mutation, mix := randomParent{}, mutation{1}, channelMix{}
+	for x := range uint16(50) {
+		colour := randomParent.Inherit(x, 7, parents)
+		if !slices.Contains(parents, colour) {
+			t.Fatalf("random-parent inherited %06x, which no parent has", colour)
+		}
+		if randomParent.Inherit(x, 7, parents) != colour {
+			t.Fatal("random-parent inherited different colours for the same cell")
+		}
+
+		mutated := mutation.Inherit(x, 7, parents)
+		if mutation.Inherit(x, 7, parents) != mutated {
+			t.Fatal("mutation inherited different colours for the same cell")
+		}
+		// at most one channel differs from the mix
+		diff, channels := mutated^mix.Inherit(x, 7, parents), 0
+		for _, mask := range []uint32{0xff0000, 0x00ff00, 0x0000ff} {
+			if diff&mask != 0 {
+				channels += 1
+			}
+		}
+		if channels > 1 {
+			t.Fatalf("mutation changed %d channels: %06x", channels, mutated)
+		}
+	}
+}
+
+func TestInheritedColoursAreReproducible(t *testing.T) {
+	for _, name := range []string{"channel-mix", "average", "dominant", "random-parent", "mutation:0.3"} {
+		t.Run(name, func(t *testing.T) {
+			var worlds []map[uint32]aliveCell
+			for _, backend := range []string{"sparse", "sparse", "bitpacked"} {
+				c := newTestConway(t, testConfig{backend, name, "B3/S23", "torus", 64, 64})
+				c.Randomise(Soup{Seed: 9, Density: 0.4, Palette: []uint32{0xff0000, 0x00ff00, 0x0000ff, 0xffff00}})
+				c.Advance(30)
+				worlds = append(worlds, cellsByPosition(c))
+			}
+			for _, w := range worlds[1:] {
+				if !maps.Equal(worlds[0], w) {
+					t.Fatal("the same soup evolved different colours")
+				}
+			}
+		})
+	}
+}
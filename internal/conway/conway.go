@@ -1,51 +1,266 @@
 package conway
 
 import (
+	"fmt"
 	"iter"
+	"maps"
 	"math"
-	"math/rand/v2"
+	"runtime"
+	"slices"
 )
 
+// maxWorldLength is the largest width or height of a world, as coordinates are 16 bit.
+const maxWorldLength = math.MaxUint16
+
+const DefaultBackend = "auto"
+
 type ConwayConfig interface {
-	WorldSize() uint
+	Backend() string
+	ColourInheritance() string
+	Rule() string
+	Topology() string
+	WorldHeight() uint
+	WorldWidth() uint
 }
 
 type Conway interface {
+	Advance(generations uint64)
 	CanSetCell(x, y uint16) bool
 	Cells() iter.Seq2[uint, Cell]
+	// CellAt returns the cell at (x, y), or false if there is none.
+	CellAt(x, y uint16) (Cell, bool)
+	// Changes returns the number of cells born and died in the last generation that
+	// was computed, or 0 if the world was changed otherwise since.
+	Changes() (births, deaths uint)
 	CellsCount() uint
 	Clear()
+	// ClearRegion kills all cells of the rectangle, which is clipped at the edges of
+	// the world.
+	ClearRegion(x, y, width, height uint16)
+	// KillCell kills the cell at (x, y), if there is one.
+	KillCell(x, y uint16)
 	NextGen()
-	Randomise()
-	SetCell(x, y uint16, colour uint32, age uint16)
+	Randomise(s Soup)
+	Rule() Rule
+	SetCell(x, y uint16, colour uint32, age uint16, state uint8)
+	SetTopology(t Topology) error
+	Topology() Topology
+}
+
+// CellChange is a cell that a generation changed, as it was before, or the position
+// of a cell that was born.
+type CellChange struct {
+	X, Y   uint16
+	Colour uint32
+	Age    uint16
+	State  uint8
+	Born   bool
+}
+
+// ChangeRecorder is implemented by the backends that can collect the cells a generation
+// changes while computing it, which is much cheaper than comparing the generations.
+// Cells that merely aged are not collected.
+type ChangeRecorder interface {
+	// RecordChanges turns collecting the changes of NextGen on or off.
+	RecordChanges(on bool)
+	// Recording reports whether the changes of the next generation will be recorded.
+	Recording() bool
+	// RecordedChanges returns the changes of the last generation that was computed, in
+	// no particular order. It returns false if none were recorded or the world was
+	// changed otherwise since.
+	RecordedChanges() ([]CellChange, bool)
+}
+
+// bandRecorder implements ChangeRecorder for the backends that compute a generation in
+// bands of forEachBand. Each band collects its changes on its own.
+type bandRecorder struct {
+	record     bool
+	bands      [][]CellChange // of the generation being computed
+	recorded   []CellChange   // of the last generation
+	recordedOK bool
+}
+
+func (r *bandRecorder) RecordChanges(on bool) {
+	r.record = on
+	r.recordedOK = false
+}
+
+func (r *bandRecorder) Recording() bool {
+	return r.record
+}
+
+func (r *bandRecorder) RecordedChanges() ([]CellChange, bool) {
+	return r.recorded, r.recordedOK
+}
+
+// begin empties the changes of the bands before a generation is computed.
+func (r *bandRecorder) begin() {
+	if n := runtime.GOMAXPROCS(0); len(r.bands) < n {
+		r.bands = append(r.bands, make([][]CellChange, n-len(r.bands))...)
+	}
+	for i := range r.bands {
+		r.bands[i] = r.bands[i][:0]
+	}
+}
+
+// add collects the cell as it was before the generation being computed, if changes
+// are recorded.
+func (r *bandRecorder) add(band int, c CellChange) {
+	if r.record {
+		r.bands[band] = append(r.bands[band], c)
+	}
+}
+
+// finish joins the changes of the bands once the generation was computed.
+func (r *bandRecorder) finish() {
+	r.recorded = r.recorded[:0]
+	for _, b := range r.bands {
+		r.recorded = append(r.recorded, b...)
+	}
+	r.recordedOK = r.record
+}
+
+// forget drops the changes of the last generation, as the world was changed by other
+// means than a step.
+func (r *bandRecorder) forget() {
+	r.recordedOK = false
 }
 
 type conway struct {
-	axisLength uint
-	wrapMask   uint16
-	aliveCells *swapSet[aliveCell]
-	candidates *swapSet[struct{}]
+	width       int
+	height      int
+	rule        Rule
+	topology    Topology
+	inheritance ColourInheritance
+	neighbours  [2][]offset // by parity of the cell
+	aliveCells  *swapSet[aliveCell]
+	candidates  *swapSet[struct{}]
+
+	keys    []uint32     // candidates of the generation being computed
+	bands   []sparseBand // results of the generation being computed
+	changes changes
+	bandRecorder
 }
 
-func NewConway(cfg ConwayConfig) Conway {
-	axisLength := cfg.WorldSize()
+// sparseBand collects the cells and candidates of the next generation found by one
+// band of candidates.
+type sparseBand struct {
+	cells      []aliveCell
+	candidates []uint32
+	changes    changes
+}
+
+func NewConway(cfg ConwayConfig) (Conway, error) {
+	width, height := cfg.WorldWidth(), cfg.WorldHeight()
+	if width == 0 || height == 0 || width > maxWorldLength || height > maxWorldLength {
+		return nil, fmt.Errorf("world size %dx%d must be between 1x1 and %dx%d", width, height, maxWorldLength, maxWorldLength)
+	}
+	rule, err := LoadRule(cfg.Rule())
+	if err != nil {
+		return nil, err
+	}
+	topology, err := ParseTopology(cfg.Topology())
+	if err != nil {
+		return nil, err
+	}
+	inheritance, err := ParseColourInheritance(cfg.ColourInheritance())
+	if err != nil {
+		return nil, err
+	}
+
+	if err := rule.checkWorldSize(int(width), int(height)); err != nil {
+		return nil, err
+	}
 
+	w, h := int(width), int(height)
+	switch cfg.Backend() {
+	case "auto":
+		switch {
+		case rule.table != nil:
+			return newMultiState(w, h, rule, topology), nil
+		case !rule.isLifeLike():
+			return newLargerThanLife(w, h, rule, topology, inheritance), nil
+		case rule.bitPackable():
+			return newAdaptive(w, h,
+				func() Conway { return newSparse(w, h, rule, topology, inheritance) },
+				func() Conway { return newBitPacked(w, h, rule, topology, inheritance) },
+			), nil
+		}
+		return newSparse(w, h, rule, topology, inheritance), nil
+	case "sparse":
+		if !rule.isLifeLike() {
+			return nil, fmt.Errorf("the sparse backend does not support rule %s", rule)
+		}
+		return newSparse(w, h, rule, topology, inheritance), nil
+	case "bitpacked":
+		if !rule.bitPackable() {
+			return nil, fmt.Errorf("the bitpacked backend does not support rule %s", rule)
+		}
+		return newBitPacked(w, h, rule, topology, inheritance), nil
+	case "multistate":
+		if rule.table == nil {
+			return nil, fmt.Errorf("the multistate backend only supports rule tables, not rule %s", rule)
+		}
+		return newMultiState(w, h, rule, topology), nil
+	case "hashlife":
+		if _, ok := inheritance.(noInheritance); !ok {
+			return nil, fmt.Errorf("the hashlife backend keeps no colours, use colour inheritance \"none\" instead of %q", cfg.ColourInheritance())
+		}
+		hl, err := newHashLife(w, h, rule, topology)
+		if err != nil {
+			return nil, err
+		}
+		return hl, nil
+	}
+	return nil, fmt.Errorf("unknown backend %q", cfg.Backend())
+}
+
+func newSparse(width, height int, rule Rule, topology Topology, inheritance ColourInheritance) *conway {
 	return &conway{
-		axisLength: axisLength,
-		wrapMask:   uint16(axisLength) - 1,
-		aliveCells: newSwapSet[aliveCell](axisLength),
-		candidates: newSwapSet[struct{}](axisLength),
+		width:       width,
+		height:      height,
+		rule:        rule,
+		topology:    topology,
+		inheritance: inheritance,
+		neighbours:  [2][]offset{rule.offsets(0), rule.offsets(1)},
+		aliveCells:  newSwapSet[aliveCell](uint(max(width, height))),
+		candidates:  newSwapSet[struct{}](uint(max(width, height))),
+	}
+}
+
+func (c *conway) Advance(generations uint64) {
+	for range generations {
+		c.NextGen()
 	}
 }
 
 func (c *conway) CanSetCell(x, y uint16) bool {
+	if int(x) >= c.width || int(y) >= c.height {
+		return false
+	}
 	_, ok := c.aliveCells.get(x, y)
 	return !ok
 }
 
+func (c *conway) Changes() (uint, uint) {
+	return c.changes.births, c.changes.deaths
+}
+
 func (c *conway) Clear() {
 	c.aliveCells.clearAll()
 	c.candidates.clearAll()
+	c.edited()
+}
+
+func (c *conway) ClearRegion(x, y, width, height uint16) {
+	c.edited()
+	x1, y1 := int(x)+int(width), int(y)+int(height)
+	for _, ac := range c.aliveCells.values() {
+		if ac.x >= x && int(ac.x) < x1 && ac.y >= y && int(ac.y) < y1 {
+			c.aliveCells.remove(ac.x, ac.y)
+			c.addCandidates(ac.x, ac.y)
+		}
+	}
 }
 
 func (c *conway) Cells() iter.Seq2[uint, Cell] {
@@ -60,68 +275,75 @@ func (c *conway) Cells() iter.Seq2[uint, Cell] {
 	}
 }
 
+func (c *conway) CellAt(x, y uint16) (Cell, bool) {
+	ac, ok := c.aliveCells.get(x, y)
+	if !ok {
+		return nil, false
+	}
+	return &ac, true
+}
+
 func (c *conway) CellsCount() uint {
 	return uint(c.aliveCells.size())
 }
 
+func (c *conway) KillCell(x, y uint16) {
+	c.edited()
+	if _, ok := c.aliveCells.get(x, y); ok {
+		c.aliveCells.remove(x, y)
+		// the neighbours may be born or die without the cell
+		c.addCandidates(x, y)
+	}
+}
+
 func (c *conway) NextGen() {
 	c.aliveCells.clearNext()
 	c.candidates.clearNext()
-	for key := range c.aliveCells.values() {
-		c.candidates.addNextByKey(key, struct{}{})
-	}
 
-	var aliveNeighbours [8]aliveCell
-	anIdx := 0
-	findAlive := func(x, y uint16) int {
-		if ac, ok := c.aliveCells.get(x, y); ok {
-			aliveNeighbours[anIdx] = ac
-			anIdx += 1
-			return 1
-		}
-		return 0
+	if n := runtime.GOMAXPROCS(0); len(c.bands) < n {
+		c.bands = make([]sparseBand, n)
 	}
+	for i := range c.bands {
+		c.bands[i].cells = c.bands[i].cells[:0]
+		c.bands[i].candidates = c.bands[i].candidates[:0]
+		c.bands[i].changes = changes{}
+	}
+	c.begin()
 
-	for x, y := range c.candidates.iter() {
-		xLeft, xRight, yUp, yDown := c.getAdjacent(x, y)
-
-		anIdx = 0
-		numNeighbours := findAlive(xLeft, yUp) +
-			findAlive(x, yUp) +
-			findAlive(xRight, yUp) +
-			findAlive(xLeft, y) +
-			findAlive(xRight, y) +
-			findAlive(xLeft, yDown) +
-			findAlive(x, yDown) +
-			findAlive(xRight, yDown)
-
-		ac, alive := c.aliveCells.get(x, y)
-
-		addCands := false
-		if alive {
-			if numNeighbours == 2 || numNeighbours == 3 {
-				if ac.age < math.MaxUint16 {
-					ac.age += 1
+	if c.rule.bornOnZero() {
+		// any dead cell of the world can be born, not just the neighbours of alive cells
+		forEachBand(c.width, c.height, func(band, from, to int) uint {
+			for y := from; y < to; y++ {
+				for x := range c.width {
+					c.step(uint16(x), uint16(y), band)
 				}
-				c.aliveCells.addNext(x, y, ac)
-			} else {
-				addCands = true
 			}
-		} else if numNeighbours == 3 {
-			colour := (aliveNeighbours[0].colour & 0xff0000) | (aliveNeighbours[1].colour & 0x00ff00) | (aliveNeighbours[2].colour & 0x0000ff)
-			c.aliveCells.addNext(x, y, aliveCell{x, y, colour, 0})
-			addCands = true
+			return 0
+		})
+	} else {
+		for key := range c.aliveCells.values() {
+			c.candidates.addNextByKey(key, struct{}{})
 		}
-		if addCands {
-			c.candidates.addNext(xLeft, yUp, struct{}{})
-			c.candidates.addNext(x, yUp, struct{}{})
-			c.candidates.addNext(xRight, yUp, struct{}{})
-			c.candidates.addNext(xLeft, y, struct{}{})
-			c.candidates.addNext(x, y, struct{}{})
-			c.candidates.addNext(xRight, y, struct{}{})
-			c.candidates.addNext(xLeft, yDown, struct{}{})
-			c.candidates.addNext(x, yDown, struct{}{})
-			c.candidates.addNext(xRight, yDown, struct{}{})
+		// the candidates are split as if they were the rows of a world one cell wide
+		c.keys = slices.AppendSeq(c.keys[:0], maps.Keys(c.candidates.values()))
+		forEachBand(1, len(c.keys), func(band, from, to int) uint {
+			for _, key := range c.keys[from:to] {
+				c.step(uint16(key>>16), uint16(key), band)
+			}
+			return 0
+		})
+	}
+
+	c.changes = changes{}
+	c.finish()
+	for _, band := range c.bands {
+		c.changes.births += band.changes.births
+		c.changes.deaths += band.changes.deaths
+		for _, ac := range band.cells {
+			c.aliveCells.addNext(ac.x, ac.y, ac)
+		}
+		for _, key := range band.candidates {
+			c.candidates.addNextByKey(key, struct{}{})
 		}
 	}
 
@@ -129,44 +351,124 @@ func (c *conway) NextGen() {
 	c.candidates.swap()
 }
 
-func (c *conway) Randomise() {
-	c.Clear()
+func (c *conway) Randomise(s Soup) {
+	s.fill(c, c.width, c.height, 1)
+}
 
-	al := uint16(c.axisLength)
-	for x := range al {
-		for y := range al {
-			if rand.UintN(2) != 1 {
-				continue
-			}
-			colour := rand.Uint32N(0xffffff) + 1
-			c.aliveCells.add(x, y, aliveCell{x, y, colour, 0})
-			c.addCandidates(x, y)
-		}
-	}
+func (c *conway) Rule() Rule {
+	return c.rule
 }
 
-func (c *conway) SetCell(x, y uint16, colour uint32, age uint16) {
-	c.aliveCells.add(x, y, aliveCell{x, y, colour, age})
+func (c *conway) SetCell(x, y uint16, colour uint32, age uint16, state uint8) {
+	c.edited()
+	c.aliveCells.add(x, y, aliveCell{x, y, colour, age, min(state, c.rule.maxStage())})
 	c.addCandidates(x, y)
 }
 
+func (c *conway) SetTopology(t Topology) error {
+	c.topology = t
+	// the neighbours of cells at the edges have changed
+	c.candidates.clearAll()
+	for _, ac := range c.aliveCells.values() {
+		c.addCandidates(ac.x, ac.y)
+	}
+	return nil
+}
+
+func (c *conway) Topology() Topology {
+	return c.topology
+}
+
+// edited forgets the changes of the last generation, as the world was changed by other
+// means than a step.
+func (c *conway) edited() {
+	c.changes = changes{}
+	c.forget()
+}
+
 func (c *conway) addCandidates(x, y uint16) {
-	xLeft, xRight, yUp, yDown := c.getAdjacent(x, y)
-	c.candidates.add(xLeft, yUp, struct{}{})
-	c.candidates.add(x, yUp, struct{}{})
-	c.candidates.add(xRight, yUp, struct{}{})
-	c.candidates.add(xLeft, y, struct{}{})
 	c.candidates.add(x, y, struct{}{})
-	c.candidates.add(xRight, y, struct{}{})
-	c.candidates.add(xLeft, yDown, struct{}{})
-	c.candidates.add(x, yDown, struct{}{})
-	c.candidates.add(xRight, yDown, struct{}{})
-}
-
-func (c *conway) getAdjacent(x, y uint16) (xLeft, xRight, yUp, yDown uint16) {
-	xLeft = (x - 1) & c.wrapMask
-	xRight = (x + 1) & c.wrapMask
-	yUp = (y - 1) & c.wrapMask
-	yDown = (y + 1) & c.wrapMask
-	return
+	for _, o := range c.neighbours[c.rule.parity(int(x), int(y))] {
+		if nx, ny, ok := c.neighbour(x, y, o); ok {
+			c.candidates.add(nx, ny, struct{}{})
+		}
+	}
+}
+
+// neighbour returns the coordinates of the neighbour of (x, y) at offset o. It returns
+// false if the neighbour lies beyond a dead edge of the world.
+func (c *conway) neighbour(x, y uint16, o offset) (uint16, uint16, bool) {
+	nx, ny := int(x)+o.dx, int(y)+o.dy
+	if uint(nx) < uint(c.width) && uint(ny) < uint(c.height) {
+		return uint16(nx), uint16(ny), true
+	}
+	nx, ny, ok := c.topology.wrap(nx, ny, c.width, c.height)
+	return uint16(nx), uint16(ny), ok
+}
+
+// step computes the next generation of the cell at (x, y) and collects the result in
+// the band. It only reads the current generation, so bands can step concurrently.
+func (c *conway) step(x, y uint16, b int) {
+	band := &c.bands[b]
+	neighbours := c.neighbours[c.rule.parity(int(x), int(y))]
+
+	var parents [maxParents]uint32
+	numNeighbours := 0
+	for _, o := range neighbours {
+		if nx, ny, ok := c.neighbour(x, y, o); ok {
+			if ac, ok := c.aliveCells.get(nx, ny); ok && ac.state == 0 {
+				parents[numNeighbours] = ac.colour
+				numNeighbours += 1
+			}
+		}
+	}
+
+	ac, occupied := c.aliveCells.get(x, y)
+
+	addCands := false
+	switch {
+	case occupied && ac.state == 0:
+		if c.rule.survival[numNeighbours] {
+			if ac.age < math.MaxUint16 {
+				ac.age += 1
+			}
+			band.cells = append(band.cells, ac)
+		} else {
+			band.changes.deaths += 1
+			c.add(b, changeOf(ac, false))
+			if c.rule.maxStage() > 0 {
+				ac.state = 1
+				band.cells = append(band.cells, ac)
+			}
+			addCands = true
+		}
+	case occupied:
+		// decaying cells do not count as neighbours, so only the cell itself is affected
+		c.add(b, changeOf(ac, false))
+		if ac.state < c.rule.maxStage() {
+			ac.state += 1
+			band.cells = append(band.cells, ac)
+		} else {
+			band.candidates = append(band.candidates, toCoord(x, y))
+		}
+	case c.rule.birth[numNeighbours]:
+		colour := c.inheritance.Inherit(x, y, parents[:numNeighbours])
+		band.cells = append(band.cells, aliveCell{x, y, colour, 0, 0})
+		band.changes.births += 1
+		c.add(b, changeOf(aliveCell{x: x, y: y}, true))
+		addCands = true
+	}
+	if addCands {
+		band.candidates = append(band.candidates, toCoord(x, y))
+		for _, o := range neighbours {
+			if nx, ny, ok := c.neighbour(x, y, o); ok {
+				band.candidates = append(band.candidates, toCoord(nx, ny))
+			}
+		}
+	}
+}
+
+// changeOf returns the change of the cell, or of its position if it was born.
+func changeOf(ac aliveCell, born bool) CellChange {
+	return CellChange{ac.x, ac.y, ac.colour, ac.age, ac.state, born}
 }
@@ -0,0 +1,436 @@
+package conway
+
+import (
+	"maps"
+	"testing"
+)
+
+type testConfig struct {
+	backend     string
+	inheritance string
+	rule        string
+	topology    string
+	width       uint
+	height      uint
+}
+
+func (c testConfig) Backend() string           { return c.backend }
+func (c testConfig) ColourInheritance() string { return c.inheritance }
+func (c testConfig) Rule() string              { return c.rule }
+func (c testConfig) Topology() string          { return c.topology }
+func (c testConfig) WorldHeight() uint         { return c.height }
+func (c testConfig) WorldWidth() uint          { return c.width }
+
+func TestNewConwayRejectsUnsupportedHashLife(t *testing.T) {
+	tests := []struct {
+		name string
+		cfg  testConfig
+	}{
+		{"not square", testConfig{"hashlife", "none", "B3/S23", "torus", 64, 32}},
+		{"not a power of two", testConfig{"hashlife", "none", "B3/S23", "torus", 48, 48}},
+		{"plane", testConfig{"hashlife", "none", "B3/S23", "plane", 64, 64}},
+		{"B0", testConfig{"hashlife", "none", "B03/S23", "torus", 64, 64}},
+		{"colour inheritance", testConfig{"hashlife", "channel-mix", "B3/S23", "torus", 64, 64}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c, err := NewConway(tt.cfg)
+			if err == nil {
+				t.Fatal("expected an error")
+			}
+			if c != nil {
+				t.Fatalf("expected a nil Conway, got %T", c)
+			}
+		})
+	}
+
+	if _, err := NewConway(testConfig{"hashlife", "none", "B3/S23", "torus", 64, 64}); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestRecordedChanges(t *testing.T) {
+	tests := []struct {
+		name    string
+		backend string
+		rule    string
+		density float64
+	}{
+		{"sparse", "sparse", "B3/S23", 0.3},
+		{"sparse generations", "sparse", "B2/S/C4", 0.3},
+		{"adaptive", "auto", "B3/S23", 0.0005},
+		{"adaptive dense", "auto", "B3/S23", 0.3},
+		{"bit-packed", "bitpacked", "B3/S23", 0.3},
+		{"bit-packed generations", "bitpacked", "B2/S/C4", 0.3},
+		{"larger than life", "auto", "R3,C0,M1,S9..18,B10..14,NM", 0.4},
+		{"larger than life generations", "auto", "R2,C3,M0,S3..6,B4..6,NN", 0.4},
+		{"rule table", "multistate", "WireWorld", 0.3},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c, err := NewConway(testConfig{tt.backend, "channel-mix", tt.rule, "torus", 256, 256})
+			if err != nil {
+				t.Fatal(err)
+			}
+			r := c.(ChangeRecorder)
+			r.RecordChanges(true)
+			c.Randomise(Soup{Seed: 1, Density: tt.density, Width: 64, Height: 64})
+			if _, ok := r.RecordedChanges(); ok {
+				t.Fatal("recorded changes after randomising")
+			}
+
+			for gen := range 50 {
+				before := cellsByPosition(c)
+				c.NextGen()
+				recorded, ok := r.RecordedChanges()
+				if !ok {
+					t.Fatalf("generation %d: no recorded changes", gen)
+				}
+				after := cellsByPosition(c)
+
+				expected := map[CellChange]bool{}
+				for pos, cell := range after {
+					if _, ok := before[pos]; !ok {
+						expected[CellChange{X: cell.x, Y: cell.y, Born: true}] = true
+					}
+				}
+				for pos, cell := range before {
+					if next, ok := after[pos]; !ok || next.state != cell.state {
+						expected[CellChange{cell.x, cell.y, cell.colour, cell.age, cell.state, false}] = true
+					}
+				}
+				if len(recorded) != len(expected) {
+					t.Fatalf("generation %d: recorded %d changes, expected %d", gen, len(recorded), len(expected))
+				}
+				for _, rc := range recorded {
+					if !expected[rc] {
+						t.Fatalf("generation %d: unexpected change %+v", gen, rc)
+					}
+				}
+			}
+		})
+	}
+}
+
+func cellsByPosition(c Conway) map[uint32]aliveCell {
+	cells := map[uint32]aliveCell{}
+	for _, cell := range c.Cells() {
+		x, y, colour, age := cell.Values()
+		cells[toCoord(x, y)] = aliveCell{x, y, colour, age, cell.State()}
+	}
+	return cells
+}
+
+// newTestConway creates a world of the backend or fails the test.
+func newTestConway(t *testing.T, cfg testConfig) Conway {
+	t.Helper()
+	c, err := NewConway(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return c
+}
+
+// positions returns the states of the cells by position, which all backends agree on.
+func positions(c Conway) map[uint32]uint8 {
+	ps := map[uint32]uint8{}
+	for _, cell := range c.Cells() {
+		x, y, _, _ := cell.Values()
+		ps[toCoord(x, y)] = cell.State()
+	}
+	return ps
+}
+
+func TestCellAt(t *testing.T) {
+	configs := []testConfig{
+		{"sparse", "channel-mix", "B2/S/C4", "torus", 64, 64},
+		{"bitpacked", "channel-mix", "B2/S/C4", "torus", 64, 64},
+		{"hashlife", "none", "B3/S23", "torus", 64, 64},
+		{"auto", "channel-mix", "R2,C3,M0,S3..6,B4..6,NN", "torus", 64, 64},
+		{"multistate", "channel-mix", "WireWorld", "torus", 64, 64},
+	}
+	for _, cfg := range configs {
+		t.Run(cfg.backend, func(t *testing.T) {
+			c := newTestConway(t, cfg)
+			c.Randomise(Soup{Seed: 5, Density: 0.4})
+			c.Advance(3)
+			cells := cellsByPosition(c)
+			for y := range uint16(65) {
+				for x := range uint16(65) {
+					cell, ok := c.CellAt(x, y)
+					expected, exists := cells[toCoord(x, y)]
+					if ok != exists {
+						t.Fatalf("(%d, %d): expected a cell %t, got %t", x, y, exists, ok)
+					}
+					if !ok {
+						continue
+					}
+					cx, cy, colour, age := cell.Values()
+					if got := (aliveCell{cx, cy, colour, age, cell.State()}); got != expected {
+						t.Fatalf("(%d, %d): expected %+v, got %+v", x, y, expected, got)
+					}
+				}
+			}
+		})
+	}
+}
+
+func TestParseRule(t *testing.T) {
+	tests := []struct {
+		rule     string
+		expected string // the canonical notation, or empty if the rule is invalid
+	}{
+		{"B3/S23", "B3/S23"},
+		{"b36/s23", "B36/S23"},
+		{"S23/B3", "B3/S23"},
+		{"23/3", "B3/S23"},
+		{" B2/S ", "B2/S"},
+		{"B0/S8", "B0/S8"},
+		{"B2/S/C3", "B2/S/C3"},
+		{"/2/3", "B2/S/C3"},
+		{"345/2/4", "B2/S345/C4"},
+		{"B2/S34H", "B2/S34H"},
+		{"B2/S3AT", "B2/S3AT"},
+		{"B1/S1V", "B1/S1V"},
+		{"R5,C0,M1,S34..58,B34..45,NM", "R5,C0,M1,S34..58,B34..45,NM"},
+		{"R2,C3,M0,S3-6,B4,5,6,NN", "R2,C3,M0,S3..6,B4..6,NN"},
+		{"R1,C0,M0,S2..3,B3,NM", "B3/S23"},
+		{"WireWorld", "WireWorld"},
+		{"wireworld", "WireWorld"},
+		{"B9/S23", ""},
+		{"B3S23x", ""},
+		{"B3/S23/C1", ""},
+		{"B3/S23/4/5", ""},
+		{"B5/S23V", ""},
+		{"B7/S23H", ""},
+		{"BD/S23T", ""},
+		{"R11,C0,M0,S1,B1,NM", ""},
+		{"R2,C0,M0,S2..30,B3,NM", ""},
+		{"R2,C0,M0,S2,B3,NX", ""},
+		{"C0,M0,S2,B3,NM", ""},
+		{"NotARuleTable", ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.rule, func(t *testing.T) {
+			r, err := ParseRule(tt.rule)
+			if tt.expected == "" {
+				if err == nil {
+					t.Fatalf("expected an error, got %s", r)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := r.String(); got != tt.expected {
+				t.Fatalf("expected %s, got %s", tt.expected, got)
+			}
+			again, err := ParseRule(r.String())
+			if err != nil {
+				t.Fatalf("cannot parse %s again: %v", r, err)
+			}
+			if again.String() != r.String() {
+				t.Fatalf("%s turned into %s", r, again)
+			}
+		})
+	}
+}
+
+func TestRulesEvolveDifferently(t *testing.T) {
+	t.Run("HighLife replicator", func(t *testing.T) {
+		replicator := []offset{{2, 0}, {3, 0}, {4, 0}, {1, 1}, {4, 1}, {0, 2}, {4, 2}, {0, 3}, {3, 3}, {0, 4}, {1, 4}, {2, 4}}
+		// after 12 generations, there are two transposed copies on its diagonal
+		expected := map[uint32]uint8{}
+		for _, o := range replicator {
+			expected[toCoord(uint16(28+o.dy), uint16(28+o.dx))] = 0
+			expected[toCoord(uint16(32+o.dy), uint16(32+o.dx))] = 0
+		}
+
+		for _, rule := range []string{"B36/S23", "B3/S23"} {
+			c := newTestConway(t, testConfig{"sparse", "none", rule, "plane", 64, 64})
+			for _, o := range replicator {
+				c.SetCell(uint16(30+o.dx), uint16(30+o.dy), 0, 0, 0)
+			}
+			c.Advance(12)
+			replicates := rule == "B36/S23"
+			if maps.Equal(positions(c), expected) != replicates {
+				t.Fatalf("%s: expected the replicator to replicate: %t", rule, replicates)
+			}
+		}
+	})
+
+	t.Run("Seeds", func(t *testing.T) {
+		// a domino dies in Life, but turns into two dominoes above and below it in Seeds
+		for _, tt := range []struct {
+			rule     string
+			expected map[uint32]uint8
+		}{
+			{"B2/S", map[uint32]uint8{toCoord(10, 9): 0, toCoord(11, 9): 0, toCoord(10, 11): 0, toCoord(11, 11): 0}},
+			{"B3/S23", map[uint32]uint8{}},
+		} {
+			c := newTestConway(t, testConfig{"sparse", "none", tt.rule, "plane", 32, 32})
+			c.SetCell(10, 10, 0, 0, 0)
+			c.SetCell(11, 10, 0, 0, 0)
+			c.NextGen()
+			if got := positions(c); !maps.Equal(got, tt.expected) {
+				t.Fatalf("%s: expected %v, got %v", tt.rule, tt.expected, got)
+			}
+		}
+	})
+}
+
+func TestBriansBrain(t *testing.T) {
+	for _, backend := range []string{"sparse", "bitpacked"} {
+		t.Run(backend, func(t *testing.T) {
+			c := newTestConway(t, testConfig{backend, "none", "B2/S/C3", "plane", 32, 32})
+			c.SetCell(10, 10, 0, 0, 0)
+			c.SetCell(11, 10, 0, 0, 0)
+
+			generations := []map[uint32]uint8{
+				// the domino starts to decay and gives birth above and below it
+				{
+					toCoord(10, 10): 1, toCoord(11, 10): 1,
+					toCoord(10, 9): 0, toCoord(11, 9): 0, toCoord(10, 11): 0, toCoord(11, 11): 0,
+				},
+				// the decaying cells vanish, and the cells left and right of them are born,
+				// which they would have prevented if they still counted as neighbours
+				{
+					toCoord(10, 9): 1, toCoord(11, 9): 1, toCoord(10, 11): 1, toCoord(11, 11): 1,
+					toCoord(10, 8): 0, toCoord(11, 8): 0, toCoord(10, 12): 0, toCoord(11, 12): 0,
+					toCoord(9, 10): 0, toCoord(12, 10): 0,
+				},
+			}
+			for gen, expected := range generations {
+				c.NextGen()
+				if got := positions(c); !maps.Equal(got, expected) {
+					t.Fatalf("generation %d: expected %v, got %v", gen+1, expected, got)
+				}
+			}
+
+			if c.CanSetCell(10, 9) {
+				t.Fatal("a decaying cell can be set")
+			}
+			if !c.CanSetCell(10, 10) {
+				t.Fatal("a vanished cell cannot be set")
+			}
+		})
+	}
+}
+
+func TestNeighbourhoods(t *testing.T) {
+	// with B1/S, the neighbours of a single cell are born and the cell dies
+	tests := []struct {
+		name       string
+		rule       string
+		backends   []string
+		topology   string
+		x, y       uint16
+		neighbours [][2]uint16
+	}{
+		{"hexagonal even row", "B1/SH", []string{"sparse"}, "plane", 5, 4, [][2]uint16{{4, 3}, {5, 3}, {4, 4}, {6, 4}, {4, 5}, {5, 5}}},
+		{"hexagonal odd row", "B1/SH", []string{"sparse"}, "plane", 5, 5, [][2]uint16{{5, 4}, {6, 4}, {4, 5}, {6, 5}, {5, 6}, {6, 6}}},
+		{"hexagonal odd row across the edge", "B1/SH", []string{"sparse"}, "torus", 15, 5, [][2]uint16{{15, 4}, {0, 4}, {14, 5}, {0, 5}, {15, 6}, {0, 6}}},
+		{"triangle pointing up", "B1/ST", []string{"sparse"}, "plane", 4, 4, [][2]uint16{
+			{3, 3}, {4, 3}, {5, 3},
+			{2, 4}, {3, 4}, {5, 4}, {6, 4},
+			{2, 5}, {3, 5}, {4, 5}, {5, 5}, {6, 5},
+		}},
+		{"triangle pointing down", "B1/ST", []string{"sparse"}, "plane", 5, 4, [][2]uint16{
+			{3, 3}, {4, 3}, {5, 3}, {6, 3}, {7, 3},
+			{3, 4}, {4, 4}, {6, 4}, {7, 4},
+			{4, 5}, {5, 5}, {6, 5},
+		}},
+		{"triangle pointing down on an odd row", "B1/ST", []string{"sparse"}, "plane", 4, 5, [][2]uint16{
+			{2, 4}, {3, 4}, {4, 4}, {5, 4}, {6, 4},
+			{2, 5}, {3, 5}, {5, 5}, {6, 5},
+			{3, 6}, {4, 6}, {5, 6},
+		}},
+		{"von Neumann", "B1/SV", []string{"sparse", "bitpacked"}, "plane", 5, 5, [][2]uint16{{5, 4}, {4, 5}, {6, 5}, {5, 6}}},
+		{"triangle pointing up across the edge", "B1/ST", []string{"sparse"}, "torus", 0, 0, [][2]uint16{
+			{15, 15}, {0, 15}, {1, 15},
+			{14, 0}, {15, 0}, {1, 0}, {2, 0},
+			{14, 1}, {15, 1}, {0, 1}, {1, 1}, {2, 1},
+		}},
+		{"von Neumann odd column", "B1/SV", []string{"sparse", "bitpacked"}, "plane", 4, 5, [][2]uint16{{4, 4}, {3, 5}, {5, 5}, {4, 6}}},
+	}
+	for _, tt := range tests {
+		for _, backend := range tt.backends {
+			t.Run(tt.name+" "+backend, func(t *testing.T) {
+				c := newTestConway(t, testConfig{backend, "none", tt.rule, tt.topology, 16, 16})
+				c.SetCell(tt.x, tt.y, 0, 0, 0)
+				c.NextGen()
+
+				expected := map[uint32]uint8{}
+				for _, n := range tt.neighbours {
+					expected[toCoord(n[0], n[1])] = 0
+				}
+				if got := positions(c); !maps.Equal(got, expected) {
+					t.Fatalf("expected %v, got %v", expected, got)
+				}
+			})
+		}
+	}
+}
+
+// newTestWorlds creates a world of the backend of cfg and a sparse one to compare it
+// with, both filled with the soup.
+func newTestWorlds(t *testing.T, cfg testConfig, s Soup) (sparse, other Conway) {
+	t.Helper()
+	other = newTestConway(t, cfg)
+	cfg.backend = "sparse"
+	sparse = newTestConway(t, cfg)
+	sparse.Randomise(s)
+	other.Randomise(s)
+	return sparse, other
+}
+
+var (
+	blinker = []offset{{0, 0}, {1, 0}, {2, 0}}
+	// heading down and right
+	glider = []offset{{1, 0}, {2, 1}, {0, 2}, {1, 2}, {2, 2}}
+)
+
+// placePattern returns the positions of the pattern with its top left corner at
+// (x, y) on a torus of the given size.
+func placePattern(pattern []offset, x, y, width, height int) map[uint32]uint8 {
+	ps := map[uint32]uint8{}
+	for _, o := range pattern {
+		ps[toCoord(uint16(mod(x+o.dx, width)), uint16(mod(y+o.dy, height)))] = 0
+	}
+	return ps
+}
+
+// testKnownPatterns checks that a blinker started at (x, y) of a Life torus of the
+// backend oscillates and that a glider started there moves one cell diagonally every
+// 4 generations.
+func testKnownPatterns(t *testing.T, backend string, width, height uint, x, y int) {
+	t.Helper()
+	cfg := testConfig{backend, "none", "B3/S23", "torus", width, height}
+	w, h := int(width), int(height)
+
+	c := newTestConway(t, cfg)
+	for _, o := range blinker {
+		c.SetCell(uint16(x+o.dx), uint16(y+o.dy), 0, 0, 0)
+	}
+	phases := []map[uint32]uint8{
+		placePattern(blinker, x, y, w, h),
+		placePattern([]offset{{1, -1}, {1, 0}, {1, 1}}, x, y, w, h),
+	}
+	for gen := 1; gen <= 4; gen++ {
+		c.NextGen()
+		if !maps.Equal(positions(c), phases[gen%2]) {
+			t.Fatalf("the blinker is in the wrong phase in generation %d", gen)
+		}
+	}
+
+	c = newTestConway(t, cfg)
+	for _, o := range glider {
+		c.SetCell(uint16(x+o.dx), uint16(y+o.dy), 0, 0, 0)
+	}
+	for n := 1; n <= 16; n++ {
+		c.Advance(4)
+		if !maps.Equal(positions(c), placePattern(glider, x+n, y+n, w, h)) {
+			t.Fatalf("the glider is not where it should be after %d generations", 4*n)
+		}
+	}
+}
@@ -0,0 +1,412 @@
+package conway
+
+import (
+	"errors"
+	"iter"
+	"math/bits"
+)
+
+// maxHashLifeNodes is the number of canonical nodes after which unused nodes and
+// memoised results are dropped.
+const maxHashLifeNodes = 1 << 20
+
+// node is a canonical quadtree node of size 2^level. Equal subtrees share a node, so
+// the result of advancing a node only has to be computed once.
+type node struct {
+	nw, ne, sw, se *node
+	level          uint8
+	population     uint64
+}
+
+type quad struct {
+	nw, ne, sw, se *node
+}
+
+type memoKey struct {
+	n *node
+	j uint8 // the node was advanced by 2^j generations
+}
+
+// hashLife is a quadtree implementation with memoised results, which advances
+// periodic and sparse patterns by huge numbers of generations at once. The world
+// must be a torus whose sides are the same power of two. Cells carry neither colour
+// nor age, all of them are shown in the colour of the last cell set.
+type hashLife struct {
+	level   uint8 // of the root, the world is 2^level cells wide
+	rule    Rule
+	colour  uint32
+	root    *node
+	changes changes
+
+	dead, alive *node
+	nodes       map[quad]*node
+	empty       []*node // empty nodes by level
+	results     map[memoKey]*node
+}
+
+func newHashLife(width, height int, rule Rule, topology Topology) (*hashLife, error) {
+	if width != height || bits.OnesCount(uint(width)) != 1 || width < 4 {
+		return nil, errors.New("hashlife needs a square world whose sides are a power of two of at least 4")
+	}
+	if topology != Torus {
+		return nil, errors.New("hashlife only supports the torus topology")
+	}
+	if rule.table != nil {
+		return nil, errors.New("hashlife does not support rule tables")
+	}
+	if rule.states != 2 || rule.rng != 1 || (rule.neighbourhood != moore && rule.neighbourhood != vonNeumann) {
+		return nil, errors.New("hashlife only supports two state rules with a Moore or von Neumann neighbourhood of range 1")
+	}
+	if rule.bornOnZero() {
+		return nil, errors.New("hashlife does not support B0 rules")
+	}
+
+	h := &hashLife{
+		level:  uint8(bits.TrailingZeros(uint(width))),
+		rule:   rule,
+		colour: 0xffffff,
+		dead:   &node{},
+		alive:  &node{population: 1},
+	}
+	h.reset()
+	h.root = h.emptyNode(h.level)
+	return h, nil
+}
+
+func (h *hashLife) Advance(generations uint64) {
+	if generations == 0 {
+		return
+	}
+	// the last generation is computed on its own to count its births and deaths
+	h.advance(generations - 1)
+	prev := h.root
+	h.advance(1)
+	h.changes = changes{births: uint(h.difference(h.root, prev)), deaths: uint(h.difference(prev, h.root))}
+}
+
+func (h *hashLife) advance(generations uint64) {
+	// the world is advanced as the centre of four copies of itself, which allows
+	// steps of up to half its size at once
+	maxJ := h.level - 1
+	for generations > 0 {
+		j := uint8(min(bits.Len64(generations)-1, int(maxJ)))
+		r := h.step(h.join(h.root, h.root, h.root, h.root), j)
+		// the result is the centre of the copies, so it is shifted by half the world
+		h.root = h.join(r.se, r.sw, r.ne, r.nw)
+		generations -= 1 << j
+
+		if len(h.nodes) > maxHashLifeNodes {
+			h.collect()
+		}
+	}
+}
+
+func (h *hashLife) CanSetCell(x, y uint16) bool {
+	size := 1 << h.level
+	if int(x) >= size || int(y) >= size {
+		return false
+	}
+	return h.get(h.root, int(x), int(y)) == h.dead
+}
+
+func (h *hashLife) Cells() iter.Seq2[uint, Cell] {
+	return func(yield func(uint, Cell) bool) {
+		var i uint
+		var walk func(n *node, x, y int) bool
+		walk = func(n *node, x, y int) bool {
+			if n.population == 0 {
+				return true
+			}
+			if n.level == 0 {
+				ok := yield(i, &aliveCell{uint16(x), uint16(y), h.colour, 0, 0})
+				i += 1
+				return ok
+			}
+			half := 1 << (n.level - 1)
+			return walk(n.nw, x, y) && walk(n.ne, x+half, y) && walk(n.sw, x, y+half) && walk(n.se, x+half, y+half)
+		}
+		walk(h.root, 0, 0)
+	}
+}
+
+func (h *hashLife) CellAt(x, y uint16) (Cell, bool) {
+	size := 1 << h.level
+	if int(x) >= size || int(y) >= size || h.get(h.root, int(x), int(y)) == h.dead {
+		return nil, false
+	}
+	return &aliveCell{x, y, h.colour, 0, 0}, true
+}
+
+func (h *hashLife) Changes() (uint, uint) {
+	return h.changes.births, h.changes.deaths
+}
+
+func (h *hashLife) CellsCount() uint {
+	return uint(h.root.population)
+}
+
+func (h *hashLife) Clear() {
+	h.changes = changes{}
+	h.reset()
+	h.root = h.emptyNode(h.level)
+}
+
+func (h *hashLife) ClearRegion(x, y, width, height uint16) {
+	h.changes = changes{}
+	h.root = h.clearRect(h.root, 0, 0, int(x), int(y), int(x)+int(width), int(y)+int(height))
+}
+
+func (h *hashLife) KillCell(x, y uint16) {
+	h.changes = changes{}
+	size := 1 << h.level
+	if int(x) >= size || int(y) >= size {
+		return
+	}
+	h.root = h.set(h.root, int(x), int(y), h.dead)
+}
+
+func (h *hashLife) NextGen() {
+	h.Advance(1)
+}
+
+func (h *hashLife) Randomise(s Soup) {
+	h.changes = changes{}
+	h.reset()
+	size := 1 << h.level
+	x0, y0, w, rh := s.rect(size, size)
+	h.colour = 0xffffff
+
+	var build func(level uint8, x, y int) *node
+	build = func(level uint8, x, y int) *node {
+		n := 1 << level
+		if x+n <= x0 || y+n <= y0 || x >= x0+w || y >= y0+rh {
+			return h.emptyNode(level)
+		}
+		if level == 0 {
+			cell, ok := s.at(x-x0, y-y0, w, rh, 1)
+			if !ok {
+				return h.dead
+			}
+			h.colour = cell.colour
+			return h.alive
+		}
+		half := n / 2
+		return h.join(build(level-1, x, y), build(level-1, x+half, y), build(level-1, x, y+half), build(level-1, x+half, y+half))
+	}
+	h.root = build(h.level, 0, 0)
+}
+
+func (h *hashLife) Rule() Rule {
+	return h.rule
+}
+
+func (h *hashLife) SetCell(x, y uint16, colour uint32, age uint16, state uint8) {
+	h.changes = changes{}
+	h.colour = colour
+	h.root = h.set(h.root, int(x), int(y), h.alive)
+}
+
+func (h *hashLife) SetTopology(t Topology) error {
+	if t != Torus {
+		return errors.New("hashlife only supports the torus topology")
+	}
+	return nil
+}
+
+func (h *hashLife) Topology() Topology {
+	return Torus
+}
+
+// collect drops all nodes and results that are not part of the current world.
+func (h *hashLife) collect() {
+	root := h.root
+	h.reset()
+
+	var rebuild func(n *node) *node
+	rebuild = func(n *node) *node {
+		switch {
+		case n.level == 0 && n.population == 0:
+			return h.dead
+		case n.level == 0:
+			return h.alive
+		case n.population == 0:
+			return h.emptyNode(n.level)
+		}
+		return h.join(rebuild(n.nw), rebuild(n.ne), rebuild(n.sw), rebuild(n.se))
+	}
+	h.root = rebuild(root)
+}
+
+func (h *hashLife) reset() {
+	h.nodes = make(map[quad]*node)
+	h.results = make(map[memoKey]*node)
+	h.empty = []*node{h.dead}
+}
+
+// join returns the canonical node with the given quadrants.
+func (h *hashLife) join(nw, ne, sw, se *node) *node {
+	q := quad{nw, ne, sw, se}
+	if n, ok := h.nodes[q]; ok {
+		return n
+	}
+	n := &node{
+		nw:         nw,
+		ne:         ne,
+		sw:         sw,
+		se:         se,
+		level:      nw.level + 1,
+		population: nw.population + ne.population + sw.population + se.population,
+	}
+	h.nodes[q] = n
+	return n
+}
+
+func (h *hashLife) emptyNode(level uint8) *node {
+	for len(h.empty) <= int(level) {
+		e := h.empty[len(h.empty)-1]
+		h.empty = append(h.empty, h.join(e, e, e, e))
+	}
+	return h.empty[level]
+}
+
+// difference returns the number of cells alive in a but not in b, which are of the
+// same level. Equal subtrees are the same node, so they are skipped at once.
+func (h *hashLife) difference(a, b *node) uint64 {
+	switch {
+	case a == b || a.population == 0:
+		return 0
+	case b.population == 0:
+		return a.population
+	}
+	return h.difference(a.nw, b.nw) + h.difference(a.ne, b.ne) + h.difference(a.sw, b.sw) + h.difference(a.se, b.se)
+}
+
+// centre returns the node of half the size in the middle of n.
+func (h *hashLife) centre(n *node) *node {
+	return h.join(n.nw.se, n.ne.sw, n.sw.ne, n.se.nw)
+}
+
+// step advances the node n of level k by 2^j generations, j <= k-2, and returns the
+// centre of the result, which is of level k-1.
+func (h *hashLife) step(n *node, j uint8) *node {
+	if n.population == 0 {
+		return h.emptyNode(n.level - 1)
+	}
+	key := memoKey{n, j}
+	if r, ok := h.results[key]; ok {
+		return r
+	}
+
+	var r *node
+	if n.level == 2 {
+		r = h.stepLeaf(n)
+	} else {
+		// the nine overlapping subnodes of half the size
+		n00, n01, n02 := n.nw, h.join(n.nw.ne, n.ne.nw, n.nw.se, n.ne.sw), n.ne
+		n10, n11, n12 := h.join(n.nw.sw, n.nw.se, n.sw.nw, n.sw.ne), h.centre(n), h.join(n.ne.sw, n.ne.se, n.se.nw, n.se.ne)
+		n20, n21, n22 := n.sw, h.join(n.sw.ne, n.se.nw, n.sw.se, n.se.sw), n.se
+
+		inner := j
+		advance := func(s *node) *node { return h.centre(s) }
+		if j == n.level-2 {
+			// half of the generations are spent on the subnodes, half on the result
+			inner = j - 1
+			advance = func(s *node) *node { return h.step(s, inner) }
+		}
+		c00, c01, c02 := advance(n00), advance(n01), advance(n02)
+		c10, c11, c12 := advance(n10), advance(n11), advance(n12)
+		c20, c21, c22 := advance(n20), advance(n21), advance(n22)
+
+		r = h.join(
+			h.step(h.join(c00, c01, c10, c11), inner),
+			h.step(h.join(c01, c02, c11, c12), inner),
+			h.step(h.join(c10, c11, c20, c21), inner),
+			h.step(h.join(c11, c12, c21, c22), inner),
+		)
+	}
+
+	h.results[key] = r
+	return r
+}
+
+// stepLeaf advances the centre of a 4x4 node by one generation.
+func (h *hashLife) stepLeaf(n *node) *node {
+	var grid [4][4]bool
+	for y := range 4 {
+		for x := range 4 {
+			grid[y][x] = h.get(n, x, y) == h.alive
+		}
+	}
+
+	var next [4]*node
+	for i, c := range [4][2]int{{1, 1}, {2, 1}, {1, 2}, {2, 2}} {
+		x, y := c[0], c[1]
+		count := 0
+		for _, o := range h.rule.offsets(0) {
+			if grid[y+o.dy][x+o.dx] {
+				count += 1
+			}
+		}
+		if h.rule.middle && grid[y][x] {
+			count += 1
+		}
+		next[i] = h.dead
+		if (grid[y][x] && h.rule.survival[count]) || (!grid[y][x] && h.rule.birth[count]) {
+			next[i] = h.alive
+		}
+	}
+	return h.join(next[0], next[1], next[2], next[3])
+}
+
+func (h *hashLife) get(n *node, x, y int) *node {
+	for n.level > 0 {
+		half := 1 << (n.level - 1)
+		switch {
+		case x < half && y < half:
+			n = n.nw
+		case y < half:
+			n, x = n.ne, x-half
+		case x < half:
+			n, y = n.sw, y-half
+		default:
+			n, x, y = n.se, x-half, y-half
+		}
+	}
+	return n
+}
+
+// set returns n with the cell at (x, y) replaced by the leaf, alive or dead.
+func (h *hashLife) set(n *node, x, y int, leaf *node) *node {
+	if n.level == 0 {
+		return leaf
+	}
+	half := 1 << (n.level - 1)
+	switch {
+	case x < half && y < half:
+		return h.join(h.set(n.nw, x, y, leaf), n.ne, n.sw, n.se)
+	case y < half:
+		return h.join(n.nw, h.set(n.ne, x-half, y, leaf), n.sw, n.se)
+	case x < half:
+		return h.join(n.nw, n.ne, h.set(n.sw, x, y-half, leaf), n.se)
+	}
+	return h.join(n.nw, n.ne, n.sw, h.set(n.se, x-half, y-half, leaf))
+}
+
+// clearRect returns n, whose top left corner is at (x, y), without the cells from
+// (x0, y0) up to but excluding (x1, y1).
+func (h *hashLife) clearRect(n *node, x, y, x0, y0, x1, y1 int) *node {
+	size := 1 << n.level
+	switch {
+	case n.population == 0 || x+size <= x0 || y+size <= y0 || x >= x1 || y >= y1:
+		return n
+	case x >= x0 && y >= y0 && x+size <= x1 && y+size <= y1:
+		return h.emptyNode(n.level)
+	}
+	half := size / 2
+	return h.join(
+		h.clearRect(n.nw, x, y, x0, y0, x1, y1),
+		h.clearRect(n.ne, x+half, y, x0, y0, x1, y1),
+		h.clearRect(n.sw, x, y+half, x0, y0, x1, y1),
+		h.clearRect(n.se, x+half, y+half, x0, y0, x1, y1),
+	)
+}
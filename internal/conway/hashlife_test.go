@@ -0,0 +1,67 @@
+package conway
+
+import (
+	"maps"
+	"testing"
+)
+
+func TestHashLifeMatchesSparse(t *testing.T) {
+	tests := []struct {
+		rule        string
+		generations []uint64
+	}{
+		{"B3/S23", []uint64{1, 2, 7, 64, 500}},
+		{"B36/S23", []uint64{3, 100}},
+		{"B1/S1V", []uint64{1, 16, 33}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.rule, func(t *testing.T) {
+			cfg := testConfig{"hashlife", "none", tt.rule, "torus", 64, 64}
+			sparse, hashLife := newTestWorlds(t, cfg, Soup{Seed: 42, Density: 0.35, X: 8, Y: 8, Width: 40, Height: 40})
+			if !maps.Equal(positions(sparse), positions(hashLife)) {
+				t.Fatal("the soups differ")
+			}
+
+			var total uint64
+			for _, n := range tt.generations {
+				sparse.Advance(n)
+				hashLife.Advance(n)
+				total += n
+				if !maps.Equal(positions(sparse), positions(hashLife)) {
+					t.Fatalf("the worlds differ after %d generations", total)
+				}
+				if sparse.CellsCount() != hashLife.CellsCount() {
+					t.Fatalf("the populations differ after %d generations", total)
+				}
+			}
+		})
+	}
+}
+
+func TestHashLifeKnownPatterns(t *testing.T) {
+	// across the edges of the world
+	testKnownPatterns(t, "hashlife", 64, 64, 56, 50)
+
+	t.Run("jumps", func(t *testing.T) {
+		tests := []struct {
+			pattern     []offset
+			generations uint64
+			expected    map[uint32]uint8
+		}{
+			// the blinker ends up in its other phase
+			{blinker, 1000001, placePattern([]offset{{1, -1}, {1, 0}, {1, 1}}, 40, 40, 64, 64)},
+			// the glider moves 1000003 cells, which is 3 cells on the torus
+			{glider, 4 * 1000003, placePattern(glider, 43, 43, 64, 64)},
+		}
+		for _, tt := range tests {
+			c := newTestConway(t, testConfig{"hashlife", "none", "B3/S23", "torus", 64, 64})
+			for _, o := range tt.pattern {
+				c.SetCell(uint16(40+o.dx), uint16(40+o.dy), 0, 0, 0)
+			}
+			c.Advance(tt.generations)
+			if !maps.Equal(positions(c), tt.expected) {
+				t.Fatalf("expected %v after %d generations, got %v", tt.expected, tt.generations, positions(c))
+			}
+		}
+	})
+}
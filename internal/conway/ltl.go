@@ -0,0 +1,327 @@
+package conway
+
+import (
+	"iter"
+	"math"
+)
+
+type denseCell struct {
+	colour   uint32
+	age      uint16
+	state    uint8
+	occupied bool
+}
+
+// largerThanLife is a dense implementation for rules with extended neighbourhoods.
+// Neighbour counts are taken from prefix sums over a wrapped copy of the world, so
+// the cost per cell does not grow with the size of the neighbourhood.
+type largerThanLife struct {
+	width       int
+	height      int
+	rule        Rule
+	topology    Topology
+	inheritance ColourInheritance
+	offsets     []offset
+	cells       []denseCell
+	next        []denseCell
+	count       uint
+	changes     changes
+	bands       []changes // of the generation being computed
+	bandRecorder
+
+	padWidth  int
+	padHeight int
+	padIndex  []int32 // index of the cell each padded cell is a copy of, -1 beyond dead edges
+	rowSums   []int32 // prefix sums of each padded row
+	areaSums  []int32 // prefix sums over the padded area (Moore neighbourhood only)
+}
+
+func newLargerThanLife(width, height int, rule Rule, topology Topology, inheritance ColourInheritance) *largerThanLife {
+	padWidth, padHeight := width+2*rule.rng, height+2*rule.rng
+	l := &largerThanLife{
+		width:       width,
+		height:      height,
+		rule:        rule,
+		inheritance: inheritance,
+		offsets:     rule.offsets(0),
+		cells:       make([]denseCell, width*height),
+		next:        make([]denseCell, width*height),
+		padWidth:    padWidth,
+		padHeight:   padHeight,
+		padIndex:    make([]int32, padWidth*padHeight),
+		rowSums:     make([]int32, padHeight*(padWidth+1)),
+	}
+	if rule.neighbourhood == moore {
+		l.areaSums = make([]int32, (padHeight+1)*(padWidth+1))
+	}
+	l.SetTopology(topology)
+	return l
+}
+
+func (l *largerThanLife) Advance(generations uint64) {
+	for range generations {
+		l.NextGen()
+	}
+}
+
+func (l *largerThanLife) CanSetCell(x, y uint16) bool {
+	if int(x) >= l.width || int(y) >= l.height {
+		return false
+	}
+	idx, _ := l.index(int(x), int(y))
+	return !l.cells[idx].occupied
+}
+
+func (l *largerThanLife) Cells() iter.Seq2[uint, Cell] {
+	return func(yield func(uint, Cell) bool) {
+		var i uint
+		for idx := range l.cells {
+			dc := l.cells[idx]
+			if !dc.occupied {
+				continue
+			}
+			x, y := idx%l.width, idx/l.width
+			if !yield(i, &aliveCell{uint16(x), uint16(y), dc.colour, dc.age, dc.state}) {
+				return
+			}
+			i += 1
+		}
+	}
+}
+
+func (l *largerThanLife) CellAt(x, y uint16) (Cell, bool) {
+	if int(x) >= l.width || int(y) >= l.height {
+		return nil, false
+	}
+	dc := l.cells[int(y)*l.width+int(x)]
+	if !dc.occupied {
+		return nil, false
+	}
+	return &aliveCell{x, y, dc.colour, dc.age, dc.state}, true
+}
+
+func (l *largerThanLife) CellsCount() uint {
+	return l.count
+}
+
+func (l *largerThanLife) Changes() (uint, uint) {
+	return l.changes.births, l.changes.deaths
+}
+
+func (l *largerThanLife) Clear() {
+	clear(l.cells)
+	l.count = 0
+	l.edited()
+}
+
+func (l *largerThanLife) NextGen() {
+	l.buildSums()
+	l.bands = resetBandChanges(l.bands)
+	l.begin()
+	count := forEachBand(l.width, l.height, l.nextRows)
+	l.finish()
+
+	l.cells, l.next = l.next, l.cells
+	l.count = count
+	l.changes = sumChanges(l.bands)
+}
+
+// nextRows computes the rows from..to of the next generation, counts the cells born
+// and died in them and returns the number of cells in them.
+func (l *largerThanLife) nextRows(band, from, to int) uint {
+	ch := &l.bands[band]
+	var count uint
+	for y := from; y < to; y++ {
+		for x := range l.width {
+			idx := y*l.width + x
+			dc := l.cells[idx]
+			alive := dc.occupied && dc.state == 0
+
+			numNeighbours := l.countNeighbours(x, y)
+			if alive && !l.rule.middle {
+				numNeighbours -= 1
+			}
+
+			next := denseCell{}
+			switch {
+			case alive:
+				if l.rule.survival[numNeighbours] {
+					next = dc
+					if next.age < math.MaxUint16 {
+						next.age += 1
+					}
+					break
+				}
+				ch.deaths += 1
+				l.add(band, changeOf(aliveCell{uint16(x), uint16(y), dc.colour, dc.age, dc.state}, false))
+				if l.rule.maxStage() > 0 {
+					next = dc
+					next.state = 1
+				}
+			case dc.occupied:
+				l.add(band, changeOf(aliveCell{uint16(x), uint16(y), dc.colour, dc.age, dc.state}, false))
+				if dc.state < l.rule.maxStage() {
+					next = dc
+					next.state += 1
+				}
+			case l.rule.birth[numNeighbours]:
+				next = denseCell{colour: l.inheritColour(x, y), occupied: true}
+				ch.births += 1
+				l.add(band, changeOf(aliveCell{x: uint16(x), y: uint16(y)}, true))
+			}
+
+			l.next[idx] = next
+			if next.occupied {
+				count += 1
+			}
+		}
+	}
+	return count
+}
+
+func (l *largerThanLife) Randomise(s Soup) {
+	s.fill(l, l.width, l.height, 1)
+}
+
+func (l *largerThanLife) Rule() Rule {
+	return l.rule
+}
+
+func (l *largerThanLife) SetTopology(t Topology) error {
+	l.topology = t
+	rng := l.rule.rng
+	for py := range l.padHeight {
+		for px := range l.padWidth {
+			idx, ok := l.index(px-rng, py-rng)
+			if !ok {
+				idx = -1
+			}
+			l.padIndex[py*l.padWidth+px] = int32(idx)
+		}
+	}
+	return nil
+}
+
+func (l *largerThanLife) Topology() Topology {
+	return l.topology
+}
+
+func (l *largerThanLife) ClearRegion(x, y, width, height uint16) {
+	for cy := int(y); cy < min(int(y)+int(height), l.height); cy++ {
+		for cx := int(x); cx < min(int(x)+int(width), l.width); cx++ {
+			l.KillCell(uint16(cx), uint16(cy))
+		}
+	}
+	l.edited()
+}
+
+func (l *largerThanLife) KillCell(x, y uint16) {
+	l.edited()
+	if int(x) >= l.width || int(y) >= l.height {
+		return
+	}
+	idx := int(y)*l.width + int(x)
+	if l.cells[idx].occupied {
+		l.count -= 1
+	}
+	l.cells[idx] = denseCell{}
+}
+
+func (l *largerThanLife) SetCell(x, y uint16, colour uint32, age uint16, state uint8) {
+	l.edited()
+	idx, _ := l.index(int(x), int(y))
+	if !l.cells[idx].occupied {
+		l.count += 1
+	}
+	l.cells[idx] = denseCell{colour, age, min(state, l.rule.maxStage()), true}
+}
+
+// edited forgets the changes of the last generation, as the world was changed by other
+// means than a step.
+func (l *largerThanLife) edited() {
+	l.changes = changes{}
+	l.forget()
+}
+
+// buildSums computes the prefix sums of alive cells over the world padded by the
+// neighbourhood range on every side, joining the edges as the topology demands.
+func (l *largerThanLife) buildSums() {
+	stride := l.padWidth + 1
+
+	forEachBand(l.padWidth, l.padHeight, func(_, from, to int) uint {
+		for py := from; py < to; py++ {
+			row := l.padIndex[py*l.padWidth:][:l.padWidth]
+			sums := l.rowSums[py*stride:][:stride]
+			var acc int32
+			for px, idx := range row {
+				if idx >= 0 {
+					if dc := l.cells[idx]; dc.occupied && dc.state == 0 {
+						acc += 1
+					}
+				}
+				sums[px+1] = acc
+			}
+		}
+		return 0
+	})
+
+	if l.areaSums == nil {
+		return
+	}
+	for py := range l.padHeight {
+		above := l.areaSums[py*stride:][:stride]
+		area := l.areaSums[(py+1)*stride:][:stride]
+		sums := l.rowSums[py*stride:][:stride]
+		for px := 1; px < stride; px++ {
+			area[px] = above[px] + sums[px]
+		}
+	}
+}
+
+// countNeighbours returns the number of alive cells in the neighbourhood of (x, y),
+// including the cell itself.
+func (l *largerThanLife) countNeighbours(x, y int) int {
+	rng := l.rule.rng
+	stride := l.padWidth + 1
+
+	if l.rule.neighbourhood == moore {
+		top, bottom := y*stride, (y+2*rng+1)*stride
+		left, right := x, x+2*rng+1
+		return int(l.areaSums[bottom+right] - l.areaSums[top+right] - l.areaSums[bottom+left] + l.areaSums[top+left])
+	}
+
+	var count int32
+	for dy := -rng; dy <= rng; dy++ {
+		w := rng - abs(dy)
+		row := (y + rng + dy) * stride
+		count += l.rowSums[row+x+rng+w+1] - l.rowSums[row+x+rng-w]
+	}
+	return int(count)
+}
+
+// inheritColour returns the colour of a cell born at (x, y) from its alive neighbours.
+func (l *largerThanLife) inheritColour(x, y int) uint32 {
+	var parents [maxParents]uint32
+	n := 0
+	for _, o := range l.offsets {
+		idx, ok := l.index(x+o.dx, y+o.dy)
+		if !ok {
+			continue
+		}
+		if dc := l.cells[idx]; dc.occupied && dc.state == 0 {
+			parents[n] = dc.colour
+			n += 1
+			if n == len(parents) {
+				break
+			}
+		}
+	}
+	return l.inheritance.Inherit(uint16(x), uint16(y), parents[:n])
+}
+
+// index returns the index of the cell at (x, y), which may lie beyond the edges of the
+// world. It returns false if the cell is beyond a dead edge.
+func (l *largerThanLife) index(x, y int) (int, bool) {
+	x, y, ok := l.topology.wrap(x, y, l.width, l.height)
+	return y*l.width + x, ok
+}
@@ -0,0 +1,73 @@
+package conway
+
+import (
+	"maps"
+	"testing"
+)
+
+func TestLargerThanLifeCountsNeighbours(t *testing.T) {
+	rules := []string{"R3,C0,M1,S9..18,B10..14,NM", "R3,C3,M0,S3..6,B4..6,NN"}
+	for _, topology := range Topologies() {
+		for _, rule := range rules {
+			t.Run(topology.String()+" "+rule, func(t *testing.T) {
+				r, err := ParseRule(rule)
+				if err != nil {
+					t.Fatal(err)
+				}
+				l := newLargerThanLife(23, 14, r, topology, noInheritance{})
+				l.Randomise(Soup{Seed: 11, Density: 0.5})
+				l.NextGen()
+				l.buildSums()
+
+				for y := range l.height {
+					for x := range l.width {
+						expected := 0
+						for dy := -r.rng; dy <= r.rng; dy++ {
+							for dx := -r.rng; dx <= r.rng; dx++ {
+								if r.neighbourhood == vonNeumann && abs(dx)+abs(dy) > r.rng {
+									continue
+								}
+								idx, ok := l.index(x+dx, y+dy)
+								if ok && l.cells[idx].occupied && l.cells[idx].state == 0 {
+									expected += 1
+								}
+							}
+						}
+						if got := l.countNeighbours(x, y); got != expected {
+							t.Fatalf("(%d, %d): expected %d neighbours, got %d", x, y, expected, got)
+						}
+					}
+				}
+			})
+		}
+	}
+}
+
+func TestBoscosRule(t *testing.T) {
+	c := newTestConway(t, testConfig{"auto", "none", "R5,C0,M1,S34..58,B34..45,NM", "plane", 64, 64})
+	for y := range uint16(11) {
+		for x := range uint16(11) {
+			c.SetCell(20+x, 20+y, 0, 0, 0)
+		}
+	}
+	c.NextGen()
+
+	// the range 5 neighbourhood of a cell i cells right of the left edge of the 11x11
+	// block covers overlap(i) of its columns, likewise for the rows
+	overlap := func(i int) int {
+		return max(0, min(10, i+5)-max(0, i-5)+1)
+	}
+	expected := map[uint32]uint8{}
+	for i := -5; i < 16; i++ {
+		for j := -5; j < 16; j++ {
+			n := overlap(i) * overlap(j)
+			inside := i >= 0 && i < 11 && j >= 0 && j < 11
+			if (inside && n >= 34 && n <= 58) || (!inside && n >= 34 && n <= 45) {
+				expected[toCoord(uint16(20+i), uint16(20+j))] = 0
+			}
+		}
+	}
+	if got := positions(c); !maps.Equal(got, expected) {
+		t.Fatalf("expected %d cells, got %d: %v", len(expected), len(got), got)
+	}
+}
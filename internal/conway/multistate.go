@@ -0,0 +1,201 @@
+package conway
+
+import (
+	"iter"
+)
+
+// multiState is a dense implementation for rule tables. Each cell is stored as its
+// state in the table, cells carry neither colour nor age.
+type multiState struct {
+	width    int
+	height   int
+	rule     Rule
+	table    *ruleTable
+	topology Topology
+	cells    []uint8
+	next     []uint8
+	count    uint
+	changes  changes
+	bands    []changes // of the generation being computed
+	bandRecorder
+}
+
+func newMultiState(width, height int, rule Rule, topology Topology) *multiState {
+	return &multiState{
+		width:    width,
+		height:   height,
+		rule:     rule,
+		table:    rule.table,
+		topology: topology,
+		cells:    make([]uint8, width*height),
+		next:     make([]uint8, width*height),
+	}
+}
+
+func (m *multiState) Advance(generations uint64) {
+	for range generations {
+		m.NextGen()
+	}
+}
+
+func (m *multiState) CanSetCell(x, y uint16) bool {
+	if int(x) >= m.width || int(y) >= m.height {
+		return false
+	}
+	return m.cells[int(y)*m.width+int(x)] == 0
+}
+
+func (m *multiState) Cells() iter.Seq2[uint, Cell] {
+	return func(yield func(uint, Cell) bool) {
+		var i uint
+		for idx, s := range m.cells {
+			if s == 0 {
+				continue
+			}
+			x, y := idx%m.width, idx/m.width
+			if !yield(i, &aliveCell{uint16(x), uint16(y), m.table.colours[s], 0, s - 1}) {
+				return
+			}
+			i += 1
+		}
+	}
+}
+
+func (m *multiState) CellAt(x, y uint16) (Cell, bool) {
+	if int(x) >= m.width || int(y) >= m.height {
+		return nil, false
+	}
+	s := m.cells[int(y)*m.width+int(x)]
+	if s == 0 {
+		return nil, false
+	}
+	return &aliveCell{x, y, m.table.colours[s], 0, s - 1}, true
+}
+
+func (m *multiState) CellsCount() uint {
+	return m.count
+}
+
+func (m *multiState) Changes() (uint, uint) {
+	return m.changes.births, m.changes.deaths
+}
+
+func (m *multiState) Clear() {
+	clear(m.cells)
+	m.count = 0
+	m.edited()
+}
+
+func (m *multiState) NextGen() {
+	m.bands = resetBandChanges(m.bands)
+	m.begin()
+	count := forEachBand(m.width, m.height, m.nextRows)
+	m.finish()
+
+	m.cells, m.next = m.next, m.cells
+	m.count = count
+	m.changes = sumChanges(m.bands)
+}
+
+// nextRows computes the rows from..to of the next generation, counts the cells born
+// and died in them and returns the number of cells in them. Cells are born when they
+// leave the empty state and die when they return to it.
+func (m *multiState) nextRows(band, from, to int) uint {
+	var count uint
+	ch := &m.bands[band]
+	neighbours := [2][]offset{m.table.neighbourOffsets(0), m.table.neighbourOffsets(1)}
+	inputs := make([]uint8, m.table.inputs)
+	for y := from; y < to; y++ {
+		for x := range m.width {
+			idx := y*m.width + x
+			inputs[0] = m.cells[idx]
+			for i, o := range neighbours[m.rule.parity(x, y)] {
+				inputs[i+1] = m.stateAt(x+o.dx, y+o.dy)
+			}
+
+			next := m.table.next(inputs)
+			m.next[idx] = next
+			switch {
+			case next != 0 && inputs[0] == 0:
+				ch.births += 1
+			case next == 0 && inputs[0] != 0:
+				ch.deaths += 1
+			}
+			if prev := inputs[0]; next != prev && prev == 0 {
+				m.add(band, changeOf(aliveCell{x: uint16(x), y: uint16(y)}, true))
+			} else if next != prev {
+				m.add(band, changeOf(aliveCell{uint16(x), uint16(y), m.table.colours[prev], 0, prev - 1}, false))
+			}
+			if next != 0 {
+				count += 1
+			}
+		}
+	}
+	return count
+}
+
+func (m *multiState) Randomise(s Soup) {
+	s.fill(m, m.width, m.height, m.table.states-1)
+}
+
+func (m *multiState) Rule() Rule {
+	return m.rule
+}
+
+func (m *multiState) ClearRegion(x, y, width, height uint16) {
+	for cy := int(y); cy < min(int(y)+int(height), m.height); cy++ {
+		for cx := int(x); cx < min(int(x)+int(width), m.width); cx++ {
+			m.KillCell(uint16(cx), uint16(cy))
+		}
+	}
+	m.edited()
+}
+
+func (m *multiState) KillCell(x, y uint16) {
+	m.edited()
+	if int(x) >= m.width || int(y) >= m.height {
+		return
+	}
+	idx := int(y)*m.width + int(x)
+	if m.cells[idx] != 0 {
+		m.count -= 1
+	}
+	m.cells[idx] = 0
+}
+
+func (m *multiState) SetCell(x, y uint16, colour uint32, age uint16, state uint8) {
+	m.edited()
+	idx := int(y)*m.width + int(x)
+	if m.cells[idx] == 0 {
+		m.count += 1
+	}
+	m.cells[idx] = min(state, m.rule.maxStage()) + 1
+}
+
+func (m *multiState) SetTopology(t Topology) error {
+	m.topology = t
+	return nil
+}
+
+func (m *multiState) Topology() Topology {
+	return m.topology
+}
+
+// edited forgets the changes of the last generation, as the world was changed by other
+// means than a step.
+func (m *multiState) edited() {
+	m.changes = changes{}
+	m.forget()
+}
+
+// stateAt returns the state of the cell at (x, y), which may lie beyond the edges of
+// the world. Cells beyond dead edges are empty.
+func (m *multiState) stateAt(x, y int) uint8 {
+	if uint(x) >= uint(m.width) || uint(y) >= uint(m.height) {
+		var ok bool
+		if x, y, ok = m.topology.wrap(x, y, m.width, m.height); !ok {
+			return 0
+		}
+	}
+	return m.cells[y*m.width+x]
+}
@@ -0,0 +1,40 @@
+package conway
+
+import (
+	"runtime"
+	"sync"
+)
+
+// minBandCells is the smallest number of cells worth stepping on a goroutine of its own.
+const minBandCells = 1 << 14
+
+// forEachBand splits the rows of a world into bands, one per available CPU, steps them
+// concurrently and returns the sum of the counts returned by step. Bands are numbered
+// from 0 and never more than GOMAXPROCS.
+//
+// step must only write to the rows of its band. Cells beyond the edges of a band are
+// read from the current generation, which does not change while the bands are
+// stepped, so the result is the same as stepping all rows at once.
+func forEachBand(width, height int, step func(band, from, to int) uint) uint {
+	bands := min(runtime.GOMAXPROCS(0), height, max(width*height/minBandCells, 1))
+	if bands <= 1 {
+		return step(0, 0, height)
+	}
+
+	counts := make([]uint, bands)
+	var wg sync.WaitGroup
+	for i := range bands {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			counts[i] = step(i, i*height/bands, (i+1)*height/bands)
+		}()
+	}
+	wg.Wait()
+
+	var count uint
+	for _, c := range counts {
+		count += c
+	}
+	return count
+}
@@ -0,0 +1,53 @@
+package conway
+
+import (
+	"maps"
+	"runtime"
+	"testing"
+)
+
+func TestParallelMatchesSerial(t *testing.T) {
+	tests := []struct {
+		backend     string
+		rule        string
+		generations int
+	}{
+		{"sparse", "B3/S23", 5},
+		{"sparse", "B2/S/C3", 5},
+		{"bitpacked", "B3/S23", 20},
+		{"auto", "R3,C0,M1,S9..18,B10..14,NM", 3},
+		{"multistate", "WireWorld", 10},
+	}
+	procs := runtime.GOMAXPROCS(0)
+	t.Cleanup(func() { runtime.GOMAXPROCS(procs) })
+
+	for _, tt := range tests {
+		t.Run(tt.backend+" "+tt.rule, func(t *testing.T) {
+			// big enough to be split into bands
+			cfg := testConfig{tt.backend, "mutation:0.5", tt.rule, "klein-bottle", 512, 512}
+			run := func(procs int) map[uint32]aliveCell {
+				runtime.GOMAXPROCS(procs)
+				c := newTestConway(t, cfg)
+				c.Randomise(Soup{Seed: 3, Density: 0.5})
+				c.Advance(uint64(tt.generations))
+				return cellsByPosition(c)
+			}
+
+			if !maps.Equal(run(1), run(8)) {
+				t.Fatal("the worlds differ")
+			}
+		})
+	}
+}
+
+func TestParallelKnownPatterns(t *testing.T) {
+	procs := runtime.GOMAXPROCS(8)
+	t.Cleanup(func() { runtime.GOMAXPROCS(procs) })
+
+	for _, backend := range []string{"sparse", "bitpacked"} {
+		t.Run(backend, func(t *testing.T) {
+			// the 512 rows are split into 8 bands of 64 rows, which the glider crosses
+			testKnownPatterns(t, backend, 512, 512, 100, 56)
+		})
+	}
+}
@@ -0,0 +1,498 @@
+package conway
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+)
+
+const DefaultRule = "B3/S23"
+
+const (
+	maxStates = 256
+	maxRange  = 10
+)
+
+type neighbourhood uint8
+
+const (
+	moore neighbourhood = iota
+	vonNeumann
+	hexagonal
+	triangular
+)
+
+// Grid is the cell shape of a rule's neighbourhood.
+type Grid uint8
+
+const (
+	SquareGrid Grid = iota
+	HexagonalGrid
+	TriangularGrid
+)
+
+// Rule is a Life-like rule in B/S notation, e.g. B3/S23 (Conway) or B36/S23 (HighLife).
+// Generations rules carry a third part with the number of cell states, e.g. B2/S/C3
+// (Brian's Brain). Cells that fail to survive then pass through states-2 decay stages
+// before vanishing. A suffix selects another neighbourhood: H for the 6 cells of a
+// hexagonal grid, T for the 12 cells of a triangular grid and V for the 4 cells of the
+// von Neumann neighbourhood, e.g. B2/S34H. Counts above 9 are written as A, B and C.
+// Larger than Life rules use the R,C,M,S,B,N notation, e.g. R5,C0,M1,S34..58,B34..45,NM
+// (Bosco's rule). Rule tables in the format of Golly, like WireWorld, are given by name
+// or by the path of their .rule file.
+type Rule struct {
+	birth         []bool // indexed by number of alive neighbours
+	survival      []bool
+	states        int
+	rng           int
+	neighbourhood neighbourhood
+	middle        bool // the cell itself is included in the neighbour count
+	table         *ruleTable
+}
+
+// ParseRule parses a rule string in B/S notation ("B3/S23"), B/S/C notation
+// ("B2/S/C3") or Larger than Life notation ("R5,C0,M1,S34..58,B34..45,NM").
+// Parts without a prefix are read in the legacy S/B/C order, so "23/3" and
+// Generations rules like "/2/3" or "345/2/4" are accepted as well.
+func ParseRule(s string) (Rule, error) {
+	s = strings.TrimSpace(s)
+	if t, ok := builtinRuleTable(s); ok {
+		return tableRule(t), nil
+	}
+	s = strings.ToUpper(s)
+	if strings.HasPrefix(s, "R") {
+		return parseLtLRule(s)
+	}
+	return parseBSRule(s)
+}
+
+// LoadRule is like ParseRule, but also reads rule tables from .rule files.
+func LoadRule(s string) (Rule, error) {
+	if !strings.HasSuffix(strings.ToLower(s), ".rule") {
+		return ParseRule(s)
+	}
+	f, err := os.Open(s)
+	if err != nil {
+		return Rule{}, err
+	}
+	defer f.Close()
+	t, err := parseRuleTable(f)
+	if err != nil {
+		return Rule{}, fmt.Errorf("invalid rule table '%s': %w", s, err)
+	}
+	return tableRule(t), nil
+}
+
+func tableRule(t *ruleTable) Rule {
+	return Rule{states: t.states, rng: 1, neighbourhood: t.neighbourhood, table: t}
+}
+
+func parseBSRule(s string) (Rule, error) {
+	r := Rule{states: 2, rng: 1, neighbourhood: moore}
+
+	rule := s
+	switch {
+	case strings.HasSuffix(rule, "H"):
+		r.neighbourhood = hexagonal
+	case strings.HasSuffix(rule, "T"):
+		r.neighbourhood = triangular
+	case strings.HasSuffix(rule, "V"):
+		r.neighbourhood = vonNeumann
+	}
+	if r.neighbourhood != moore {
+		rule = rule[:len(rule)-1]
+	}
+
+	parts := strings.Split(rule, "/")
+	if len(parts) < 2 || len(parts) > 3 {
+		return r, fmt.Errorf("invalid rule '%s': expected two or three parts separated by '/'", s)
+	}
+
+	var birth, survival, states string
+	positional := [3]*string{&survival, &birth, &states}
+	for i, p := range parts {
+		switch {
+		case strings.HasPrefix(p, "B"):
+			birth = p[1:]
+		case strings.HasPrefix(p, "S"):
+			survival = p[1:]
+		case strings.HasPrefix(p, "C"), strings.HasPrefix(p, "G"):
+			states = p[1:]
+		default:
+			*positional[i] = p
+		}
+	}
+
+	maxCount := r.maxCount()
+	r.birth = make([]bool, maxCount+1)
+	r.survival = make([]bool, maxCount+1)
+	if err := parseCounts(birth, r.birth); err != nil {
+		return r, fmt.Errorf("invalid rule '%s': %w", s, err)
+	}
+	if err := parseCounts(survival, r.survival); err != nil {
+		return r, fmt.Errorf("invalid rule '%s': %w", s, err)
+	}
+	if states != "" {
+		if err := r.parseStates(states); err != nil {
+			return r, fmt.Errorf("invalid rule '%s': %w", s, err)
+		}
+	}
+
+	return r, nil
+}
+
+func parseLtLRule(s string) (Rule, error) {
+	r := Rule{states: 2, neighbourhood: moore}
+
+	var birth, survival []string
+	var ranges *[]string
+	for token := range strings.SplitSeq(s, ",") {
+		if token == "" {
+			return r, fmt.Errorf("invalid rule '%s': empty part", s)
+		}
+		if token[0] >= '0' && token[0] <= '9' {
+			// additional count range for the preceding S or B part
+			if ranges == nil {
+				return r, fmt.Errorf("invalid rule '%s': unexpected count range '%s'", s, token)
+			}
+			*ranges = append(*ranges, token)
+			continue
+		}
+
+		ranges = nil
+		value := token[1:]
+		switch token[0] {
+		case 'R':
+			n, err := strconv.Atoi(value)
+			if err != nil || n < 1 || n > maxRange {
+				return r, fmt.Errorf("invalid rule '%s': range must be between 1 and %d", s, maxRange)
+			}
+			r.rng = n
+		case 'C':
+			n, err := strconv.Atoi(value)
+			if err != nil {
+				return r, fmt.Errorf("invalid rule '%s': invalid number of states '%s'", s, value)
+			}
+			if n > 0 {
+				if err := r.parseStates(value); err != nil {
+					return r, fmt.Errorf("invalid rule '%s': %w", s, err)
+				}
+			}
+		case 'M':
+			if value != "0" && value != "1" {
+				return r, fmt.Errorf("invalid rule '%s': middle must be 0 or 1", s)
+			}
+			r.middle = value == "1"
+		case 'S':
+			survival = append(survival, value)
+			ranges = &survival
+		case 'B':
+			birth = append(birth, value)
+			ranges = &birth
+		case 'N':
+			switch value {
+			case "M":
+				r.neighbourhood = moore
+			case "N":
+				r.neighbourhood = vonNeumann
+			default:
+				return r, fmt.Errorf("invalid rule '%s': unknown neighbourhood '%s'", s, value)
+			}
+		default:
+			return r, fmt.Errorf("invalid rule '%s': unknown part '%s'", s, token)
+		}
+	}
+	if r.rng == 0 {
+		return r, fmt.Errorf("invalid rule '%s': missing range", s)
+	}
+
+	maxCount := r.maxCount()
+	r.birth = make([]bool, maxCount+1)
+	r.survival = make([]bool, maxCount+1)
+	for _, rng := range birth {
+		if err := parseCountRange(rng, r.birth); err != nil {
+			return r, fmt.Errorf("invalid rule '%s': %w", s, err)
+		}
+	}
+	for _, rng := range survival {
+		if err := parseCountRange(rng, r.survival); err != nil {
+			return r, fmt.Errorf("invalid rule '%s': %w", s, err)
+		}
+	}
+
+	return r, nil
+}
+
+// String returns the rule in canonical B/S (or B/S/C) notation, or in
+// Larger than Life notation if the rule cannot be expressed in B/S notation.
+func (r Rule) String() string {
+	if r.table != nil {
+		return r.table.name
+	}
+	var b strings.Builder
+	if !r.isLifeLike() {
+		fmt.Fprintf(&b, "R%d,C", r.rng)
+		if r.states > 2 {
+			b.WriteString(strconv.Itoa(r.states))
+		} else {
+			b.WriteByte('0')
+		}
+		if r.middle {
+			b.WriteString(",M1,S")
+		} else {
+			b.WriteString(",M0,S")
+		}
+		writeCountRanges(&b, r.survival)
+		b.WriteString(",B")
+		writeCountRanges(&b, r.birth)
+		if r.neighbourhood == vonNeumann {
+			b.WriteString(",NN")
+		} else {
+			b.WriteString(",NM")
+		}
+		return b.String()
+	}
+
+	b.WriteByte('B')
+	writeCounts(&b, r.birth)
+	b.WriteString("/S")
+	writeCounts(&b, r.survival)
+	if r.states > 2 {
+		fmt.Fprintf(&b, "/C%d", r.states)
+	}
+	switch r.neighbourhood {
+	case hexagonal:
+		b.WriteByte('H')
+	case triangular:
+		b.WriteByte('T')
+	case vonNeumann:
+		b.WriteByte('V')
+	}
+	return b.String()
+}
+
+// Grid returns the cell shape the rule is meant to be displayed with.
+func (r Rule) Grid() Grid {
+	switch r.neighbourhood {
+	case hexagonal:
+		return HexagonalGrid
+	case triangular:
+		return TriangularGrid
+	}
+	return SquareGrid
+}
+
+// StateColours returns the colour of every cell state of a rule table, indexed by
+// Cell.State(). It returns nil for other rules, whose cells carry their own colour.
+func (r Rule) StateColours() []uint32 {
+	if r.table == nil {
+		return nil
+	}
+	return r.table.colours[1:]
+}
+
+// bornOnZero reports whether dead cells without any alive neighbours are born.
+func (r Rule) bornOnZero() bool {
+	return r.birth[0]
+}
+
+// isLifeLike reports whether the rule can be expressed in B/S notation.
+func (r Rule) isLifeLike() bool {
+	return r.table == nil && r.rng == 1 && !r.middle
+}
+
+// bitPackable reports whether the neighbourhood of the rule fits into the 3x3 square
+// around a cell independently of its position.
+func (r Rule) bitPackable() bool {
+	return r.isLifeLike() && (r.neighbourhood == moore || r.neighbourhood == vonNeumann)
+}
+
+// maxCount returns the highest possible neighbour count.
+func (r Rule) maxCount() int {
+	n := 0
+	switch r.neighbourhood {
+	case moore:
+		n = (2*r.rng+1)*(2*r.rng+1) - 1
+	case vonNeumann:
+		n = 2 * r.rng * (r.rng + 1)
+	case hexagonal:
+		n = 6
+	case triangular:
+		n = 12
+	}
+	if r.middle {
+		n += 1
+	}
+	return n
+}
+
+// maxStage returns the last decay stage a cell passes through before vanishing.
+// It is 0 for rules without decay.
+func (r Rule) maxStage() uint8 {
+	return uint8(r.states - 2)
+}
+
+// offsets returns the relative positions of all neighbours in row-major order.
+// Hexagonal and triangular neighbourhoods depend on the parity of the cell.
+func (r Rule) offsets(parity int) []offset {
+	switch r.neighbourhood {
+	case hexagonal:
+		// odd rows are shifted right by half a cell
+		if parity == 0 {
+			return []offset{{-1, -1}, {0, -1}, {-1, 0}, {1, 0}, {-1, 1}, {0, 1}}
+		}
+		return []offset{{0, -1}, {1, -1}, {-1, 0}, {1, 0}, {0, 1}, {1, 1}}
+	case triangular:
+		// even cells point up, odd cells point down
+		var offsets []offset
+		for dy := -1; dy <= 1; dy++ {
+			w := 2
+			if (parity == 0 && dy == -1) || (parity == 1 && dy == 1) {
+				w = 1
+			}
+			for dx := -w; dx <= w; dx++ {
+				if dx != 0 || dy != 0 {
+					offsets = append(offsets, offset{dx, dy})
+				}
+			}
+		}
+		return offsets
+	}
+
+	var offsets []offset
+	for dy := -r.rng; dy <= r.rng; dy++ {
+		for dx := -r.rng; dx <= r.rng; dx++ {
+			if dx == 0 && dy == 0 {
+				continue
+			}
+			if r.neighbourhood == vonNeumann && abs(dx)+abs(dy) > r.rng {
+				continue
+			}
+			offsets = append(offsets, offset{dx, dy})
+		}
+	}
+	return offsets
+}
+
+// parity returns the index of the neighbour offsets to use for the cell at (x, y).
+func (r Rule) parity(x, y int) int {
+	switch r.neighbourhood {
+	case hexagonal:
+		return y & 1
+	case triangular:
+		return (x + y) & 1
+	}
+	return 0
+}
+
+// checkWorldSize reports an error if the parity of cells would not survive wrapping
+// around a world of the given size.
+func (r Rule) checkWorldSize(width, height int) error {
+	switch {
+	case r.neighbourhood == hexagonal && height%2 != 0:
+		return fmt.Errorf("rule %s needs a world of even height", r)
+	case r.neighbourhood == triangular && (width%2 != 0 || height%2 != 0):
+		return fmt.Errorf("rule %s needs a world of even width and height", r)
+	}
+	return nil
+}
+
+func (r *Rule) parseStates(s string) error {
+	n, err := strconv.Atoi(s)
+	if err != nil || n < 2 || n > maxStates {
+		return fmt.Errorf("number of states must be between 2 and %d", maxStates)
+	}
+	r.states = n
+	return nil
+}
+
+type offset struct {
+	dx, dy int
+}
+
+func abs(v int) int {
+	if v < 0 {
+		return -v
+	}
+	return v
+}
+
+func parseCounts(s string, dest []bool) error {
+	for _, ch := range s {
+		n := -1
+		switch {
+		case ch >= '0' && ch <= '9':
+			n = int(ch - '0')
+		case ch >= 'A' && ch <= 'C':
+			n = int(ch-'A') + 10
+		}
+		if n < 0 || n >= len(dest) {
+			return fmt.Errorf("invalid neighbour count '%c'", ch)
+		}
+		dest[n] = true
+	}
+	return nil
+}
+
+func parseCountRange(s string, dest []bool) error {
+	if s == "" {
+		return nil
+	}
+	lo, hi, isRange := strings.Cut(s, "..")
+	if !isRange {
+		lo, hi, isRange = strings.Cut(s, "-")
+	}
+	if !isRange {
+		hi = lo
+	}
+	from, err := strconv.Atoi(lo)
+	if err != nil {
+		return fmt.Errorf("invalid neighbour count range '%s'", s)
+	}
+	to, err := strconv.Atoi(hi)
+	if err != nil {
+		return fmt.Errorf("invalid neighbour count range '%s'", s)
+	}
+	if from < 0 || from > to || to >= len(dest) {
+		return fmt.Errorf("neighbour count range '%s' must be within 0..%d", s, len(dest)-1)
+	}
+	for n := from; n <= to; n++ {
+		dest[n] = true
+	}
+	return nil
+}
+
+func writeCounts(b *strings.Builder, counts []bool) {
+	for n, set := range counts {
+		switch {
+		case !set:
+		case n < 10:
+			b.WriteByte(byte('0' + n))
+		default:
+			b.WriteByte(byte('A' + n - 10))
+		}
+	}
+}
+
+func writeCountRanges(b *strings.Builder, counts []bool) {
+	first := true
+	for n := 0; n < len(counts); n++ {
+		if !counts[n] {
+			continue
+		}
+		from := n
+		for n+1 < len(counts) && counts[n+1] {
+			n++
+		}
+		if !first {
+			b.WriteByte(',')
+		}
+		first = false
+		if from == n {
+			b.WriteString(strconv.Itoa(n))
+		} else {
+			fmt.Fprintf(b, "%d..%d", from, n)
+		}
+	}
+}
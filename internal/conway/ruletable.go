@@ -0,0 +1,524 @@
+package conway
+
+import (
+	"bufio"
+	"embed"
+	"errors"
+	"fmt"
+	"io"
+	"math/bits"
+	"path"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// maxTransitions limits the number of transitions a rule table expands to, counting
+// every binding of its variables and every symmetric variant.
+const maxTransitions = 1 << 16
+
+//go:embed rules/*.rule
+var builtinRuleTables embed.FS
+
+// stateSet is a set of cell states, one bit per state.
+type stateSet [maxStates / 64]uint64
+
+func (s *stateSet) add(state int) {
+	s[state/64] |= 1 << (state % 64)
+}
+
+func (s *stateSet) has(state int) bool {
+	return s[state/64]&(1<<(state%64)) != 0
+}
+
+// ruleTable is a transition table read from a rule file in the format of Golly, with
+// an @RULE and a @TABLE section and an optional @COLORS section. States are numbered
+// as in Golly, 0 is the empty cell.
+//
+// The transitions are expanded into every binding of their variables and every
+// variant allowed by the symmetries. accepts holds a bit for each of them per input
+// and state, so the first transition that matches a neighbourhood is found by
+// intersecting the bits of its inputs, just like Golly does.
+type ruleTable struct {
+	name          string
+	states        int
+	neighbourhood neighbourhood
+	inputs        int      // the cell itself and its neighbours
+	words         int      // of the transition bits per input and state
+	accepts       []uint64 // indexed by (input*states+state)*words
+	outputs       []uint8  // by transition
+	colours       []uint32 // by state
+}
+
+// neighbourOffsets returns the neighbours of a cell in the order of the table, which is
+// clockwise starting at the top. Golly's hexagonal neighbourhood is mapped onto the
+// hexagonal grid of the game, in which odd rows are shifted right by half a cell.
+func (t *ruleTable) neighbourOffsets(parity int) []offset {
+	switch t.neighbourhood {
+	case vonNeumann:
+		return []offset{{0, -1}, {1, 0}, {0, 1}, {-1, 0}}
+	case hexagonal:
+		if parity == 0 {
+			return []offset{{0, -1}, {1, 0}, {0, 1}, {-1, 1}, {-1, 0}, {-1, -1}}
+		}
+		return []offset{{1, -1}, {1, 0}, {1, 1}, {0, 1}, {-1, 0}, {0, -1}}
+	}
+	return []offset{{0, -1}, {1, -1}, {1, 0}, {1, 1}, {0, 1}, {-1, 1}, {-1, 0}, {-1, -1}}
+}
+
+// next returns the state of a cell in the next generation. inputs holds the state of
+// the cell followed by the states of its neighbours. Cells that no transition matches
+// keep their state.
+func (t *ruleTable) next(inputs []uint8) uint8 {
+	for w := range t.words {
+		m := ^uint64(0)
+		for i, s := range inputs {
+			m &= t.accepts[(i*t.states+int(s))*t.words+w]
+			if m == 0 {
+				break
+			}
+		}
+		if m != 0 {
+			return t.outputs[w*64+bits.TrailingZeros64(m)]
+		}
+	}
+	return inputs[0]
+}
+
+// builtinRuleTable returns the rule table shipped with the game with the given name.
+func builtinRuleTable(name string) (*ruleTable, bool) {
+	entries, _ := builtinRuleTables.ReadDir("rules")
+	for _, e := range entries {
+		if !strings.EqualFold(strings.TrimSuffix(e.Name(), ".rule"), name) {
+			continue
+		}
+		f, err := builtinRuleTables.Open(path.Join("rules", e.Name()))
+		if err != nil {
+			return nil, false
+		}
+		defer f.Close()
+		t, err := parseRuleTable(f)
+		if err != nil {
+			panic(fmt.Sprintf("invalid builtin rule table %s: %s", e.Name(), err))
+		}
+		return t, true
+	}
+	return nil, false
+}
+
+type ruleTableParser struct {
+	table       *ruleTable
+	symmetries  string
+	vars        map[string]stateSet
+	transitions []expandedTransition
+}
+
+type expandedTransition struct {
+	inputs []stateSet
+	output uint8
+}
+
+func parseRuleTable(r io.Reader) (*ruleTable, error) {
+	p := &ruleTableParser{
+		table:      &ruleTable{neighbourhood: moore},
+		symmetries: "none",
+		vars:       make(map[string]stateSet),
+	}
+
+	section := ""
+	var colourLines []string
+	scanner := bufio.NewScanner(r)
+	for n := 1; scanner.Scan(); n++ {
+		line, _, _ := strings.Cut(scanner.Text(), "#")
+		line = strings.TrimSpace(line)
+		if line == "" {
+			continue
+		}
+		if strings.HasPrefix(line, "@") {
+			keyword, value, _ := strings.Cut(line, " ")
+			section = keyword
+			switch section {
+			case "@RULE":
+				p.table.name = strings.TrimSpace(value)
+			case "@TREE":
+				return nil, errors.New("rule trees are not supported, only rule tables")
+			}
+			continue
+		}
+
+		var err error
+		switch section {
+		case "@TABLE":
+			err = p.parseLine(line)
+		case "@COLORS":
+			colourLines = append(colourLines, line)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %w", n, err)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	t := p.table
+	switch {
+	case t.name == "":
+		return nil, errors.New("missing @RULE name")
+	case t.states == 0:
+		return nil, errors.New("missing @TABLE with n_states")
+	}
+
+	t.words = max((len(p.transitions)+63)/64, 1)
+	t.accepts = make([]uint64, t.inputs*t.states*t.words)
+	t.outputs = make([]uint8, len(p.transitions))
+	for i, tr := range p.transitions {
+		for input, set := range tr.inputs {
+			for s := range t.states {
+				if set.has(s) {
+					t.accepts[(input*t.states+s)*t.words+i/64] |= 1 << (i % 64)
+				}
+			}
+		}
+		t.outputs[i] = tr.output
+	}
+
+	t.colours = defaultStateColours(t.states)
+	for _, line := range colourLines {
+		fields := strings.Fields(line)
+		if len(fields) != 4 {
+			continue
+		}
+		var v [4]int
+		for i, f := range fields {
+			v[i], _ = strconv.Atoi(f)
+		}
+		if v[0] > 0 && v[0] < t.states {
+			t.colours[v[0]] = uint32(v[1]&0xff)<<16 | uint32(v[2]&0xff)<<8 | uint32(v[3]&0xff)
+		}
+	}
+
+	return t, nil
+}
+
+func (p *ruleTableParser) parseLine(line string) error {
+	t := p.table
+	if key, value, ok := strings.Cut(line, ":"); ok && !strings.Contains(key, ",") {
+		return p.parseSetting(strings.TrimSpace(key), strings.TrimSpace(value))
+	}
+	if key, value, ok := strings.Cut(line, "="); ok && !strings.HasPrefix(line, "var") {
+		// old style "n_states=4"
+		return p.parseSetting(strings.TrimSpace(key), strings.TrimSpace(value))
+	}
+	if t.states == 0 {
+		return errors.New("n_states must come before variables and transitions")
+	}
+	if rest, ok := strings.CutPrefix(line, "var "); ok {
+		name, value, ok := strings.Cut(rest, "=")
+		if !ok {
+			return fmt.Errorf("invalid variable '%s'", line)
+		}
+		set, err := p.parseSet(strings.TrimSpace(value))
+		if err != nil {
+			return err
+		}
+		p.vars[strings.TrimSpace(name)] = set
+		return nil
+	}
+	return p.parseTransition(line)
+}
+
+func (p *ruleTableParser) parseSetting(key, value string) error {
+	t := p.table
+	switch key {
+	case "n_states", "num_states":
+		n, err := strconv.Atoi(value)
+		if err != nil || n < 2 || n > maxStates {
+			return fmt.Errorf("n_states must be between 2 and %d", maxStates)
+		}
+		t.states = n
+	case "neighborhood", "neighbourhood":
+		switch strings.ToLower(value) {
+		case "moore":
+			t.neighbourhood = moore
+		case "vonneumann":
+			t.neighbourhood = vonNeumann
+		case "hexagonal":
+			t.neighbourhood = hexagonal
+		default:
+			return fmt.Errorf("unsupported neighbourhood '%s'", value)
+		}
+	case "symmetries":
+		p.symmetries = value
+	default:
+		return fmt.Errorf("unknown setting '%s'", key)
+	}
+	t.inputs = 1 + len(t.neighbourOffsets(0))
+	return nil
+}
+
+// parseSet parses a state, a variable or a set like {0,1,a} of them.
+func (p *ruleTableParser) parseSet(s string) (stateSet, error) {
+	var set stateSet
+	inner, ok := strings.CutPrefix(s, "{")
+	if !ok {
+		if v, ok := p.vars[s]; ok {
+			return v, nil
+		}
+		n, err := strconv.Atoi(s)
+		if err != nil || n < 0 || n >= p.table.states {
+			return set, fmt.Errorf("invalid state '%s'", s)
+		}
+		set.add(n)
+		return set, nil
+	}
+	inner, ok = strings.CutSuffix(inner, "}")
+	if !ok {
+		return set, fmt.Errorf("unclosed set '%s'", s)
+	}
+	for part := range strings.SplitSeq(inner, ",") {
+		v, err := p.parseSet(strings.TrimSpace(part))
+		if err != nil {
+			return set, err
+		}
+		for i := range set {
+			set[i] |= v[i]
+		}
+	}
+	return set, nil
+}
+
+func (p *ruleTableParser) parseTransition(line string) error {
+	t := p.table
+	tokens := tokeniseTransition(line)
+	if len(tokens) == 1 && len(line) == t.inputs+1 {
+		// compact form without separators, e.g. 012345678
+		tokens = strings.Split(line, "")
+	}
+	if len(tokens) != t.inputs+1 {
+		return fmt.Errorf("transition '%s' must have %d states", line, t.inputs+1)
+	}
+
+	// variables that occur more than once take the same value everywhere
+	occurrences := make(map[string]int)
+	for _, tok := range tokens {
+		if _, ok := p.vars[tok]; ok {
+			occurrences[tok] += 1
+		}
+	}
+	var bound []string
+	for _, tok := range tokens {
+		if occurrences[tok] > 1 {
+			bound = append(bound, tok)
+			occurrences[tok] = 0
+		}
+	}
+	out := tokens[len(tokens)-1]
+	if _, ok := p.vars[out]; ok && !slices.Contains(bound, out) {
+		return fmt.Errorf("output variable '%s' does not occur in the inputs", out)
+	}
+
+	binding := make(map[string]int, len(bound))
+	var expand func(i int) error
+	expand = func(i int) error {
+		if i < len(bound) {
+			set := p.vars[bound[i]]
+			for s := range t.states {
+				if set.has(s) {
+					binding[bound[i]] = s
+					if err := expand(i + 1); err != nil {
+						return err
+					}
+				}
+			}
+			return nil
+		}
+
+		inputs := make([]stateSet, t.inputs)
+		for j, tok := range tokens[:t.inputs] {
+			if s, ok := binding[tok]; ok {
+				inputs[j].add(s)
+				continue
+			}
+			set, err := p.parseSet(tok)
+			if err != nil {
+				return err
+			}
+			inputs[j] = set
+		}
+		output, ok := binding[out]
+		if !ok {
+			n, err := strconv.Atoi(out)
+			if err != nil || n < 0 || n >= t.states {
+				return fmt.Errorf("invalid output state '%s'", out)
+			}
+			output = n
+		}
+		return p.addSymmetric(inputs, uint8(output))
+	}
+	return expand(0)
+}
+
+// addSymmetric adds the transition and all its variants allowed by the symmetries.
+func (p *ruleTableParser) addSymmetric(inputs []stateSet, output uint8) error {
+	neighbours := inputs[1:]
+	var variants [][]stateSet
+	if p.symmetries == "permute" {
+		variants = distinctPermutations(neighbours)
+	} else {
+		perms, err := symmetryPermutations(p.symmetries, len(neighbours))
+		if err != nil {
+			return err
+		}
+	next:
+		for _, perm := range perms {
+			v := make([]stateSet, len(neighbours))
+			for i, j := range perm {
+				v[i] = neighbours[j]
+			}
+			for _, seen := range variants {
+				if slices.Equal(seen, v) {
+					continue next
+				}
+			}
+			variants = append(variants, v)
+		}
+	}
+
+	if len(p.transitions)+len(variants) > maxTransitions {
+		return fmt.Errorf("the table expands to more than %d transitions", maxTransitions)
+	}
+	for _, v := range variants {
+		p.transitions = append(p.transitions, expandedTransition{append([]stateSet{inputs[0]}, v...), output})
+	}
+	return nil
+}
+
+// symmetryPermutations returns the permutations of the neighbours, given in clockwise
+// order, that make up the symmetry.
+func symmetryPermutations(symmetries string, n int) ([][]int, error) {
+	rotate := func(k int) []int {
+		perm := make([]int, n)
+		for i := range perm {
+			perm[i] = (i + k) % n
+		}
+		return perm
+	}
+	reflect := func(perm []int) []int {
+		// mirrored at the vertical axis through the first neighbour
+		r := make([]int, n)
+		for i := range r {
+			r[i] = perm[(n-i)%n]
+		}
+		return r
+	}
+	count := 0
+	switch symmetries {
+	case "none", "reflect_horizontal":
+		count = 1
+	case "rotate2":
+		count = 2
+	case "rotate3":
+		count = 3
+	case "rotate4", "rotate4reflect":
+		count = 4
+	case "rotate6", "rotate6reflect":
+		count = 6
+	case "rotate8", "rotate8reflect":
+		count = 8
+	default:
+		return nil, fmt.Errorf("unknown symmetries '%s'", symmetries)
+	}
+	if count > n || n%count != 0 {
+		return nil, fmt.Errorf("symmetries '%s' do not fit the neighbourhood", symmetries)
+	}
+
+	var perms [][]int
+	for k := 0; k < n; k += n / count {
+		perms = append(perms, rotate(k))
+	}
+	if strings.HasSuffix(symmetries, "reflect") || symmetries == "reflect_horizontal" {
+		for _, perm := range perms {
+			perms = append(perms, reflect(perm))
+		}
+	}
+	return perms, nil
+}
+
+// distinctPermutations returns all orderings of the sets, each only once.
+func distinctPermutations(sets []stateSet) [][]stateSet {
+	var perms [][]stateSet
+	v := make([]stateSet, 0, len(sets))
+	used := make([]bool, len(sets))
+	var permute func()
+	permute = func() {
+		if len(v) == len(sets) {
+			perms = append(perms, append([]stateSet(nil), v...))
+			return
+		}
+		for i, s := range sets {
+			if used[i] {
+				continue
+			}
+			// equal sets take their turn in order, so each ordering is produced once
+			skip := false
+			for j := range i {
+				if !used[j] && sets[j] == s {
+					skip = true
+					break
+				}
+			}
+			if skip {
+				continue
+			}
+			used[i] = true
+			v = append(v, s)
+			permute()
+			v = v[:len(v)-1]
+			used[i] = false
+		}
+	}
+	permute()
+	return perms
+}
+
+// tokeniseTransition splits a transition at commas and spaces, keeping sets in braces
+// together.
+func tokeniseTransition(line string) []string {
+	var tokens []string
+	var b strings.Builder
+	depth := 0
+	for _, ch := range line {
+		switch {
+		case ch == '{':
+			depth += 1
+		case ch == '}':
+			depth -= 1
+		case depth == 0 && (ch == ',' || ch == ' ' || ch == '\t'):
+			if b.Len() > 0 {
+				tokens = append(tokens, b.String())
+				b.Reset()
+			}
+			continue
+		}
+		b.WriteRune(ch)
+	}
+	if b.Len() > 0 {
+		tokens = append(tokens, b.String())
+	}
+	return tokens
+}
+
+// defaultStateColours returns a gradient from red to yellow like Golly uses for rules
+// without colours.
+func defaultStateColours(states int) []uint32 {
+	colours := make([]uint32, states)
+	for s := 1; s < states; s++ {
+		g := uint32(0)
+		if states > 2 {
+			g = uint32(0xff * (s - 1) / (states - 2))
+		}
+		colours[s] = 0xff0000 | g<<8
+	}
+	return colours
+}
@@ -0,0 +1,97 @@
+package conway
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestParseRuleTable(t *testing.T) {
+	tests := []struct {
+		name  string
+		table string
+		err   string
+	}{
+		{
+			name: "von Neumann with rotations",
+			table: `@RULE Spread
+@TABLE
+n_states:3
+neighborhood:vonNeumann
+symmetries:rotate4
+var a={0,1,2}
+0,1,a,a,a,1
+1,a,a,a,a,2
+2,a,a,a,a,0`,
+		},
+		{name: "rule tree", table: "@RULE Tree\n@TREE\nnum_states=2", err: "rule trees are not supported"},
+		{name: "without name", table: "@TABLE\nn_states:2", err: "missing @RULE name"},
+		{name: "without table", table: "@RULE Empty", err: "missing @TABLE"},
+		{name: "too many states", table: "@RULE Big\n@TABLE\nn_states:300", err: "n_states must be between"},
+		{name: "unknown neighbourhood", table: "@RULE N\n@TABLE\nn_states:2\nneighborhood:Pentagonal", err: "unsupported neighbourhood"},
+		{name: "short transition", table: "@RULE S\n@TABLE\nn_states:2\nneighborhood:vonNeumann\n0,1,1,1", err: "must have 6 states"},
+		{name: "state out of range", table: "@RULE R\n@TABLE\nn_states:2\nneighborhood:vonNeumann\n0,1,1,1,1,5", err: "invalid output state"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := parseRuleTable(strings.NewReader(tt.table))
+			switch {
+			case tt.err == "" && err != nil:
+				t.Fatal(err)
+			case tt.err != "" && (err == nil || !strings.Contains(err.Error(), tt.err)):
+				t.Fatalf("expected an error containing %q, got %v", tt.err, err)
+			}
+		})
+	}
+}
+
+func TestRuleTableNext(t *testing.T) {
+	wireWorld, ok := builtinRuleTable("WireWorld")
+	if !ok {
+		t.Fatal("WireWorld is not built in")
+	}
+	tests := []struct {
+		name     string
+		inputs   []uint8 // the cell, then N, NE, E, SE, S, SW, W, NW
+		expected uint8
+	}{
+		{"empty stays empty", []uint8{0, 1, 1, 1, 0, 0, 0, 0, 0}, 0},
+		{"head becomes tail", []uint8{1, 3, 0, 3, 0, 0, 0, 0, 0}, 2},
+		{"tail becomes conductor", []uint8{2, 1, 0, 0, 0, 0, 0, 0, 0}, 3},
+		{"conductor next to a head", []uint8{3, 0, 0, 0, 0, 1, 0, 0, 0}, 1},
+		{"conductor next to two heads", []uint8{3, 0, 1, 0, 0, 0, 0, 1, 0}, 1},
+		{"conductor next to three heads", []uint8{3, 1, 1, 1, 0, 0, 0, 0, 0}, 3},
+		{"conductor next to tails", []uint8{3, 2, 2, 0, 0, 0, 0, 0, 0}, 3},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if next := wireWorld.next(tt.inputs); next != tt.expected {
+				t.Fatalf("expected state %d, got %d", tt.expected, next)
+			}
+		})
+	}
+}
+
+func TestWireWorldElectron(t *testing.T) {
+	c := newTestConway(t, testConfig{"auto", "channel-mix", "WireWorld", "plane", 16, 8})
+
+	// an electron runs along a wire from the left to the right, the states of the
+	// cells are those of the table minus one
+	const head, tail, conductor = 0, 1, 2
+	for x := range uint16(12) {
+		c.SetCell(x+2, 3, 0, 0, conductor)
+	}
+	c.SetCell(3, 3, 0, 0, head)
+	c.SetCell(2, 3, 0, 0, tail)
+
+	for gen := range 8 {
+		c.NextGen()
+		cells := positions(c)
+		x := uint16(gen + 4)
+		if cells[toCoord(x, 3)] != head || cells[toCoord(x-1, 3)] != tail {
+			t.Fatalf("generation %d: the electron is not at %d", gen+1, x)
+		}
+		if len(cells) != 12 {
+			t.Fatalf("generation %d: the wire has %d cells", gen+1, len(cells))
+		}
+	}
+}
@@ -0,0 +1,199 @@
+package conway
+
+import (
+	"fmt"
+	"hash/fnv"
+	"iter"
+	"math/rand/v2"
+	"strings"
+)
+
+// Symmetry is the symmetry of a soup, named like the symmetries of Catagolue.
+type Symmetry uint8
+
+const (
+	C1 Symmetry = iota // no symmetry
+	C2                 // invariant under rotation by 180 degrees
+	C4                 // invariant under rotation by 90 degrees
+	D4                 // mirrored at the horizontal and the vertical axis
+	D8                 // invariant under all rotations and reflections of the square
+)
+
+var symmetryNames = [...]string{
+	C1: "C1",
+	C2: "C2",
+	C4: "C4",
+	D4: "D4",
+	D8: "D8",
+}
+
+// Symmetries lists all symmetries in the order of their values.
+func Symmetries() []Symmetry {
+	ss := make([]Symmetry, len(symmetryNames))
+	for i := range ss {
+		ss[i] = Symmetry(i)
+	}
+	return ss
+}
+
+// ParseSymmetry parses the name of a symmetry, e.g. "C1" or "D8".
+func ParseSymmetry(s string) (Symmetry, error) {
+	s = strings.ToUpper(strings.TrimSpace(s))
+	for sym, name := range symmetryNames {
+		if s == name {
+			return Symmetry(sym), nil
+		}
+	}
+	return 0, fmt.Errorf("unknown symmetry %q", s)
+}
+
+// Valid reports whether s is one of the known symmetries.
+func (s Symmetry) Valid() bool {
+	return int(s) < len(symmetryNames)
+}
+
+func (s Symmetry) String() string {
+	if !s.Valid() {
+		return fmt.Sprintf("Symmetry(%d)", s)
+	}
+	return symmetryNames[s]
+}
+
+// square reports whether the symmetry needs a square, the rectangle of such a soup is
+// cut down to its largest square in the top left corner.
+func (s Symmetry) square() bool {
+	return s == C4 || s == D8
+}
+
+// Soup describes a random filling of a rectangle of the world. A soup only depends on
+// its fields, so the same soup always yields the same cells, whichever backend runs
+// the world.
+type Soup struct {
+	Seed     uint64
+	Density  float64 // share of the cells that are alive, between 0 and 1
+	X, Y     uint16  // top left corner of the rectangle
+	Width    uint16  // of the rectangle, 0 reaches to the right edge of the world
+	Height   uint16  // of the rectangle, 0 reaches to the bottom edge of the world
+	Palette  []uint32
+	Symmetry Symmetry
+}
+
+// RandomSoup returns a soup with a random seed that fills the whole world at half
+// density with random colours.
+func RandomSoup() Soup {
+	return Soup{Seed: rand.Uint64(), Density: 0.5}
+}
+
+// SeedFromString turns a seed string that users share into the seed of a soup.
+func SeedFromString(s string) uint64 {
+	h := fnv.New64a()
+	h.Write([]byte(s))
+	return h.Sum64()
+}
+
+// rect returns the rectangle of the soup clipped to a world of the given size.
+func (s Soup) rect(width, height int) (x, y, w, h int) {
+	x, y = min(int(s.X), width), min(int(s.Y), height)
+	w, h = width-x, height-y
+	if s.Width > 0 {
+		w = min(w, int(s.Width))
+	}
+	if s.Height > 0 {
+		h = min(h, int(s.Height))
+	}
+	if s.Symmetry.square() {
+		w = min(w, h)
+		h = w
+	}
+	return x, y, w, h
+}
+
+// cells yields the cells of the soup in a world of the given size. The cells are in
+// one of the first states, the state of a cell that has just been born in rules
+// without a table.
+func (s Soup) cells(width, height, states int) iter.Seq[aliveCell] {
+	return func(yield func(aliveCell) bool) {
+		x0, y0, w, h := s.rect(width, height)
+		for y := range h {
+			for x := range w {
+				if cell, ok := s.at(x, y, w, h, states); ok {
+					cell.x, cell.y = uint16(x0+x), uint16(y0+y)
+					if !yield(cell) {
+						return
+					}
+				}
+			}
+		}
+	}
+}
+
+// Inject sets the cells of the soup in the world of c after clearing the rectangle of
+// the soup, keeping the cells outside of it.
+func (s Soup) Inject(c Conway, width, height int) {
+	x, y, w, h := s.rect(width, height)
+	c.ClearRegion(uint16(x), uint16(y), uint16(w), uint16(h))
+	states := 1
+	if t := c.Rule().table; t != nil {
+		states = t.states - 1
+	}
+	for cell := range s.cells(width, height, states) {
+		c.SetCell(cell.x, cell.y, cell.colour, 0, cell.state)
+	}
+}
+
+// fill clears the world and sets the cells of the soup.
+func (s Soup) fill(c Conway, width, height, states int) {
+	c.Clear()
+	for cell := range s.cells(width, height, states) {
+		c.SetCell(cell.x, cell.y, cell.colour, 0, cell.state)
+	}
+}
+
+// at returns the cell at (x, y) of a w by h rectangle, if it is alive. Each cell is
+// derived from the seed and the first position in its orbit under the symmetry, so
+// symmetric cells are equal and no cell depends on the order they are generated in.
+func (s Soup) at(x, y, w, h, states int) (aliveCell, bool) {
+	cx, cy := x, y
+	for _, p := range s.orbit(x, y, w, h) {
+		if p[1] < cy || (p[1] == cy && p[0] < cx) {
+			cx, cy = p[0], p[1]
+		}
+	}
+
+	v := splitMix(s.Seed + uint64(cy*w+cx+1)*0x9e3779b97f4a7c15)
+	if float64(v>>11)/(1<<53) >= s.Density {
+		return aliveCell{}, false
+	}
+	v = splitMix(v)
+	var colour uint32
+	if len(s.Palette) > 0 {
+		colour = s.Palette[v%uint64(len(s.Palette))]
+	} else {
+		colour = uint32(v%0xffffff) + 1
+	}
+	return aliveCell{colour: colour, state: uint8((v >> 32) % uint64(states))}, true
+}
+
+// orbit returns the positions that (x, y) is mapped to by the symmetry within a w by h
+// rectangle.
+func (s Soup) orbit(x, y, w, h int) [][2]int {
+	rx, ry := w-1-x, h-1-y
+	switch s.Symmetry {
+	case C2:
+		return [][2]int{{rx, ry}}
+	case C4:
+		return [][2]int{{ry, x}, {rx, ry}, {y, rx}}
+	case D4:
+		return [][2]int{{rx, y}, {x, ry}, {rx, ry}}
+	case D8:
+		return [][2]int{{ry, x}, {rx, ry}, {y, rx}, {rx, y}, {x, ry}, {y, x}, {ry, rx}}
+	}
+	return nil
+}
+
+// splitMix is the finaliser of SplitMix64.
+func splitMix(v uint64) uint64 {
+	v = (v ^ (v >> 30)) * 0xbf58476d1ce4e5b9
+	v = (v ^ (v >> 27)) * 0x94d049bb133111eb
+	return v ^ (v >> 31)
+}
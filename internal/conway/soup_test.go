@@ -0,0 +1,76 @@
+package conway
+
+import (
+	"maps"
+	"testing"
+)
+
+func TestSoupIsReproducible(t *testing.T) {
+	tests := []struct {
+		name string
+		soup Soup
+	}{
+		{"whole world", Soup{Seed: SeedFromString("conwaymore"), Density: 0.5}},
+		{"rectangle", Soup{Seed: 1, Density: 0.3, X: 10, Y: 5, Width: 20, Height: 12}},
+		{"palette", Soup{Seed: 2, Density: 0.5, Palette: []uint32{0xff0000, 0x00ff00}}},
+		{"C2", Soup{Seed: 3, Density: 0.5, Width: 16, Height: 10, Symmetry: C2}},
+		{"C4", Soup{Seed: 3, Density: 0.5, Width: 16, Height: 16, Symmetry: C4}},
+		{"D4", Soup{Seed: 3, Density: 0.5, Width: 16, Height: 10, Symmetry: D4}},
+		{"D8", Soup{Seed: 3, Density: 0.5, Width: 16, Height: 16, Symmetry: D8}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var worlds []map[uint32]aliveCell
+			for _, backend := range []string{"sparse", "bitpacked", "sparse"} {
+				c := newTestConway(t, testConfig{backend, "channel-mix", "B3/S23", "torus", 64, 48})
+				c.Randomise(tt.soup)
+				worlds = append(worlds, cellsByPosition(c))
+			}
+			for _, w := range worlds[1:] {
+				if !maps.Equal(worlds[0], w) {
+					t.Fatal("the same soup yields different cells")
+				}
+			}
+
+			x, y, w, h := tt.soup.rect(64, 48)
+			if tt.soup.Symmetry.square() {
+				w, h = min(w, h), min(w, h)
+			}
+			for _, cell := range worlds[0] {
+				cx, cy := int(cell.x)-x, int(cell.y)-y
+				if cx < 0 || cx >= w || cy < 0 || cy >= h {
+					t.Fatalf("cell (%d, %d) lies outside of the soup", cell.x, cell.y)
+				}
+				// all cells of the orbit of a cell are alive alike
+				for _, p := range tt.soup.orbit(cx, cy, w, h) {
+					if _, ok := worlds[0][toCoord(uint16(x+p[0]), uint16(y+p[1]))]; !ok {
+						t.Fatalf("cell (%d, %d) is alive, but (%d, %d) is not", cell.x, cell.y, x+p[0], y+p[1])
+					}
+				}
+				if len(tt.soup.Palette) > 0 && cell.colour != 0xff0000 && cell.colour != 0x00ff00 {
+					t.Fatalf("cell (%d, %d) has colour %06x outside of the palette", cell.x, cell.y, cell.colour)
+				}
+			}
+		})
+	}
+}
+
+func TestSoupSeeds(t *testing.T) {
+	soup := func(seed uint64) map[uint32]aliveCell {
+		c := newTestConway(t, testConfig{"sparse", "channel-mix", "B3/S23", "torus", 64, 64})
+		c.Randomise(Soup{Seed: seed, Density: 0.5})
+		return cellsByPosition(c)
+	}
+	if maps.Equal(soup(1), soup(2)) {
+		t.Fatal("different seeds yield the same soup")
+	}
+	if SeedFromString("a") != SeedFromString("a") || SeedFromString("a") == SeedFromString("b") {
+		t.Fatal("seed strings are not mapped to seeds one to one")
+	}
+
+	// the density is the share of alive cells
+	n := len(soup(3))
+	if n < 64*64*45/100 || n > 64*64*55/100 {
+		t.Fatalf("%d of %d cells are alive at density 0.5", n, 64*64)
+	}
+}
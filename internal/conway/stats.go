@@ -0,0 +1,130 @@
+package conway
+
+import (
+	"cmp"
+	"math/bits"
+	"runtime"
+	"slices"
+)
+
+// AgeBuckets is the number of buckets of the age histogram. Bucket 0 holds the cells of
+// age 0 and bucket i the ages from 2^(i-1) to 2^i-1.
+const AgeBuckets = 17
+
+// maxStatsColours is the number of most frequent colours listed in the stats.
+const maxStatsColours = 8
+
+// Stats describes the population of a generation.
+type Stats struct {
+	Generation  uint64           `json:"generation"`
+	Births      uint             `json:"births"`
+	Deaths      uint             `json:"deaths"`
+	Population  uint             `json:"population"`
+	BoundingBox BoundingBox      `json:"bounding_box"`
+	Colours     []ColourCount    `json:"colours"` // most frequent first
+	Ages        [AgeBuckets]uint `json:"ages"`
+}
+
+// BoundingBox spans from the smallest to the largest coordinates of the cells, it is
+// empty for a dead world. Patterns that wrap around an edge span the whole world.
+type BoundingBox struct {
+	X      uint16 `json:"x"`
+	Y      uint16 `json:"y"`
+	Width  uint   `json:"width"`
+	Height uint   `json:"height"`
+}
+
+type ColourCount struct {
+	Colour uint32 `json:"colour"`
+	Count  uint   `json:"count"`
+}
+
+// StatsCollector collects the stats of generations. It keeps its buffers between
+// generations, so it is not safe for concurrent use.
+type StatsCollector struct {
+	colours []uint32
+}
+
+func NewStatsCollector() *StatsCollector {
+	return &StatsCollector{}
+}
+
+// CountStats returns the stats of the current generation of the world that do not
+// need to look at the cells: the population and the births and deaths of the last
+// generation that was computed.
+func CountStats(c Conway, generation uint64) Stats {
+	s := Stats{Generation: generation, Population: c.CellsCount()}
+	s.Births, s.Deaths = c.Changes()
+	return s
+}
+
+// Collect returns all stats of the current generation of the world. Births and deaths
+// are those of the last generation that was computed.
+func (sc *StatsCollector) Collect(c Conway, generation uint64) Stats {
+	s := CountStats(c, generation)
+
+	minX, minY, maxX, maxY := uint16(0xffff), uint16(0xffff), uint16(0), uint16(0)
+	colours := sc.colours[:0]
+	for _, cell := range c.Cells() {
+		x, y, colour, age := cell.Values()
+		minX, minY, maxX, maxY = min(minX, x), min(minY, y), max(maxX, x), max(maxY, y)
+		colours = append(colours, colour)
+		s.Ages[bits.Len16(age)] += 1
+	}
+	if s.Population > 0 {
+		s.BoundingBox = BoundingBox{minX, minY, uint(maxX-minX) + 1, uint(maxY-minY) + 1}
+	}
+	sc.colours = colours
+
+	// sorted, equal colours are counted in runs and only the most frequent are kept
+	slices.Sort(colours)
+	for start := 0; start < len(colours); {
+		end := start + 1
+		for end < len(colours) && colours[end] == colours[start] {
+			end += 1
+		}
+		cc := ColourCount{colours[start], uint(end - start)}
+		start = end
+
+		pos := len(s.Colours)
+		for pos > 0 && moreFrequent(cc, s.Colours[pos-1]) {
+			pos -= 1
+		}
+		if pos < maxStatsColours {
+			s.Colours = slices.Insert(s.Colours, pos, cc)
+			s.Colours = s.Colours[:min(len(s.Colours), maxStatsColours)]
+		}
+	}
+
+	return s
+}
+
+// moreFrequent orders colour counts by descending count, and then by colour.
+func moreFrequent(a, b ColourCount) bool {
+	return cmp.Or(cmp.Compare(b.Count, a.Count), cmp.Compare(a.Colour, b.Colour)) < 0
+}
+
+// changes counts the cells born and died in a generation. Cells that start to decay
+// count as died.
+type changes struct {
+	births uint
+	deaths uint
+}
+
+// resetBandChanges returns zeroed counters for each band of forEachBand.
+func resetBandChanges(cs []changes) []changes {
+	if n := runtime.GOMAXPROCS(0); len(cs) < n {
+		cs = make([]changes, n)
+	}
+	clear(cs)
+	return cs
+}
+
+func sumChanges(cs []changes) changes {
+	var sum changes
+	for _, c := range cs {
+		sum.births += c.births
+		sum.deaths += c.deaths
+	}
+	return sum
+}
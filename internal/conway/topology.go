@@ -0,0 +1,91 @@
+package conway
+
+import (
+	"fmt"
+	"strings"
+)
+
+const DefaultTopology = "torus"
+
+// Topology decides how the edges of the world are joined.
+type Topology uint8
+
+const (
+	Torus        Topology = iota // both pairs of edges are joined
+	Plane                        // bounded, cells beyond the edges are dead
+	Cylinder                     // left and right edges are joined, top and bottom are dead
+	KleinBottle                  // like the torus, but the top and bottom edges are joined with a twist
+	CrossSurface                 // both pairs of edges are joined with a twist
+)
+
+var topologyNames = [...]string{
+	Torus:        "torus",
+	Plane:        "plane",
+	Cylinder:     "cylinder",
+	KleinBottle:  "klein-bottle",
+	CrossSurface: "cross-surface",
+}
+
+// Topologies lists all topologies in the order of their values.
+func Topologies() []Topology {
+	ts := make([]Topology, len(topologyNames))
+	for i := range ts {
+		ts[i] = Topology(i)
+	}
+	return ts
+}
+
+// ParseTopology parses the name of a topology, e.g. "torus" or "klein-bottle".
+func ParseTopology(s string) (Topology, error) {
+	s = strings.ToLower(strings.TrimSpace(s))
+	for t, name := range topologyNames {
+		if s == name {
+			return Topology(t), nil
+		}
+	}
+	return 0, fmt.Errorf("unknown topology %q", s)
+}
+
+// Valid reports whether t is one of the known topologies.
+func (t Topology) Valid() bool {
+	return int(t) < len(topologyNames)
+}
+
+func (t Topology) String() string {
+	if !t.Valid() {
+		return fmt.Sprintf("Topology(%d)", t)
+	}
+	return topologyNames[t]
+}
+
+// wrap maps the coordinates of a cell that may lie beyond the edges of a world of the
+// given size back into the world. It returns false if the cell is beyond a dead edge.
+func (t Topology) wrap(x, y, width, height int) (int, int, bool) {
+	if x < 0 || x >= width {
+		switch t {
+		case Plane:
+			return 0, 0, false
+		case CrossSurface:
+			y = height - 1 - y
+		}
+		x = mod(x, width)
+	}
+	if y < 0 || y >= height {
+		switch t {
+		case Plane, Cylinder:
+			return 0, 0, false
+		case KleinBottle, CrossSurface:
+			x = width - 1 - x
+		}
+		y = mod(y, height)
+	}
+	return x, y, true
+}
+
+func mod(v, n int) int {
+	v %= n
+	if v < 0 {
+		v += n
+	}
+	return v
+}
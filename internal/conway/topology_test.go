@@ -0,0 +1,77 @@
+package conway
+
+import (
+	"maps"
+	"testing"
+)
+
+func TestWrap(t *testing.T) {
+	type result struct {
+		x, y int
+		ok   bool
+	}
+	// a world of 10x8 cells
+	tests := []struct {
+		name     string
+		x, y     int
+		expected map[Topology]result
+	}{
+		{"inside", 4, 5, map[Topology]result{
+			Torus: {4, 5, true}, Plane: {4, 5, true}, Cylinder: {4, 5, true}, KleinBottle: {4, 5, true}, CrossSurface: {4, 5, true},
+		}},
+		{"left", -1, 3, map[Topology]result{
+			Torus: {9, 3, true}, Plane: {0, 0, false}, Cylinder: {9, 3, true}, KleinBottle: {9, 3, true}, CrossSurface: {9, 4, true},
+		}},
+		{"right", 11, 0, map[Topology]result{
+			Torus: {1, 0, true}, Plane: {0, 0, false}, Cylinder: {1, 0, true}, KleinBottle: {1, 0, true}, CrossSurface: {1, 7, true},
+		}},
+		{"top", 3, -1, map[Topology]result{
+			Torus: {3, 7, true}, Plane: {0, 0, false}, Cylinder: {0, 0, false}, KleinBottle: {6, 7, true}, CrossSurface: {6, 7, true},
+		}},
+		{"bottom", 0, 9, map[Topology]result{
+			Torus: {0, 1, true}, Plane: {0, 0, false}, Cylinder: {0, 0, false}, KleinBottle: {9, 1, true}, CrossSurface: {9, 1, true},
+		}},
+		{"corner", 10, 8, map[Topology]result{
+			Torus: {0, 0, true}, Plane: {0, 0, false}, Cylinder: {0, 0, false}, KleinBottle: {9, 0, true}, CrossSurface: {9, 7, true},
+		}},
+	}
+	for _, tt := range tests {
+		for _, topology := range Topologies() {
+			t.Run(tt.name+" "+topology.String(), func(t *testing.T) {
+				x, y, ok := topology.wrap(tt.x, tt.y, 10, 8)
+				got := result{x, y, ok}
+				if !ok {
+					got = result{}
+				}
+				if expected := tt.expected[topology]; got != expected {
+					t.Fatalf("expected %+v, got %+v", expected, got)
+				}
+			})
+		}
+	}
+}
+
+func TestGliderCrossesKleinBottle(t *testing.T) {
+	for _, backend := range []string{"sparse", "bitpacked"} {
+		t.Run(backend, func(t *testing.T) {
+			c := newTestConway(t, testConfig{backend, "none", "B3/S23", "klein-bottle", 16, 16})
+			for _, o := range glider {
+				c.SetCell(uint16(2+o.dx), uint16(10+o.dy), 0, 0, 0)
+			}
+
+			// after crossing the bottom edge, the glider comes back mirrored at the top
+			// and heads down and left
+			c.Advance(4 * 8)
+			for n := 8; n <= 10; n++ {
+				expected := map[uint32]uint8{}
+				for _, o := range glider {
+					expected[toCoord(uint16(15-(2+n+o.dx)), uint16(10+n+o.dy-16))] = 0
+				}
+				if got := positions(c); !maps.Equal(got, expected) {
+					t.Fatalf("after %d generations: expected %v, got %v", 4*n, expected, got)
+				}
+				c.Advance(4)
+			}
+		})
+	}
+}
@@ -15,10 +15,33 @@ type DatabaseConfig interface {
 
 type DatabaseService interface {
 	Close() error
+	// DeleteRoom deletes the room with its schedules.
+	DeleteRoom(ctx context.Context, name string) error
+	DeleteSchedule(ctx context.Context, room string, id int64) error
+	GetRooms() ([]Room, error)
+	GetSchedules() ([]Schedule, error)
 	GetSeed() ([]byte, error)
+	WriteRoom(ctx context.Context, name string, config []byte) error
+	WriteRoomSeed(ctx context.Context, name string, seed []byte) error
+	// WriteSchedule stores a new schedule of a room and returns its ID.
+	WriteSchedule(ctx context.Context, room string, schedule []byte) (int64, error)
 	WriteSeed(ctx context.Context, seed []byte) error
 }
 
+// Room is a world of its own, stored next to the seed of the default world.
+type Room struct {
+	Name   string
+	Config []byte // JSON
+	Seed   []byte // nil until the room is saved
+}
+
+// Schedule is an event of the world of a room, the default room included.
+type Schedule struct {
+	ID       int64
+	Room     string
+	Schedule []byte // JSON
+}
+
 type service struct {
 	cfg DatabaseConfig
 	db  *sql.DB
@@ -36,6 +59,14 @@ func NewDatabaseService(cfg DatabaseConfig) DatabaseService {
 	if err != nil {
 		panic(fmt.Sprintf("could not initialise database %s", err))
 	}
+	_, err = db.Exec("CREATE TABLE IF NOT EXISTS rooms (name TEXT PRIMARY KEY, config TEXT NOT NULL, seed BLOB)")
+	if err != nil {
+		panic(fmt.Sprintf("could not initialise database %s", err))
+	}
+	_, err = db.Exec("CREATE TABLE IF NOT EXISTS schedules (id INTEGER PRIMARY KEY AUTOINCREMENT, room TEXT NOT NULL, schedule TEXT NOT NULL)")
+	if err != nil {
+		panic(fmt.Sprintf("could not initialise database %s", err))
+	}
 
 	return s
 }
@@ -45,6 +76,60 @@ func (s *service) Close() error {
 	return s.db.Close()
 }
 
+func (s *service) DeleteRoom(ctx context.Context, name string) error {
+	_, err := s.db.ExecContext(ctx, "DELETE FROM rooms WHERE name = ?", name)
+	if err != nil {
+		return err
+	}
+	_, err = s.db.ExecContext(ctx, "DELETE FROM schedules WHERE room = ?", name)
+	return err
+}
+
+func (s *service) DeleteSchedule(ctx context.Context, room string, id int64) error {
+	res, err := s.db.ExecContext(ctx, "DELETE FROM schedules WHERE room = ? AND id = ?", room, id)
+	if err != nil {
+		return err
+	}
+	if n, err := res.RowsAffected(); err == nil && n == 0 {
+		return fmt.Errorf("schedule %d of room %q does not exist", id, room)
+	}
+	return nil
+}
+
+func (s *service) GetRooms() ([]Room, error) {
+	rows, err := s.db.Query("SELECT name, config, seed FROM rooms ORDER BY name")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var rooms []Room
+	for rows.Next() {
+		var r Room
+		if err := rows.Scan(&r.Name, &r.Config, &r.Seed); err != nil {
+			return nil, err
+		}
+		rooms = append(rooms, r)
+	}
+	return rooms, rows.Err()
+}
+
+func (s *service) GetSchedules() ([]Schedule, error) {
+	rows, err := s.db.Query("SELECT id, room, schedule FROM schedules ORDER BY id")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var schedules []Schedule
+	for rows.Next() {
+		var sc Schedule
+		if err := rows.Scan(&sc.ID, &sc.Room, &sc.Schedule); err != nil {
+			return nil, err
+		}
+		schedules = append(schedules, sc)
+	}
+	return schedules, rows.Err()
+}
+
 func (s *service) GetSeed() ([]byte, error) {
 	rows, err := s.db.Query("SELECT seed FROM conway ORDER BY id DESC LIMIT 1")
 	if err != nil {
@@ -68,3 +153,27 @@ func (s *service) WriteSeed(ctx context.Context, seed []byte) error {
 
 	return nil
 }
+
+func (s *service) WriteRoom(ctx context.Context, name string, config []byte) error {
+	_, err := s.db.ExecContext(ctx, "INSERT INTO rooms (name, config) VALUES (?, ?)", name, config)
+	return err
+}
+
+func (s *service) WriteRoomSeed(ctx context.Context, name string, seed []byte) error {
+	res, err := s.db.ExecContext(ctx, "UPDATE rooms SET seed = ? WHERE name = ?", seed, name)
+	if err != nil {
+		return err
+	}
+	if n, err := res.RowsAffected(); err == nil && n == 0 {
+		return fmt.Errorf("room %q does not exist", name)
+	}
+	return nil
+}
+
+func (s *service) WriteSchedule(ctx context.Context, room string, schedule []byte) (int64, error) {
+	res, err := s.db.ExecContext(ctx, "INSERT INTO schedules (room, schedule) VALUES (?, ?)", room, schedule)
+	if err != nil {
+		return 0, err
+	}
+	return res.LastInsertId()
+}
@@ -2,9 +2,11 @@ package engine
 
 import (
 	"context"
+	"encoding/json"
 	"errors"
 	"fmt"
 	"log"
+	"math"
 	"sync"
 	"sync/atomic"
 	"time"
@@ -15,18 +17,56 @@ import (
 
 type EngineConfig interface {
 	conway.ConwayConfig
+	AutoPause() bool
+	// BroadcastFPS caps the outputs generated per second while playing, 0 generates one
+	// for each generation.
+	BroadcastFPS() uint
+	HistoryDepth() uint
 }
 
 type Engine interface {
-	Output() <-chan []byte
+	// Census classifies the objects of the current generation.
+	Census() conway.Census
+	// Output delivers the encoded outputs, with the stats of their generation as long
+	// as they are watched.
+	Output() <-chan Frame
 	Playing() bool
+	Rule() conway.Rule
 	Speed() uint32
 	Start()
-	SubmitMessage(b []byte) error
+	// Stats returns the stats of the last generations, the oldest first. Only the
+	// current generation carries the bounding box, the colours and the ages.
+	Stats() []conway.Stats
+	// LatestStats returns the stats of the current generation.
+	LatestStats() conway.Stats
+	// WatchStats adds a watcher of the stats sent with the outputs, or removes one.
+	WatchStats(watch bool)
+	// Progress reports how far StepN and JumpTo got.
+	Progress() <-chan protocol.Progress
+	// AddSchedule runs the action of the validated schedule whenever it is due.
+	AddSchedule(s Schedule)
+	// RemoveSchedule reports whether there was a schedule with the ID.
+	RemoveSchedule(id int64) bool
+	Schedules() []Schedule
+	// SubmitMessage handles a client message on behalf of the session.
+	SubmitMessage(s *Session, b []byte) error
+	WorldHeight() uint
+	WorldWidth() uint
+}
+
+// Frame is an encoded output and the JSON encoded stats of its generation, which are nil
+// unless the stats are watched.
+type Frame struct {
+	Output []byte
+	Stats  []byte
 }
 
 type state = uint32
 
+// unthrottledTime is the time spent computing generations in a tick if the speed is 0,
+// before the mutex is released for the messages again.
+const unthrottledTime = 10 * time.Millisecond
+
 const (
 	paused state = iota
 	playing
@@ -35,99 +75,381 @@ const (
 type engine struct {
 	ctx          context.Context
 	conway       conway.Conway
-	speed        atomic.Uint32 // ms
+	worldWidth   uint
+	worldHeight  uint
+	history      *history
+	period       periodDetector
+	generation   uint64 // number of generations computed since the world started over
+	stats        statsSeries
+	collector    *conway.StatsCollector
+	collected    bool         // the latest stats carry more than the counts
+	watchers     atomic.Int32 // of the stats sent with the outputs
+	version      uint64       // counts the changes of the world
+	census       *conway.Census
+	censusOf     uint64        // version of the world the census was taken of
+	censusMutex  sync.Mutex    // runs one census at a time
+	autoPause    bool          // pause once the world is dead, static or periodic
+	autoPaused   bool          // the world was paused as it repeats, so it is not paused again until it changes
+	frameTime    time.Duration // between the outputs while playing, 0 for one per generation
+	stepped      uint64        // generations computed by steps, for the generations per second
+	rate         uint32        // generations per second
+	speed        atomic.Uint32 // ms, 0 for as fast as possible
 	speedChanged atomic.Bool
 	state        atomic.Uint32
 	mutex        sync.Mutex
 	output       protocol.Output
-	outputChan   chan []byte
+	outputChan   chan Frame
+	progressChan chan protocol.Progress
+	sequence     uint32       // of the last output
+	stopped      bool         // the output channels are closed
+	fastForward  *fastForward // running StepN or JumpTo
+	schedules    []*scheduled
 	encodeBuffer []byte
 }
 
-func NewEngine(cfg EngineConfig, seed []byte, ctx context.Context) Engine {
-	ws := cfg.WorldSize()
+func NewEngine(cfg EngineConfig, seed []byte, ctx context.Context) (Engine, error) {
+	c, err := conway.NewConway(cfg)
+	if err != nil {
+		return nil, err
+	}
+
 	e := &engine{
-		ctx:        ctx,
-		conway:     conway.NewConway(cfg),
-		output:     protocol.Output{Cells: make([]protocol.Cell, ws/4)},
-		outputChan: make(chan []byte, 2),
+		ctx:         ctx,
+		conway:      c,
+		worldWidth:  cfg.WorldWidth(),
+		worldHeight: cfg.WorldHeight(),
+		history:     newHistory(cfg.HistoryDepth()),
+		collector:   conway.NewStatsCollector(),
+		autoPause:   cfg.AutoPause(),
+		frameTime:   frameTime(cfg.BroadcastFPS()),
+		output: protocol.Output{
+			Cells:       make([]protocol.Cell, cfg.WorldWidth()/4),
+			WorldWidth:  uint32(cfg.WorldWidth()),
+			WorldHeight: uint32(cfg.WorldHeight()),
+			Rule:        c.Rule().String(),
+		},
+		outputChan:   make(chan Frame, 2),
+		progressChan: make(chan protocol.Progress, 4),
 	}
 
+	if r, ok := c.(conway.ChangeRecorder); ok && cfg.HistoryDepth() != 0 {
+		r.RecordChanges(true)
+	}
 	e.speed.Store(100)
-	err := e.setSeed(seed)
+	err = e.setSeed(seed)
 	if err != nil {
 		log.Printf("error setting seed: %s", err)
 	}
+	e.history.reset(c)
+	e.period.reset(c)
+	e.recordStats()
 
 	e.generateOutput()
 
-	return e
+	return e, nil
 }
 
-func (e *engine) Output() <-chan []byte {
+func (e *engine) Census() conway.Census {
+	// a census takes long, so requests wait for the one running and share its result
+	// until the world changes
+	e.censusMutex.Lock()
+	defer e.censusMutex.Unlock()
+
+	e.mutex.Lock()
+	// fast-forwards change the generation without recording stats for each chunk
+	if e.census != nil && e.censusOf == e.version && e.census.Generation == e.generation {
+		census := *e.census
+		e.mutex.Unlock()
+		return census
+	}
+	cells := conway.CopyCells(e.conway, int(e.worldWidth), int(e.worldHeight))
+	generation, version := e.generation, e.version
+	e.mutex.Unlock()
+
+	// the islands are found and run in worlds of their own, the engine goes on meanwhile
+	census := cells.Islands().Census()
+	census.Generation = generation
+
+	e.mutex.Lock()
+	e.census, e.censusOf = &census, version
+	e.mutex.Unlock()
+	return census
+}
+
+func (e *engine) Output() <-chan Frame {
 	return e.outputChan
 }
 
+func (e *engine) Progress() <-chan protocol.Progress {
+	return e.progressChan
+}
+
 func (e *engine) Playing() bool {
 	return e.state.Load() == playing
 }
 
+func (e *engine) Rule() conway.Rule {
+	return e.conway.Rule()
+}
+
 func (e *engine) Speed() uint32 {
 	return e.speed.Load()
 }
 
+func (e *engine) Stats() []conway.Stats {
+	e.mutex.Lock()
+	defer e.mutex.Unlock()
+	e.collectStats()
+	return e.stats.all()
+}
+
+func (e *engine) LatestStats() conway.Stats {
+	e.mutex.Lock()
+	defer e.mutex.Unlock()
+	e.collectStats()
+	s, _ := e.stats.latest()
+	return s
+}
+
+func (e *engine) WatchStats(watch bool) {
+	if watch {
+		e.watchers.Add(1)
+	} else {
+		e.watchers.Add(-1)
+	}
+}
+
+func (e *engine) WorldHeight() uint {
+	return e.worldHeight
+}
+
+func (e *engine) WorldWidth() uint {
+	return e.worldWidth
+}
+
 func (e *engine) Start() {
 	ticker := time.NewTicker(e.speedAsDuration())
+	var frames <-chan time.Time // nil if there is an output for each generation
+	if e.frameTime != 0 {
+		t := time.NewTicker(e.frameTime)
+		defer t.Stop()
+		frames = t.C
+	}
+	clock := time.NewTicker(time.Second)
 	defer func() {
 		ticker.Stop()
+		clock.Stop()
+		e.mutex.Lock()
+		e.stopped = true
 		close(e.outputChan)
+		close(e.progressChan)
+		e.mutex.Unlock()
 	}()
 
+	pending := false // a generation was computed since the last output
+	stale := false   // a generation of a static world was computed since the last output
 	for {
 		select {
 		case <-e.ctx.Done():
 			return
 		case <-ticker.C:
-			if e.state.Load() == playing {
-				e.calcNextGen()
-				e.generateOutput()
+			if e.state.Load() == playing && !e.isDead() {
+				e.step()
+				switch {
+				case e.isStatic() && e.state.Load() == playing:
+					// only the generation and the ages change, the clock sends them
+					stale = true
+				case e.frameTime == 0:
+					e.generateOutput()
+				default:
+					pending = true
+				}
 			}
 			if e.speedChanged.Load() {
 				ticker.Reset(e.speedAsDuration())
 				e.speedChanged.Store(false)
 			}
+		case <-frames:
+			if pending {
+				e.generateOutput()
+				pending = false
+			}
+		case now := <-clock.C:
+			ran := e.runClockSchedules(now)
+			if (e.updateRate() || ran || stale) && !pending {
+				// tell the clients that the engine went idle or the world was changed
+				e.generateOutput()
+				stale = false
+			}
 		}
 	}
 }
 
-func (e *engine) SubmitMessage(b []byte) error {
+// step computes the next generations of a tick, which are as many as fit into
+// unthrottledTime if the speed is 0.
+func (e *engine) step() {
+	started := time.Now()
+	for {
+		e.calcNextGen()
+		if e.pauseIfPeriodic() {
+			return
+		}
+		if e.speed.Load() != 0 || time.Since(started) >= unthrottledTime || e.isDead() {
+			return
+		}
+	}
+}
+
+// updateRate computes the generations per second of the last second and reports
+// whether they changed to 0.
+func (e *engine) updateRate() bool {
+	e.mutex.Lock()
+	defer e.mutex.Unlock()
+	idle := e.stepped == 0 && e.rate != 0
+	e.rate = uint32(min(e.stepped, math.MaxUint32))
+	e.stepped = 0
+	return idle
+}
+
+func (e *engine) SubmitMessage(s *Session, b []byte) error {
 	msg, err := protocol.DecodeClientMessage(b)
 	if err != nil {
 		return fmt.Errorf("decode error: %w", err)
 	}
 
-	switch t := msg.(type) {
-	case *protocol.Command:
-		err = e.handleCommand(t)
-	case *protocol.SetCells:
-		err = e.handleSetCells(t)
-	case *protocol.SetSpeed:
-		err = e.handleSetSpeed(t)
-	}
-
+	err = e.handleMessage(s, msg)
 	if err != nil {
 		return fmt.Errorf("handle command error: %w", err)
 	}
+	if startsFastForward(msg) {
+		// the fast-forward generates the output once it is done
+		return nil
+	}
 
 	e.generateOutput()
 
 	return nil
 }
 
+func (e *engine) handleMessage(s *Session, msg protocol.ClientMessage) error {
+	if !allowedWhileFastForwarding(msg) && e.isFastForwarding() {
+		return ErrFastForwarding
+	}
+
+	switch t := msg.(type) {
+	case *protocol.Command:
+		return e.handleCommand(s, t)
+	case *protocol.SetCells:
+		return e.handleSetCells(s, t)
+	case *protocol.SetSpeed:
+		return e.handleSetSpeed(t)
+	case *protocol.SetTopology:
+		return e.handleSetTopology(t)
+	case *protocol.Soup:
+		return e.handleSoup(s, t)
+	case *protocol.KillCells:
+		return e.handleKillCells(s, t)
+	case *protocol.ClearRegion:
+		return e.handleClearRegion(s, t)
+	case *protocol.EditRegion:
+		return e.handleEditRegion(s, t)
+	case *protocol.PasteClipboard:
+		return e.handlePasteClipboard(s, t)
+	}
+	return nil
+}
+
 func (e *engine) calcNextGen() {
 	e.mutex.Lock()
 	defer e.mutex.Unlock()
 	e.conway.NextGen()
+	e.generation += 1
+	e.stepped += 1
+	e.history.push(e.conway, 1)
+	e.period.step(e.conway)
+	e.recordStats()
+	e.runDueSchedules(e.generation - 1)
+}
+
+// isDead reports whether the world is empty and stays empty, so there is nothing to
+// step and the output does not change.
+func (e *engine) isDead() bool {
+	e.mutex.Lock()
+	defer e.mutex.Unlock()
+	return e.period.period == 1 && e.conway.CellsCount() == 0
+}
+
+// isStatic reports whether the world does not change anymore besides the ages of its
+// cells.
+func (e *engine) isStatic() bool {
+	e.mutex.Lock()
+	defer e.mutex.Unlock()
+	return e.period.period == 1
+}
+
+// pauseIfPeriodic pauses the world if auto-pause is on and the world repeats, unless it
+// was paused for that before and played again since, and reports whether it did.
+func (e *engine) pauseIfPeriodic() bool {
+	e.mutex.Lock()
+	defer e.mutex.Unlock()
+	if !e.autoPause || e.autoPaused || e.period.period == 0 {
+		return false
+	}
+	e.autoPaused = true
+	e.state.Store(paused)
+	return true
+}
+
+// resetPeriod starts over detecting the period after the world was changed by other
+// means than a step. The caller must hold the mutex.
+func (e *engine) resetPeriod() {
+	e.period.reset(e.conway)
+	e.autoPaused = false
+}
+
+// recordStats adds the counts of the current generation to the time series. The other
+// stats look at all cells, so they are only collected once they are requested, like
+// the census. The caller must hold the mutex.
+func (e *engine) recordStats() {
+	e.stats.record(conway.CountStats(e.conway, e.generation))
+	e.collected = false
+	e.version += 1
+}
+
+// collectStats replaces the counts of the current generation with all of its stats,
+// unless that was done before. The caller must hold the mutex.
+func (e *engine) collectStats() {
+	if !e.collected {
+		e.stats.record(e.collector.Collect(e.conway, e.generation))
+		e.collected = true
+	}
+}
+
+// startOver resets the generation and the stats after the world was replaced. The
+// caller must hold the mutex.
+func (e *engine) startOver() {
+	e.generation = 0
+	e.stats.reset()
+	e.recordStats()
+}
+
+func (e *engine) restorePrevGen() error {
+	e.mutex.Lock()
+	defer e.mutex.Unlock()
+
+	cells, generations, ok := e.history.pop(e.conway)
+	if !ok {
+		return errors.New("no previous generation in history")
+	}
+	e.conway.Clear()
+	for _, c := range cells {
+		e.conway.SetCell(c.X, c.Y, c.Colour, c.Age, c.State)
+	}
+	e.generation -= min(generations, e.generation)
+	e.resetPeriod()
+	e.recordStats()
+
+	return nil
 }
 
 func (e *engine) generateOutput() {
@@ -142,10 +464,17 @@ func (e *engine) generateOutput() {
 		e.output.Cells[i].Y = y
 		e.output.Cells[i].Colour = colour
 		e.output.Cells[i].Age = age
+		e.output.Cells[i].State = cell.State()
 	}
 	e.output.CellsCount = uint32(cnt)
 	e.output.Playing = e.state.Load() == playing
 	e.output.Speed = uint16(e.speed.Load())
+	e.output.Topology = uint8(e.conway.Topology())
+	e.output.Period = e.period.period
+	e.output.Generation = e.generation
+	e.output.GenerationsPerSecond = e.rate
+	e.sequence += 1
+	e.output.Sequence = e.sequence
 
 	encodeSize := e.output.EncodeSize()
 	if uint32(cap(e.encodeBuffer)) < encodeSize {
@@ -153,27 +482,50 @@ func (e *engine) generateOutput() {
 	}
 	e.encodeBuffer = e.encodeBuffer[:encodeSize]
 	e.output.Encode(e.encodeBuffer)
-	out := append([]byte(nil), e.encodeBuffer...)
-	e.mutex.Unlock()
+	frame := Frame{Output: append([]byte(nil), e.encodeBuffer...)}
+	defer e.mutex.Unlock()
 
+	if e.watchers.Load() > 0 {
+		// collected once for all watchers of the room
+		e.collectStats()
+		s, _ := e.stats.latest()
+		var err error
+		if frame.Stats, err = json.Marshal(s); err != nil {
+			log.Printf("could not marshal stats: %s", err)
+		}
+	}
+
+	if e.stopped {
+		return
+	}
 	select {
-	case e.outputChan <- out:
+	case e.outputChan <- frame:
 	default:
 		log.Println("NOPE")
 	}
 }
 
-func (e *engine) handleCommand(c *protocol.Command) error {
+func (e *engine) handleCommand(s *Session, c *protocol.Command) error {
 	switch c.Cmd {
 	case protocol.Clear:
 		e.mutex.Lock()
+		before := e.beginEdit(e.wholeWorld())
 		e.conway.Clear()
+		e.endEdit(s, before)
+		e.history.reset(e.conway)
+		e.resetPeriod()
+		e.startOver()
 		e.mutex.Unlock()
 	case protocol.Next:
 		if e.state.Load() == playing {
 			return errors.New("cannot execute next command while playing")
 		}
 		e.calcNextGen()
+	case protocol.Previous:
+		if e.state.Load() == playing {
+			return errors.New("cannot execute previous command while playing")
+		}
+		return e.restorePrevGen()
 	case protocol.Pause:
 		if e.state.Load() == paused {
 			return errors.New("already paused")
@@ -186,28 +538,111 @@ func (e *engine) handleCommand(c *protocol.Command) error {
 		e.state.Store(playing)
 	case protocol.Randomise:
 		e.mutex.Lock()
-		e.conway.Randomise()
+		before := e.beginEdit(e.wholeWorld())
+		e.conway.Randomise(conway.RandomSoup())
+		e.endEdit(s, before)
+		e.history.reset(e.conway)
+		e.resetPeriod()
+		e.startOver()
 		e.mutex.Unlock()
+	case protocol.StepN, protocol.JumpTo:
+		return e.startFastForward(c)
+	case protocol.CancelFastForward:
+		return e.cancelFastForward()
+	case protocol.Undo:
+		return e.handleUndo(s)
+	case protocol.Redo:
+		return e.handleRedo(s)
 	}
 
 	return nil
 }
 
-func (e *engine) handleSetCells(sc *protocol.SetCells) error {
+func (e *engine) handleSetCells(s *Session, sc *protocol.SetCells) error {
 	e.mutex.Lock()
 	defer e.mutex.Unlock()
 
-	for i := range sc.Cells {
-		c := sc.Cells[i]
-		if !e.conway.CanSetCell(c.X, c.Y) {
-			return fmt.Errorf("cannot set cell at (%d, %d)", c.X, c.Y)
-		}
+	before := e.beginEdit(e.pasteFootprint(sc.Cells, sc.Mode))
+	err := e.paste(sc.Cells, sc.Mode)
+	if err != nil {
+		return err
+	}
+	e.endEdit(s, before)
+	e.history.reset(e.conway)
+	e.resetPeriod()
+	e.recordStats()
+
+	return nil
+}
+
+func (e *engine) handleKillCells(s *Session, kc *protocol.KillCells) error {
+	e.mutex.Lock()
+	defer e.mutex.Unlock()
+
+	before := e.beginEdit(footprint{coords: kc.Coords})
+	for _, c := range kc.Coords {
+		e.conway.KillCell(c.X, c.Y)
 	}
-	for i := range sc.Cells {
-		c := sc.Cells[i]
-		e.conway.SetCell(c.X, c.Y, c.Colour, 0)
+	e.endEdit(s, before)
+	e.history.reset(e.conway)
+	e.resetPeriod()
+	e.recordStats()
+
+	return nil
+}
+
+func (e *engine) handleClearRegion(s *Session, cr *protocol.ClearRegion) error {
+	if cr.Width == 0 || cr.Height == 0 {
+		return fmt.Errorf("cannot clear an empty region of %dx%d cells", cr.Width, cr.Height)
 	}
 
+	e.mutex.Lock()
+	defer e.mutex.Unlock()
+
+	// the region is clipped at the edges of the world
+	r := region{int(cr.X), int(cr.Y), int(cr.Width), int(cr.Height)}
+	r.width = max(min(r.width, int(e.worldWidth)-r.x), 0)
+	r.height = max(min(r.height, int(e.worldHeight)-r.y), 0)
+	before := e.beginEdit(footprint{regions: []region{r}})
+	e.conway.ClearRegion(cr.X, cr.Y, cr.Width, cr.Height)
+	e.endEdit(s, before)
+	e.history.reset(e.conway)
+	e.resetPeriod()
+	e.recordStats()
+
+	return nil
+}
+
+func (e *engine) handleSoup(s *Session, soup *protocol.Soup) error {
+	if soup.Density == 0 || soup.Density > 100 {
+		return fmt.Errorf("soup density must be between 1 and 100, got %d", soup.Density)
+	}
+	sym := conway.Symmetry(soup.Symmetry)
+	if !sym.Valid() {
+		return fmt.Errorf("unknown symmetry %d", soup.Symmetry)
+	}
+	if uint(soup.X) >= e.worldWidth || uint(soup.Y) >= e.worldHeight {
+		return fmt.Errorf("soup corner (%d, %d) lies outside of the world", soup.X, soup.Y)
+	}
+
+	e.mutex.Lock()
+	defer e.mutex.Unlock()
+	before := e.beginEdit(e.wholeWorld())
+	e.conway.Randomise(conway.Soup{
+		Seed:     soup.Seed,
+		Density:  float64(soup.Density) / 100,
+		X:        soup.X,
+		Y:        soup.Y,
+		Width:    soup.Width,
+		Height:   soup.Height,
+		Palette:  soup.Palette,
+		Symmetry: sym,
+	})
+	e.endEdit(s, before)
+	e.history.reset(e.conway)
+	e.resetPeriod()
+	e.startOver()
+
 	return nil
 }
 
@@ -222,6 +657,26 @@ func (e *engine) handleSetSpeed(sp *protocol.SetSpeed) error {
 	return nil
 }
 
+func (e *engine) handleSetTopology(st *protocol.SetTopology) error {
+	t := conway.Topology(st.Topology)
+	if !t.Valid() {
+		return fmt.Errorf("unknown topology %d", st.Topology)
+	}
+
+	e.mutex.Lock()
+	defer e.mutex.Unlock()
+	if t == e.conway.Topology() {
+		return errors.New("topology has not changed")
+	}
+	if err := e.conway.SetTopology(t); err != nil {
+		return err
+	}
+	e.resetPeriod()
+	e.recordStats()
+
+	return nil
+}
+
 func (e *engine) setSeed(seed []byte) error {
 	o := &protocol.Output{}
 	err := o.Decode(seed)
@@ -234,13 +689,38 @@ func (e *engine) setSeed(seed []byte) error {
 		e.state.Store(paused)
 	}
 	e.speed.Store(uint32(o.Speed))
+	// seeds that predate topologies keep the configured one
+	if t := conway.Topology(o.Topology); o.HasTopology && t.Valid() {
+		if err := e.conway.SetTopology(t); err != nil {
+			log.Printf("could not restore topology of seed: %s", err)
+		}
+	}
+	if o.Rule != "" && o.Rule != e.conway.Rule().String() {
+		log.Printf("seed was saved from a world with rule %s, restoring it with rule %s", o.Rule, e.conway.Rule())
+	}
+	if o.WorldWidth != 0 && (uint(o.WorldWidth) != e.worldWidth || uint(o.WorldHeight) != e.worldHeight) {
+		log.Printf("seed was saved from a world of %dx%d cells, restoring it into %dx%d cells", o.WorldWidth, o.WorldHeight, e.worldWidth, e.worldHeight)
+	}
+	e.generation = o.Generation
 	for i := range o.Cells {
 		c := o.Cells[i]
-		e.conway.SetCell(c.X, c.Y, c.Colour, c.Age)
+		if !e.conway.CanSetCell(c.X, c.Y) {
+			// the seed was saved from a larger world
+			continue
+		}
+		e.conway.SetCell(c.X, c.Y, c.Colour, c.Age, c.State)
 	}
 	return nil
 }
 
 func (e *engine) speedAsDuration() time.Duration {
-	return time.Duration(e.speed.Load()) * time.Millisecond
+	return max(time.Duration(e.speed.Load()), 1) * time.Millisecond
+}
+
+// frameTime returns the time between the outputs for the frames per second.
+func frameTime(fps uint) time.Duration {
+	if fps == 0 {
+		return 0
+	}
+	return time.Second / time.Duration(fps)
 }
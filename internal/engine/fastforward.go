@@ -0,0 +1,169 @@
+package engine
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/JackWithOneEye/conwaymore/internal/protocol"
+)
+
+const (
+	// fastForwardSlice is the longest time the world is stepped without releasing the
+	// mutex, so that other messages and cancellations get through.
+	fastForwardSlice = 20 * time.Millisecond
+	// fastForwardReport is the interval of the progress reports. Shorter runs are not
+	// reported at all.
+	fastForwardReport   = 250 * time.Millisecond
+	maxFastForwardChunk = 1 << 40
+)
+
+// ErrFastForwarding is returned for the messages that would change the world while it
+// is being fast-forwarded.
+var ErrFastForwarding = errors.New("cannot change the world while fast-forwarding")
+
+// fastForward is a run of generations computed without an output for each of them.
+type fastForward struct {
+	start  uint64 // generation the run started at
+	target uint64
+	cancel chan struct{}
+}
+
+func (e *engine) startFastForward(c *protocol.Command) error {
+	if e.state.Load() == playing {
+		return errors.New("cannot fast-forward while playing")
+	}
+
+	e.mutex.Lock()
+	defer e.mutex.Unlock()
+	if e.fastForward != nil {
+		return errors.New("already fast-forwarding")
+	}
+	target := c.Arg
+	if c.Cmd == protocol.StepN {
+		if c.Arg == 0 {
+			return errors.New("cannot step zero generations")
+		}
+		target = e.generation + c.Arg
+	} else if target <= e.generation {
+		return fmt.Errorf("cannot jump from generation %d back to %d", e.generation, target)
+	}
+
+	ff := &fastForward{start: e.generation, target: target, cancel: make(chan struct{})}
+	e.fastForward = ff
+	go e.runFastForward(ff)
+
+	return nil
+}
+
+func (e *engine) cancelFastForward() error {
+	e.mutex.Lock()
+	defer e.mutex.Unlock()
+	if e.fastForward == nil {
+		return errors.New("not fast-forwarding")
+	}
+	select {
+	case <-e.fastForward.cancel:
+		return errors.New("fast-forward already cancelled")
+	default:
+		close(e.fastForward.cancel)
+	}
+	return nil
+}
+
+// allowedWhileFastForwarding reports whether the message may be handled while the world
+// is being fast-forwarded, which holds for those that do not touch the world.
+func allowedWhileFastForwarding(msg protocol.ClientMessage) bool {
+	switch t := msg.(type) {
+	case *protocol.Command:
+		return t.Cmd == protocol.CancelFastForward
+	case *protocol.SetSpeed:
+		return true
+	case *protocol.EditRegion:
+		return t.Op == protocol.RegionCopy
+	}
+	return false
+}
+
+func startsFastForward(msg protocol.ClientMessage) bool {
+	switch t := msg.(type) {
+	case *protocol.Command:
+		return t.Cmd == protocol.StepN || t.Cmd == protocol.JumpTo
+	}
+	return false
+}
+
+func (e *engine) isFastForwarding() bool {
+	e.mutex.Lock()
+	defer e.mutex.Unlock()
+	return e.fastForward != nil
+}
+
+// runFastForward steps the world in slices of time until the target is reached or the
+// run is cancelled, and only generates an output at the end.
+func (e *engine) runFastForward(ff *fastForward) {
+	chunk := uint64(1)
+	last, reported := time.Now(), false // last report or the start of the run
+
+	for done := false; !done; {
+		select {
+		case <-ff.cancel:
+			done = true
+		case <-e.ctx.Done():
+			done = true
+		default:
+		}
+
+		e.mutex.Lock()
+		slice := time.Now()
+		for !done && e.generation < ff.target && time.Since(slice) < fastForwardSlice {
+			// the schedules that are due in between are run on the way
+			n := min(chunk, ff.target-e.generation, e.nextDue()-e.generation)
+			t := time.Now()
+			from := e.generation
+			// the generations are compared, as only single steps are recorded
+			e.history.sync(e.conway)
+			e.conway.Advance(n)
+			e.generation += n
+			e.stepped += n
+			if e.runDueSchedules(from) {
+				// the history starts over with the changes of the schedule
+				ff.start = e.generation
+			}
+			// HashLife advances many generations about as fast as a few, so the chunks
+			// grow as long as they are quick
+			if time.Since(t) < fastForwardSlice/8 {
+				chunk = min(chunk*2, maxFastForwardChunk)
+			}
+		}
+		done = done || e.generation >= ff.target
+		progress := protocol.Progress{Generation: e.generation, Target: ff.target, Done: done}
+		if done {
+			if e.generation > ff.start {
+				e.history.push(e.conway, e.generation-ff.start)
+				e.resetPeriod()
+				e.recordStats()
+			}
+			e.fastForward = nil
+		}
+		if (reported && done) || time.Since(last) >= fastForwardReport {
+			e.reportProgress(progress)
+			last, reported = time.Now(), true
+		}
+		e.mutex.Unlock()
+	}
+
+	e.generateOutput()
+}
+
+// reportProgress sends the progress of a fast-forward, dropping it if the channel is
+// full. The caller must hold the mutex.
+func (e *engine) reportProgress(p protocol.Progress) {
+	if e.stopped {
+		return
+	}
+	select {
+	case e.progressChan <- p:
+	default:
+	}
+}
@@ -0,0 +1,211 @@
+package engine
+
+import (
+	"cmp"
+	"iter"
+	"math"
+	"slices"
+
+	"github.com/JackWithOneEye/conwaymore/internal/conway"
+	"github.com/JackWithOneEye/conwaymore/internal/protocol"
+)
+
+// change is the cell at a position before a step, or its absence.
+type change struct {
+	cell   protocol.Cell
+	absent bool
+}
+
+// delta turns a generation back into the one before it. Cells that merely aged by the
+// number of generations stepped are left out, so only the cells that were born, died
+// or changed colour or state are stored.
+type delta struct {
+	generations uint64
+	changes     []change // sorted by position
+}
+
+// history keeps the deltas of the last steps in a ring buffer. The deltas of single
+// steps are taken from the backend if it records them, which all but HashLife do,
+// otherwise the generations are compared.
+type history struct {
+	deltas  []delta
+	start   int
+	size    int
+	current []protocol.Cell // cells of the current generation, sorted by position
+	stale   bool            // current lags behind, as it is only needed to compare
+}
+
+func newHistory(depth uint) *history {
+	return &history{deltas: make([]delta, depth)}
+}
+
+// push records that the world was advanced by the given number of generations.
+func (h *history) push(c conway.Conway, generations uint64) {
+	if len(h.deltas) == 0 {
+		return
+	}
+
+	var changes []change
+	if r, ok := c.(conway.ChangeRecorder); ok && generations == 1 {
+		if recorded, ok := r.RecordedChanges(); ok {
+			changes = make([]change, len(recorded))
+			for i, rc := range recorded {
+				changes[i] = change{protocol.Cell{X: rc.X, Y: rc.Y, Colour: rc.Colour, Age: rc.Age, State: rc.State}, rc.Born}
+			}
+			slices.SortFunc(changes, func(a, b change) int {
+				return compareCells(a.cell, b.cell)
+			})
+			h.add(delta{generations, changes})
+			h.stale = true
+			if !r.Recording() {
+				// the next generation is compared against this one
+				h.sync(c)
+			}
+			return
+		}
+	}
+	if h.stale {
+		// the generation before is unknown, so the history starts over
+		h.reset(c)
+		h.sync(c)
+		return
+	}
+
+	next := sortedCells(c.Cells())
+	merge(h.current, next, func(prev, cell *protocol.Cell) {
+		switch {
+		case prev == nil:
+			changes = append(changes, change{cell: *cell, absent: true})
+		case cell == nil || *prev != aged(*cell, generations):
+			changes = append(changes, change{cell: *prev})
+		}
+	})
+	h.add(delta{generations, changes})
+	h.current = next
+}
+
+// add appends the delta to the ring buffer, dropping the oldest one if it is full.
+func (h *history) add(d delta) {
+	i := (h.start + h.size) % len(h.deltas)
+	if h.size == len(h.deltas) {
+		h.start = (h.start + 1) % len(h.deltas)
+	} else {
+		h.size += 1
+	}
+	h.deltas[i] = d
+}
+
+// sync takes the cells of the current generation, which the next push of many
+// generations at once compares against.
+func (h *history) sync(c conway.Conway) {
+	if h.stale && len(h.deltas) != 0 {
+		h.current = sortedCells(c.Cells())
+		h.stale = false
+	}
+}
+
+// pop removes the last delta and returns the cells of the generation before the
+// current one of the world and the number of generations it goes back.
+func (h *history) pop(c conway.Conway) ([]protocol.Cell, uint64, bool) {
+	if h.size == 0 {
+		return nil, 0, false
+	}
+	h.sync(c)
+	h.size -= 1
+	i := (h.start + h.size) % len(h.deltas)
+	d := h.deltas[i]
+	h.deltas[i] = delta{}
+
+	prev := make([]protocol.Cell, 0, len(h.current))
+	j := 0
+	for _, cell := range h.current {
+		for ; j < len(d.changes) && before(d.changes[j].cell, cell); j++ {
+			prev = append(prev, d.changes[j].cell)
+		}
+		if j < len(d.changes) && samePosition(d.changes[j].cell, cell) {
+			if !d.changes[j].absent {
+				prev = append(prev, d.changes[j].cell)
+			}
+			j += 1
+			continue
+		}
+		prev = append(prev, aged(cell, d.generations))
+	}
+	for ; j < len(d.changes); j++ {
+		prev = append(prev, d.changes[j].cell)
+	}
+
+	h.current = prev
+	return prev, d.generations, true
+}
+
+// reset drops all deltas, as the world was changed by other means than a step.
+func (h *history) reset(c conway.Conway) {
+	if len(h.deltas) == 0 {
+		return
+	}
+	clear(h.deltas)
+	h.start = 0
+	h.size = 0
+	if r, ok := c.(conway.ChangeRecorder); ok && r.Recording() {
+		// taken once it is needed
+		h.current = nil
+		h.stale = true
+	} else {
+		h.current = sortedCells(c.Cells())
+		h.stale = false
+	}
+}
+
+// aged returns the cell as it most likely was the given number of generations ago.
+func aged(cell protocol.Cell, generations uint64) protocol.Cell {
+	if cell.State == 0 && cell.Age < math.MaxUint16 {
+		cell.Age -= uint16(min(uint64(cell.Age), generations))
+	}
+	return cell
+}
+
+func before(a, b protocol.Cell) bool {
+	return a.Y < b.Y || (a.Y == b.Y && a.X < b.X)
+}
+
+func samePosition(a, b protocol.Cell) bool {
+	return a.X == b.X && a.Y == b.Y
+}
+
+// merge calls f for each position of the sorted cells a and b, with nil for a missing cell.
+func merge(a, b []protocol.Cell, f func(a, b *protocol.Cell)) {
+	i, j := 0, 0
+	for i < len(a) || j < len(b) {
+		switch {
+		case j == len(b) || (i < len(a) && before(a[i], b[j])):
+			f(&a[i], nil)
+			i += 1
+		case i == len(a) || before(b[j], a[i]):
+			f(nil, &b[j])
+			j += 1
+		default:
+			f(&a[i], &b[j])
+			i += 1
+			j += 1
+		}
+	}
+}
+
+func sortedCells(cells iter.Seq2[uint, conway.Cell]) []protocol.Cell {
+	var sorted []protocol.Cell
+	for _, cell := range cells {
+		x, y, colour, age := cell.Values()
+		sorted = append(sorted, protocol.Cell{X: x, Y: y, Colour: colour, Age: age, State: cell.State()})
+	}
+	// the dense implementations already return their cells row by row
+	if !slices.IsSortedFunc(sorted, compareCells) {
+		slices.SortFunc(sorted, compareCells)
+	}
+	return sorted
+}
+
+// compareCells orders cells row by row.
+func compareCells(a, b protocol.Cell) int {
+	return cmp.Or(cmp.Compare(a.Y, b.Y), cmp.Compare(a.X, b.X))
+}
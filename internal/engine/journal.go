@@ -0,0 +1,207 @@
+package engine
+
+import (
+	"errors"
+	"slices"
+
+	"github.com/JackWithOneEye/conwaymore/internal/conway"
+	"github.com/JackWithOneEye/conwaymore/internal/protocol"
+)
+
+// maxJournalCells is the number of changed cells the journal of a session keeps at
+// most. The oldest edits are dropped first.
+const maxJournalCells = 1 << 20
+
+// editedCell is a position an edit changed, with its cell before and after the edit.
+type editedCell struct {
+	before change
+	after  change
+}
+
+// edit is the change of the world by a message of a session, sorted by position.
+type edit []editedCell
+
+// journal keeps the edits of a session to undo and redo them.
+type journal struct {
+	undo  []edit
+	redo  []edit
+	cells int // in both stacks
+}
+
+// record adds an edit that can be undone. Edits undone before cannot be redone anymore.
+func (j *journal) record(ed edit) {
+	if len(ed) == 0 {
+		return
+	}
+	for _, r := range j.redo {
+		j.cells -= len(r)
+	}
+	j.redo = nil
+	j.undo = append(j.undo, ed)
+	j.cells += len(ed)
+	for j.cells > maxJournalCells && len(j.undo) > 0 {
+		j.cells -= len(j.undo[0])
+		j.undo = j.undo[1:]
+	}
+}
+
+// diff returns the edit that turned the sorted cells before into the sorted cells after.
+// Cells that merely aged are not edited.
+func diff(before, after []protocol.Cell) edit {
+	var ed edit
+	merge(before, after, func(b, a *protocol.Cell) {
+		switch {
+		case b == nil:
+			ed = append(ed, editedCell{before: change{cell: *a, absent: true}, after: change{cell: *a}})
+		case a == nil:
+			ed = append(ed, editedCell{before: change{cell: *b}, after: change{cell: *b, absent: true}})
+		case !sameContents(*b, *a):
+			ed = append(ed, editedCell{before: change{cell: *b}, after: change{cell: *a}})
+		}
+	})
+	return ed
+}
+
+// sameContents reports whether the cells are equal besides their age.
+func sameContents(a, b protocol.Cell) bool {
+	return a.X == b.X && a.Y == b.Y && a.Colour == b.Colour && a.State == b.State
+}
+
+// footprint is the positions an edit may change: single cells and regions.
+type footprint struct {
+	coords  []protocol.Coord
+	regions []region
+}
+
+// pendingEdit is an edit that was begun, with the cells of its footprint before it.
+type pendingEdit struct {
+	footprint footprint
+	before    []protocol.Cell
+}
+
+// beginEdit takes the cells of the footprint of an edit of the session, which is
+// recorded by endEdit. The caller must hold the mutex.
+func (e *engine) beginEdit(f footprint) pendingEdit {
+	return pendingEdit{f, e.cellsIn(f)}
+}
+
+// endEdit records the edit of the session since beginEdit. The caller must hold the
+// mutex.
+func (e *engine) endEdit(s *Session, p pendingEdit) {
+	s.journal.record(diff(p.before, e.cellsIn(p.footprint)))
+}
+
+// wholeWorld returns the footprint of an edit that may change any cell.
+func (e *engine) wholeWorld() footprint {
+	return footprint{regions: []region{{0, 0, int(e.worldWidth), int(e.worldHeight)}}}
+}
+
+// cellsIn returns the cells of the footprint, sorted by position. Regions are looked
+// up cell by cell unless they are larger than the population of the world. The caller
+// must hold the mutex.
+func (e *engine) cellsIn(f footprint) []protocol.Cell {
+	var cells []protocol.Cell
+	add := func(cell conway.Cell) {
+		x, y, colour, age := cell.Values()
+		cells = append(cells, protocol.Cell{X: x, Y: y, Colour: colour, Age: age, State: cell.State()})
+	}
+	for _, c := range f.coords {
+		if cell, ok := e.conway.CellAt(c.X, c.Y); ok {
+			add(cell)
+		}
+	}
+
+	var area int
+	for _, r := range f.regions {
+		area += r.width * r.height
+	}
+	if uint(area) > e.conway.CellsCount() {
+		for _, cell := range e.conway.Cells() {
+			x, y, _, _ := cell.Values()
+			if slices.ContainsFunc(f.regions, func(r region) bool { return r.contains(int(x), int(y)) }) {
+				add(cell)
+			}
+		}
+	} else {
+		for _, r := range f.regions {
+			for y := r.y; y < r.y+r.height; y++ {
+				for x := r.x; x < r.x+r.width; x++ {
+					if cell, ok := e.conway.CellAt(uint16(x), uint16(y)); ok {
+						add(cell)
+					}
+				}
+			}
+		}
+	}
+
+	// positions may be part of the footprint more than once
+	slices.SortFunc(cells, compareCells)
+	return slices.CompactFunc(cells, samePosition)
+}
+
+func (e *engine) handleUndo(s *Session) error {
+	return e.replay(s, &s.journal.undo, &s.journal.redo, true)
+}
+
+func (e *engine) handleRedo(s *Session) error {
+	return e.replay(s, &s.journal.redo, &s.journal.undo, false)
+}
+
+// replay reverts the last edit of one stack of the journal and moves it to the other.
+// An edit whose cells were changed since cannot be reverted anymore and is dropped.
+func (e *engine) replay(s *Session, from, to *[]edit, undo bool) error {
+	if len(*from) == 0 {
+		if undo {
+			return errors.New("nothing to undo")
+		}
+		return errors.New("nothing to redo")
+	}
+
+	e.mutex.Lock()
+	defer e.mutex.Unlock()
+
+	ed := (*from)[len(*from)-1]
+	*from = (*from)[:len(*from)-1]
+	expected, target := func(c editedCell) change { return c.after }, func(c editedCell) change { return c.before }
+	if !undo {
+		expected, target = target, expected
+	}
+
+	if !e.inState(ed, expected) {
+		s.journal.cells -= len(ed)
+		return errors.New("the cells of the edit were changed since")
+	}
+	for _, c := range ed {
+		t := target(c)
+		if t.absent {
+			e.conway.KillCell(t.cell.X, t.cell.Y)
+		} else {
+			e.conway.SetCell(t.cell.X, t.cell.Y, t.cell.Colour, t.cell.Age, t.cell.State)
+		}
+	}
+	*to = append(*to, ed)
+	e.history.reset(e.conway)
+	e.resetPeriod()
+	e.recordStats()
+
+	return nil
+}
+
+// inState reports whether the cells of the edit are in the expected state, besides
+// their age. The caller must hold the mutex.
+func (e *engine) inState(ed edit, expected func(editedCell) change) bool {
+	for _, c := range ed {
+		want := expected(c)
+		cell, found := e.conway.CellAt(want.cell.X, want.cell.Y)
+		if found == want.absent {
+			return false
+		}
+		if found {
+			x, y, colour, _ := cell.Values()
+			if !sameContents(protocol.Cell{X: x, Y: y, Colour: colour, State: cell.State()}, want.cell) {
+				return false
+			}
+		}
+	}
+	return true
+}
@@ -0,0 +1,204 @@
+package engine
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"regexp"
+	"slices"
+	"strings"
+	"sync"
+
+	"github.com/JackWithOneEye/conwaymore/internal/conway"
+)
+
+// DefaultRoom is the name of the room of the world configured for the server, which
+// cannot be deleted.
+const DefaultRoom = "default"
+
+var roomName = regexp.MustCompile(`^[a-z0-9][a-z0-9_-]{0,31}$`)
+
+// ManagerConfig is the configuration of the server with the limits of the rooms, which
+// anyone can create.
+type ManagerConfig interface {
+	EngineConfig
+	// MaxRooms caps the number of rooms besides the default one.
+	MaxRooms() uint
+	// MaxRoomSize caps the width and height of the worlds of rooms. Rooms without a
+	// size of their own take the one of the server.
+	MaxRoomSize() uint
+}
+
+// RoomConfig overrides the configuration of the server for the world of a room. Empty
+// fields keep the configuration of the server.
+type RoomConfig struct {
+	Rule     string `json:"rule,omitempty"`
+	Topology string `json:"topology,omitempty"`
+	Width    uint   `json:"width,omitempty"`
+	Height   uint   `json:"height,omitempty"`
+	Speed    uint16 `json:"speed,omitempty"` // ms per generation
+}
+
+// Room is a world of its own with its own engine.
+type Room struct {
+	Name   string
+	Config RoomConfig
+	Engine Engine
+	ctx    context.Context
+	cancel context.CancelFunc
+}
+
+// Done is closed once the room was deleted or the server shuts down.
+func (r *Room) Done() <-chan struct{} {
+	return r.ctx.Done()
+}
+
+// Manager hosts the engines of many independent rooms.
+type Manager interface {
+	// Create starts the engine of a new room, restored from the seed if there is one.
+	Create(name string, rc RoomConfig, seed []byte) (*Room, error)
+	// Delete stops the engine of a room.
+	Delete(name string) error
+	Get(name string) (*Room, bool)
+	// List returns all rooms, ordered by name.
+	List() []*Room
+}
+
+type manager struct {
+	cfg   ManagerConfig
+	ctx   context.Context
+	rooms map[string]*Room
+	mutex sync.RWMutex
+}
+
+func NewManager(cfg ManagerConfig, ctx context.Context) Manager {
+	return &manager{
+		cfg:   cfg,
+		ctx:   ctx,
+		rooms: make(map[string]*Room),
+	}
+}
+
+func (m *manager) Create(name string, rc RoomConfig, seed []byte) (*Room, error) {
+	if !roomName.MatchString(name) {
+		return nil, fmt.Errorf("invalid room name %q, use up to 32 lowercase letters, digits, '-' and '_'", name)
+	}
+	if err := m.validate(rc); err != nil {
+		return nil, fmt.Errorf("could not create room %q: %w", name, err)
+	}
+
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	if _, ok := m.rooms[name]; ok {
+		return nil, fmt.Errorf("room %q already exists", name)
+	}
+	others := len(m.rooms)
+	if _, ok := m.rooms[DefaultRoom]; ok {
+		others -= 1
+	}
+	if name != DefaultRoom && uint(others) >= m.cfg.MaxRooms() {
+		return nil, fmt.Errorf("cannot create more than %d rooms", m.cfg.MaxRooms())
+	}
+
+	ctx, cancel := context.WithCancel(m.ctx)
+	e, err := NewEngine(&roomEngineConfig{m.cfg, rc}, seed, ctx)
+	if err != nil {
+		cancel()
+		return nil, fmt.Errorf("could not create room %q: %w", name, err)
+	}
+	if rc.Speed != 0 && len(seed) == 0 {
+		// seeds bring their own speed
+		e.(*engine).speed.Store(uint32(rc.Speed))
+	}
+
+	r := &Room{Name: name, Config: rc, Engine: e, ctx: ctx, cancel: cancel}
+	m.rooms[name] = r
+	go e.Start()
+
+	return r, nil
+}
+
+func (m *manager) Delete(name string) error {
+	if name == DefaultRoom {
+		return errors.New("cannot delete the default room")
+	}
+
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	r, ok := m.rooms[name]
+	if !ok {
+		return fmt.Errorf("room %q does not exist", name)
+	}
+	r.cancel()
+	delete(m.rooms, name)
+
+	return nil
+}
+
+func (m *manager) Get(name string) (*Room, bool) {
+	m.mutex.RLock()
+	defer m.mutex.RUnlock()
+	r, ok := m.rooms[name]
+	return r, ok
+}
+
+func (m *manager) List() []*Room {
+	m.mutex.RLock()
+	defer m.mutex.RUnlock()
+	rooms := make([]*Room, 0, len(m.rooms))
+	for _, r := range m.rooms {
+		rooms = append(rooms, r)
+	}
+	slices.SortFunc(rooms, func(a, b *Room) int {
+		return strings.Compare(a.Name, b.Name)
+	})
+	return rooms
+}
+
+// validate checks the overrides of a room against the limits of the server. Rules are
+// only taken from their notation or the built-in rule tables, never from files.
+func (m *manager) validate(rc RoomConfig) error {
+	if rc.Rule != "" {
+		if _, err := conway.ParseRule(rc.Rule); err != nil {
+			return err
+		}
+	}
+	if maxSize := m.cfg.MaxRoomSize(); rc.Width > maxSize || rc.Height > maxSize {
+		return fmt.Errorf("world size %dx%d exceeds the maximum of %dx%d", rc.Width, rc.Height, maxSize, maxSize)
+	}
+	return nil
+}
+
+// roomEngineConfig is the configuration of the server with the overrides of a room.
+type roomEngineConfig struct {
+	EngineConfig
+	room RoomConfig
+}
+
+func (c *roomEngineConfig) Rule() string {
+	if c.room.Rule != "" {
+		return c.room.Rule
+	}
+	return c.EngineConfig.Rule()
+}
+
+func (c *roomEngineConfig) Topology() string {
+	if c.room.Topology != "" {
+		return c.room.Topology
+	}
+	return c.EngineConfig.Topology()
+}
+
+func (c *roomEngineConfig) WorldHeight() uint {
+	if c.room.Height != 0 {
+		return c.room.Height
+	}
+	return c.EngineConfig.WorldHeight()
+}
+
+func (c *roomEngineConfig) WorldWidth() uint {
+	if c.room.Width != 0 {
+		return c.room.Width
+	}
+	return c.EngineConfig.WorldWidth()
+}
@@ -0,0 +1,145 @@
+package engine
+
+import (
+	"fmt"
+	"slices"
+
+	"github.com/JackWithOneEye/conwaymore/internal/protocol"
+)
+
+// region is a rectangle of the world that does not cross its edges.
+type region struct {
+	x, y, width, height int
+}
+
+func (r region) contains(x, y int) bool {
+	return x >= r.x && x < r.x+r.width && y >= r.y && y < r.y+r.height
+}
+
+// paste places cells in the world as the paste mode demands. The caller must hold the
+// mutex.
+func (e *engine) paste(cells []protocol.Cell, mode protocol.PasteMode) error {
+	for _, c := range cells {
+		if uint(c.X) >= e.worldWidth || uint(c.Y) >= e.worldHeight {
+			return fmt.Errorf("cell (%d, %d) lies outside the world", c.X, c.Y)
+		}
+	}
+
+	switch mode {
+	case protocol.PasteReject:
+		for _, c := range cells {
+			if !e.conway.CanSetCell(c.X, c.Y) {
+				return fmt.Errorf("cannot set cell at (%d, %d)", c.X, c.Y)
+			}
+		}
+		for _, c := range cells {
+			e.conway.SetCell(c.X, c.Y, c.Colour, 0, c.State)
+		}
+	case protocol.PasteOr:
+		for _, c := range cells {
+			if e.conway.CanSetCell(c.X, c.Y) {
+				e.conway.SetCell(c.X, c.Y, c.Colour, 0, c.State)
+			}
+		}
+	case protocol.PasteOverwrite:
+		for _, r := range e.boundingRegions(cells) {
+			e.conway.ClearRegion(uint16(r.x), uint16(r.y), uint16(r.width), uint16(r.height))
+		}
+		for _, c := range cells {
+			e.conway.SetCell(c.X, c.Y, c.Colour, 0, c.State)
+		}
+	case protocol.PasteXor:
+		for _, c := range cells {
+			if e.conway.CanSetCell(c.X, c.Y) {
+				e.conway.SetCell(c.X, c.Y, c.Colour, 0, c.State)
+			} else {
+				e.conway.KillCell(c.X, c.Y)
+			}
+		}
+	case protocol.PasteAnd:
+		keep := make(map[protocol.Coord]struct{}, len(cells))
+		for _, c := range cells {
+			keep[protocol.Coord{X: c.X, Y: c.Y}] = struct{}{}
+		}
+		regions := e.boundingRegions(cells)
+		var kill []protocol.Coord
+		for _, cell := range e.conway.Cells() {
+			x, y, _, _ := cell.Values()
+			if _, ok := keep[protocol.Coord{X: x, Y: y}]; ok {
+				continue
+			}
+			if slices.ContainsFunc(regions, func(r region) bool { return r.contains(int(x), int(y)) }) {
+				kill = append(kill, protocol.Coord{X: x, Y: y})
+			}
+		}
+		for _, c := range kill {
+			e.conway.KillCell(c.X, c.Y)
+		}
+	default:
+		return fmt.Errorf("unknown paste mode %d", mode)
+	}
+	return nil
+}
+
+// pasteFootprint returns the positions pasting the cells in the mode may change.
+func (e *engine) pasteFootprint(cells []protocol.Cell, mode protocol.PasteMode) footprint {
+	f := footprint{coords: make([]protocol.Coord, len(cells))}
+	for i, c := range cells {
+		if uint(c.X) >= e.worldWidth || uint(c.Y) >= e.worldHeight {
+			// paste rejects the cells, so nothing changes
+			return footprint{}
+		}
+		f.coords[i] = protocol.Coord{X: c.X, Y: c.Y}
+	}
+	if mode == protocol.PasteOverwrite || mode == protocol.PasteAnd {
+		// cells of the bounding box are killed
+		f.regions = e.boundingRegions(cells)
+	}
+	return f
+}
+
+// boundingRegions returns the bounding box of the cells, split into regions where it
+// crosses the edges of the world.
+func (e *engine) boundingRegions(cells []protocol.Cell) []region {
+	if len(cells) == 0 {
+		return nil
+	}
+	xs, ys := make([]int, len(cells)), make([]int, len(cells))
+	for i, c := range cells {
+		xs[i], ys[i] = int(c.X), int(c.Y)
+	}
+
+	var regions []region
+	for _, x := range splitSpan(shortestSpan(xs, int(e.worldWidth))) {
+		for _, y := range splitSpan(shortestSpan(ys, int(e.worldHeight))) {
+			regions = append(regions, region{x[0], y[0], x[1], y[1]})
+		}
+	}
+	return regions
+}
+
+// shortestSpan returns the shortest interval of a circle of the given size that covers
+// all values, by leaving out the largest gap between them. Cells placed across an edge
+// of the world wrap around it, so their bounding box is found on the circle. The
+// interval may reach beyond the size.
+func shortestSpan(values []int, size int) (start, length, circle int) {
+	slices.Sort(values)
+	values = slices.Compact(values)
+
+	gap, start := size-values[len(values)-1]+values[0], values[0]
+	for i := 1; i < len(values); i++ {
+		if g := values[i] - values[i-1]; g > gap {
+			gap, start = g, values[i]
+		}
+	}
+	return start, size - gap + 1, size
+}
+
+// splitSpan splits an interval of a circle into the pairs of start and length that do
+// not cross the end of the circle.
+func splitSpan(start, length, circle int) [][2]int {
+	if start+length <= circle {
+		return [][2]int{{start, length}}
+	}
+	return [][2]int{{start, circle - start}, {0, start + length - circle}}
+}
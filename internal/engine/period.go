@@ -0,0 +1,122 @@
+package engine
+
+import (
+	"github.com/JackWithOneEye/conwaymore/internal/conway"
+)
+
+// maxPeriod is the longest period of a world that is detected for sure.
+const maxPeriod = 256
+
+// hashedCells is the number of cells above which the world is no longer hashed each
+// generation, but only every few generations, so that big worlds stay cheap to step.
+const hashedCells = 1 << 12
+
+// maxHashInterval keeps the periods that are found below math.MaxUint16.
+const maxHashInterval = maxPeriod / 2
+
+// periodDetector keeps the hashes of the last generations to detect worlds that repeat.
+// As the world only depends on its previous generation, a world that equals one of
+// the last P generations repeats with period P forever.
+//
+// Big worlds are sampled every few generations. Once a sample repeats, the world is
+// known to repeat with a multiple of its period, and the period itself is found by
+// hashing each generation until the world returns to the repeated sample.
+type periodDetector struct {
+	hashes   [maxPeriod]uint64 // ring buffer of the samples, the newest at next-1
+	next     int
+	size     int
+	interval int    // generations between the samples, a power of two
+	skipped  int    // generations since the last sample
+	multiple int    // of the period found by the samples, 0 until a sample repeated
+	target   uint64 // hash of the sample that repeated
+	since    int    // generations since the sample that repeated
+	period   uint16
+}
+
+// step observes the world after it was advanced by one generation and returns the
+// period of the world, or 0 if it has not repeated yet.
+func (d *periodDetector) step(c conway.Conway) uint16 {
+	switch {
+	case d.period != 0:
+	case d.multiple != 0:
+		// the world returns to the sample after exactly one period
+		d.since += 1
+		if d.since == d.multiple || worldHash(c) == d.target {
+			d.period = uint16(d.since)
+		}
+	default:
+		d.skipped += 1
+		if d.skipped >= d.interval {
+			d.sample(c)
+		}
+	}
+	return d.period
+}
+
+// reset forgets all generations, as the world was changed by other means than a step,
+// and starts over with a sample of the changed world.
+func (d *periodDetector) reset(c conway.Conway) {
+	d.size = 0
+	d.multiple = 0
+	d.period = 0
+	d.interval = hashInterval(c.CellsCount())
+	d.sample(c)
+}
+
+// sample adds the hash of the world to the ring buffer and looks for an earlier
+// sample that equals it.
+func (d *periodDetector) sample(c conway.Conway) {
+	d.skipped = 0
+	if interval := hashInterval(c.CellsCount()); interval > d.interval || interval < d.interval/4 {
+		// samples taken at other intervals cannot be compared, the margin keeps
+		// oscillating worlds from switching back and forth
+		d.interval = interval
+		d.size = 0
+	}
+
+	hash := worldHash(c)
+	for p := 1; p <= d.size; p++ {
+		if d.hashes[(d.next-p+maxPeriod)%maxPeriod] != hash {
+			continue
+		}
+		if d.interval == 1 {
+			d.period = uint16(p)
+		} else {
+			d.multiple, d.target, d.since = p*d.interval, hash, 0
+		}
+		break
+	}
+	d.hashes[d.next] = hash
+	d.next = (d.next + 1) % maxPeriod
+	d.size = min(d.size+1, maxPeriod)
+}
+
+// hashInterval returns the number of generations between the samples of a world with
+// the given number of cells.
+func hashInterval(cells uint) int {
+	interval := 1
+	for interval < maxHashInterval && uint(interval)*hashedCells < cells {
+		interval *= 2
+	}
+	return interval
+}
+
+// worldHash returns a hash of the positions and states of all cells. Colour and age do
+// not change how the world evolves, so they are left out. The hashes of the cells are
+// summed up, as the order of the cells is not defined.
+func worldHash(c conway.Conway) uint64 {
+	var sum uint64
+	for _, cell := range c.Cells() {
+		x, y, _, _ := cell.Values()
+		sum += mix(uint64(x)<<24 | uint64(y)<<8 | uint64(cell.State()))
+	}
+	return sum
+}
+
+// mix is the finaliser of SplitMix64.
+func mix(v uint64) uint64 {
+	v += 0x9e3779b97f4a7c15
+	v = (v ^ (v >> 30)) * 0xbf58476d1ce4e5b9
+	v = (v ^ (v >> 27)) * 0x94d049bb133111eb
+	return v ^ (v >> 31)
+}
@@ -0,0 +1,170 @@
+package engine
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/JackWithOneEye/conwaymore/internal/protocol"
+)
+
+// transform maps the position of a cell within a region of width x height cells to
+// its position within the transformed region.
+type transform func(x, y, width, height int) (int, int)
+
+var transforms = map[protocol.RegionOp]transform{
+	protocol.RegionRotateCW:       func(x, y, _, h int) (int, int) { return h - 1 - y, x },
+	protocol.RegionRotateCCW:      func(x, y, w, _ int) (int, int) { return y, w - 1 - x },
+	protocol.RegionRotate180:      func(x, y, w, h int) (int, int) { return w - 1 - x, h - 1 - y },
+	protocol.RegionFlipHorizontal: func(x, y, w, _ int) (int, int) { return w - 1 - x, y },
+	protocol.RegionFlipVertical:   func(x, y, _, h int) (int, int) { return x, h - 1 - y },
+}
+
+func (e *engine) handleEditRegion(s *Session, er *protocol.EditRegion) error {
+	if er.Width == 0 || er.Height == 0 || uint(er.Width) > e.worldWidth || uint(er.Height) > e.worldHeight {
+		return fmt.Errorf("region of %dx%d cells does not fit into the world", er.Width, er.Height)
+	}
+	if uint(er.X) >= e.worldWidth || uint(er.Y) >= e.worldHeight {
+		return fmt.Errorf("region corner (%d, %d) lies outside of the world", er.X, er.Y)
+	}
+
+	e.mutex.Lock()
+	defer e.mutex.Unlock()
+
+	x, y, w, h := int(er.X), int(er.Y), int(er.Width), int(er.Height)
+	cells := e.selection(x, y, w, h)
+	if er.Op == protocol.RegionCopy {
+		s.clipboard = clipboard{cells, w, h}
+		return nil
+	}
+
+	// the rectangle the cells end up in
+	tx, ty, tw, th := x, y, w, h
+	switch er.Op {
+	case protocol.RegionCut, protocol.RegionRecolour:
+	case protocol.RegionMove:
+		tx, ty = e.wrap(x+int(er.DX), y+int(er.DY))
+	default:
+		if _, ok := transforms[er.Op]; !ok {
+			return fmt.Errorf("unknown region operation %d", er.Op)
+		}
+		if er.Op == protocol.RegionRotateCW || er.Op == protocol.RegionRotateCCW {
+			tw, th = h, w
+			if uint(tw) > e.worldWidth || uint(th) > e.worldHeight {
+				return fmt.Errorf("rotated region of %dx%d cells does not fit into the world", tw, th)
+			}
+		}
+		// the region is rotated around its centre
+		tx, ty = e.wrap(x+(w-tw)/2, y+(h-th)/2)
+	}
+	before := e.beginEdit(footprint{regions: append(e.rectRegions(x, y, w, h), e.rectRegions(tx, ty, tw, th)...)})
+
+	switch er.Op {
+	case protocol.RegionCut:
+		s.clipboard = clipboard{cells, w, h}
+		e.clearRect(x, y, w, h)
+	case protocol.RegionMove:
+		e.clearRect(x, y, w, h)
+		e.clearRect(tx, ty, tw, th)
+		e.place(cells, tx, ty)
+	case protocol.RegionRecolour:
+		for i := range cells {
+			cells[i].Colour = er.Colour
+		}
+		e.place(cells, x, y)
+	default:
+		t := transforms[er.Op]
+		for i := range cells {
+			nx, ny := t(int(cells[i].X), int(cells[i].Y), w, h)
+			cells[i].X, cells[i].Y = uint16(nx), uint16(ny)
+		}
+		e.clearRect(x, y, w, h)
+		e.clearRect(tx, ty, tw, th)
+		e.place(cells, tx, ty)
+	}
+	e.endEdit(s, before)
+
+	e.history.reset(e.conway)
+	e.resetPeriod()
+	e.recordStats()
+
+	return nil
+}
+
+func (e *engine) handlePasteClipboard(s *Session, pc *protocol.PasteClipboard) error {
+	if s.clipboard.width == 0 {
+		return errors.New("clipboard is empty")
+	}
+	if uint(pc.X) >= e.worldWidth || uint(pc.Y) >= e.worldHeight {
+		return fmt.Errorf("paste corner (%d, %d) lies outside of the world", pc.X, pc.Y)
+	}
+
+	e.mutex.Lock()
+	defer e.mutex.Unlock()
+
+	cells := make([]protocol.Cell, len(s.clipboard.cells))
+	for i, c := range s.clipboard.cells {
+		x, y := e.wrap(int(pc.X)+int(c.X), int(pc.Y)+int(c.Y))
+		c.X, c.Y = uint16(x), uint16(y)
+		cells[i] = c
+	}
+	before := e.beginEdit(e.pasteFootprint(cells, pc.Mode))
+	err := e.paste(cells, pc.Mode)
+	if err != nil {
+		return err
+	}
+	e.endEdit(s, before)
+	e.history.reset(e.conway)
+	e.resetPeriod()
+	e.recordStats()
+
+	return nil
+}
+
+// selection returns the cells of the rectangle at (x, y), which wraps around the edges
+// of the world, relative to its top left corner. The caller must hold the mutex.
+func (e *engine) selection(x, y, width, height int) []protocol.Cell {
+	var cells []protocol.Cell
+	for _, cell := range e.conway.Cells() {
+		cx, cy, colour, age := cell.Values()
+		dx, dy := e.wrap(int(cx)-x, int(cy)-y)
+		if dx < width && dy < height {
+			cells = append(cells, protocol.Cell{X: uint16(dx), Y: uint16(dy), Colour: colour, Age: age, State: cell.State()})
+		}
+	}
+	return cells
+}
+
+// clearRect kills all cells of the rectangle at (x, y), which wraps around the edges
+// of the world. The caller must hold the mutex.
+func (e *engine) clearRect(x, y, width, height int) {
+	for _, r := range e.rectRegions(x, y, width, height) {
+		e.conway.ClearRegion(uint16(r.x), uint16(r.y), uint16(r.width), uint16(r.height))
+	}
+}
+
+// rectRegions splits the rectangle at (x, y), which wraps around the edges of the
+// world, into regions.
+func (e *engine) rectRegions(x, y, width, height int) []region {
+	var regions []region
+	for _, sx := range splitSpan(x, width, int(e.worldWidth)) {
+		for _, sy := range splitSpan(y, height, int(e.worldHeight)) {
+			regions = append(regions, region{sx[0], sy[0], sx[1], sy[1]})
+		}
+	}
+	return regions
+}
+
+// place sets the cells relative to (x, y), wrapping around the edges of the world.
+// The caller must hold the mutex.
+func (e *engine) place(cells []protocol.Cell, x, y int) {
+	for _, c := range cells {
+		cx, cy := e.wrap(x+int(c.X), y+int(c.Y))
+		e.conway.SetCell(uint16(cx), uint16(cy), c.Colour, c.Age, c.State)
+	}
+}
+
+// wrap returns the position within the world that (x, y) wraps around to.
+func (e *engine) wrap(x, y int) (int, int) {
+	w, h := int(e.worldWidth), int(e.worldHeight)
+	return (x%w + w) % w, (y%h + h) % h
+}
@@ -0,0 +1,272 @@
+package engine
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"math"
+	"math/rand/v2"
+	"slices"
+	"time"
+
+	"github.com/JackWithOneEye/conwaymore/internal/conway"
+	"github.com/JackWithOneEye/conwaymore/internal/patterns"
+	"github.com/JackWithOneEye/conwaymore/internal/protocol"
+)
+
+// actions of schedules
+const (
+	ActionPattern   = "pattern"   // places a pattern of the library
+	ActionSoup      = "soup"      // injects a random soup into a region
+	ActionClear     = "clear"     // kills all cells
+	ActionRandomise = "randomise" // replaces the world with a random soup
+)
+
+// Schedule runs an action whenever it is due. Exactly one of the triggers is set.
+type Schedule struct {
+	ID int64 `json:"id"` // assigned by the database
+
+	AtGeneration     uint64 `json:"at_generation,omitempty"`     // whenever the world reaches the generation
+	EveryGenerations uint64 `json:"every_generations,omitempty"` // whenever the generation is a multiple
+	DailyAt          string `json:"daily_at,omitempty"`          // every day at "15:04", local time
+
+	Action ScheduleAction `json:"action"`
+}
+
+// ScheduleAction changes the world. The fields besides the type depend on the action.
+type ScheduleAction struct {
+	Type    string `json:"type"`
+	Pattern string `json:"pattern,omitempty"` // name in the pattern library
+	X       uint16 `json:"x,omitempty"`       // top left corner of the pattern or soup
+	Y       uint16 `json:"y,omitempty"`
+	Width   uint16 `json:"width,omitempty"`   // of the soup, 0 reaches to the right edge
+	Height  uint16 `json:"height,omitempty"`  // of the soup, 0 reaches to the bottom edge
+	Density uint8  `json:"density,omitempty"` // percentage of alive cells of the soup, 50 if 0
+	Seed    string `json:"seed,omitempty"`    // of the soup, a new one each time if empty
+	Colour  uint32 `json:"colour,omitempty"`  // of the pattern cells, white if 0
+}
+
+// Validate checks the schedule against a world of the given size.
+func (s *Schedule) Validate(worldWidth, worldHeight uint) error {
+	triggers := 0
+	if s.AtGeneration != 0 {
+		triggers++
+	}
+	if s.EveryGenerations != 0 {
+		triggers++
+	}
+	if s.DailyAt != "" {
+		if _, err := time.Parse("15:04", s.DailyAt); err != nil {
+			return fmt.Errorf("invalid daily time %q, use hh:mm", s.DailyAt)
+		}
+		triggers++
+	}
+	if triggers != 1 {
+		return errors.New("schedule needs exactly one of at_generation, every_generations and daily_at")
+	}
+
+	a := &s.Action
+	switch a.Type {
+	case ActionPattern:
+		if _, ok := patterns.Patterns[a.Pattern]; !ok {
+			return fmt.Errorf("unknown pattern %q", a.Pattern)
+		}
+	case ActionSoup:
+		if a.Density > 100 {
+			return fmt.Errorf("soup density must be between 1 and 100, got %d", a.Density)
+		}
+	case ActionClear, ActionRandomise:
+		return nil
+	default:
+		return fmt.Errorf("unknown action %q", a.Type)
+	}
+	if uint(a.X) >= worldWidth || uint(a.Y) >= worldHeight {
+		return fmt.Errorf("corner (%d, %d) lies outside of the world", a.X, a.Y)
+	}
+	return nil
+}
+
+// String describes the schedule for the UI.
+func (s *Schedule) String() string {
+	var when string
+	switch {
+	case s.AtGeneration != 0:
+		when = fmt.Sprintf("at gen %d", s.AtGeneration)
+	case s.EveryGenerations != 0:
+		when = fmt.Sprintf("every %d gens", s.EveryGenerations)
+	default:
+		when = "daily at " + s.DailyAt
+	}
+
+	a := &s.Action
+	switch a.Type {
+	case ActionPattern:
+		return fmt.Sprintf("%s: place %s at (%d, %d)", when, a.Pattern, a.X, a.Y)
+	case ActionSoup:
+		return fmt.Sprintf("%s: inject soup at (%d, %d)", when, a.X, a.Y)
+	}
+	return fmt.Sprintf("%s: %s", when, a.Type)
+}
+
+// scheduled is a schedule added to the engine.
+type scheduled struct {
+	Schedule
+	lastRun time.Time // or when it was added, for the schedules of the clock
+}
+
+// dueBetween reports whether a schedule of generations is due after stepping from one
+// generation to a later one.
+func (s *scheduled) dueBetween(from, to uint64) bool {
+	switch {
+	case s.AtGeneration != 0:
+		return from < s.AtGeneration && s.AtGeneration <= to
+	case s.EveryGenerations != 0:
+		return from/s.EveryGenerations != to/s.EveryGenerations
+	}
+	return false
+}
+
+// nextDue returns the first generation after the given one a schedule is due at.
+func (s *scheduled) nextDue(generation uint64) uint64 {
+	switch {
+	case s.AtGeneration > generation:
+		return s.AtGeneration
+	case s.EveryGenerations != 0:
+		return (generation/s.EveryGenerations + 1) * s.EveryGenerations
+	}
+	return math.MaxUint64
+}
+
+// dueAt reports whether a daily schedule is due at the time, as its time of the day
+// passed since it last ran.
+func (s *scheduled) dueAt(now time.Time) bool {
+	if s.DailyAt == "" {
+		return false
+	}
+	t, _ := time.Parse("15:04", s.DailyAt)
+	last := s.lastRun
+	next := time.Date(last.Year(), last.Month(), last.Day(), t.Hour(), t.Minute(), 0, 0, last.Location())
+	if !next.After(last) {
+		next = next.AddDate(0, 0, 1)
+	}
+	return !now.Before(next)
+}
+
+func (e *engine) AddSchedule(s Schedule) {
+	e.mutex.Lock()
+	defer e.mutex.Unlock()
+	e.schedules = append(e.schedules, &scheduled{s, time.Now()})
+}
+
+func (e *engine) RemoveSchedule(id int64) bool {
+	e.mutex.Lock()
+	defer e.mutex.Unlock()
+	n := len(e.schedules)
+	e.schedules = slices.DeleteFunc(e.schedules, func(s *scheduled) bool {
+		return s.ID == id
+	})
+	return len(e.schedules) != n
+}
+
+func (e *engine) Schedules() []Schedule {
+	e.mutex.Lock()
+	defer e.mutex.Unlock()
+	schedules := make([]Schedule, len(e.schedules))
+	for i, s := range e.schedules {
+		schedules[i] = s.Schedule
+	}
+	return schedules
+}
+
+// nextDue returns the first generation after the current one a schedule is due at.
+// The caller must hold the mutex.
+func (e *engine) nextDue() uint64 {
+	next := uint64(math.MaxUint64)
+	for _, s := range e.schedules {
+		next = min(next, s.nextDue(e.generation))
+	}
+	return next
+}
+
+// runDueSchedules runs the schedules of generations that are due after stepping from
+// the given generation to the current one, and reports whether any were. The caller
+// must hold the mutex.
+func (e *engine) runDueSchedules(from uint64) bool {
+	ran := false
+	to := e.generation
+	for _, s := range e.schedules {
+		if s.dueBetween(from, to) {
+			e.runAction(&s.Action)
+			ran = true
+		}
+	}
+	return ran
+}
+
+// runClockSchedules runs the daily schedules that are due and reports whether any
+// were.
+func (e *engine) runClockSchedules(now time.Time) bool {
+	if e.isFastForwarding() {
+		// they are run once the fast-forward is done
+		return false
+	}
+
+	e.mutex.Lock()
+	defer e.mutex.Unlock()
+	ran := false
+	for _, s := range e.schedules {
+		if s.dueAt(now) {
+			e.runAction(&s.Action)
+			s.lastRun = now
+			ran = true
+		}
+	}
+	return ran
+}
+
+// runAction changes the world as the action of a schedule says. The caller must hold
+// the mutex.
+func (e *engine) runAction(a *ScheduleAction) {
+	switch a.Type {
+	case ActionPattern:
+		colour := a.Colour
+		if colour == 0 {
+			colour = 0xffffff
+		}
+		p := patterns.Patterns[a.Pattern]
+		cells := make([]protocol.Cell, len(p.Cells))
+		for i, pc := range p.Cells {
+			x, y := e.wrap(int(a.X)+int(pc.X), int(a.Y)+int(pc.Y))
+			cells[i] = protocol.Cell{X: uint16(x), Y: uint16(y), Colour: colour}
+		}
+		if err := e.paste(cells, protocol.PasteOverwrite); err != nil {
+			log.Printf("could not place pattern %q of schedule: %s", a.Pattern, err)
+		}
+	case ActionSoup:
+		density := a.Density
+		if density == 0 {
+			density = 50
+		}
+		seed := rand.Uint64()
+		if a.Seed != "" {
+			seed = conway.SeedFromString(a.Seed)
+		}
+		soup := conway.Soup{Seed: seed, Density: float64(density) / 100, X: a.X, Y: a.Y, Width: a.Width, Height: a.Height}
+		soup.Inject(e.conway, int(e.worldWidth), int(e.worldHeight))
+	case ActionClear:
+		e.conway.Clear()
+		e.history.reset(e.conway)
+		e.resetPeriod()
+		e.startOver()
+		return
+	case ActionRandomise:
+		e.conway.Randomise(conway.RandomSoup())
+		e.history.reset(e.conway)
+		e.resetPeriod()
+		e.startOver()
+		return
+	}
+	e.history.reset(e.conway)
+	e.resetPeriod()
+	e.recordStats()
+}
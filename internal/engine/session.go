@@ -0,0 +1,24 @@
+package engine
+
+import (
+	"github.com/JackWithOneEye/conwaymore/internal/protocol"
+)
+
+// Session is the state the engine keeps for one client, such as its clipboard and the
+// journal of its edits. The messages of a session are submitted one after another.
+type Session struct {
+	clipboard clipboard
+	journal   journal
+}
+
+// clipboard holds cells relative to the top left corner of the region they were
+// copied from.
+type clipboard struct {
+	cells  []protocol.Cell
+	width  int
+	height int
+}
+
+func NewSession() *Session {
+	return &Session{}
+}
@@ -0,0 +1,57 @@
+package engine
+
+import (
+	"github.com/JackWithOneEye/conwaymore/internal/conway"
+)
+
+// maxStats is the number of generations kept in the stats time series.
+const maxStats = 1000
+
+// statsSeries is a rolling time series of the stats of the last generations, ordered
+// by generation.
+type statsSeries struct {
+	entries [maxStats]conway.Stats // ring buffer, the oldest at start
+	start   int
+	size    int
+}
+
+// record adds the stats of a generation. Entries of the same or later generations are
+// dropped first, as the world was changed or went back in time.
+func (s *statsSeries) record(stats conway.Stats) {
+	for s.size > 0 && s.at(s.size-1).Generation >= stats.Generation {
+		s.size -= 1
+	}
+	if s.size == maxStats {
+		s.start = (s.start + 1) % maxStats
+		s.size -= 1
+	}
+	s.entries[(s.start+s.size)%maxStats] = stats
+	s.size += 1
+}
+
+// reset drops all entries, as the world started over.
+func (s *statsSeries) reset() {
+	s.start = 0
+	s.size = 0
+}
+
+func (s *statsSeries) at(i int) *conway.Stats {
+	return &s.entries[(s.start+i)%maxStats]
+}
+
+// latest returns the stats of the current generation.
+func (s *statsSeries) latest() (conway.Stats, bool) {
+	if s.size == 0 {
+		return conway.Stats{}, false
+	}
+	return *s.at(s.size - 1), true
+}
+
+// all returns a copy of the series, the oldest generation first.
+func (s *statsSeries) all() []conway.Stats {
+	all := make([]conway.Stats, s.size)
+	for i := range all {
+		all[i] = *s.at(i)
+	}
+	return all
+}
@@ -1,10 +1,20 @@
 package protocol
 
-const bytesPerCell = 9
+const (
+	bytesPerCell       = 10
+	legacyBytesPerCell = 9 // cells without state, as found in old seeds
+	bytesPerCoord      = 4
+)
 
 type Cell struct {
 	X, Y, Age uint16
 	Colour    uint32
+	State     uint8 // 0 = alive, > 0 = decay stage
+}
+
+// Coord is the position of a cell without its contents.
+type Coord struct {
+	X, Y uint16
 }
 
 func encodeCells(src []Cell, cellsCount uint32, dest []byte, destOffset uint) {
@@ -33,10 +43,13 @@ func encodeCells(src []Cell, cellsCount uint32, dest []byte, destOffset uint) {
 		dsti += 1
 		dest[dsti] = byte(cell.Age & 0xff)
 		dsti += 1
+
+		dest[dsti] = cell.State
+		dsti += 1
 	}
 }
 
-func decodeCells(src []byte, dest []Cell, srcOffset uint) {
+func decodeCells(src []byte, dest []Cell, srcOffset uint, cellSize uint) {
 	srci := srcOffset
 	for i := range dest {
 		x := (uint16(src[srci]) << 8) & 0xff00
@@ -61,6 +74,12 @@ func decodeCells(src []byte, dest []Cell, srcOffset uint) {
 		a = a | uint16(src[srci])
 		srci += 1
 
-		dest[i] = Cell{X: x, Y: y, Colour: c, Age: a}
+		var s uint8
+		if cellSize == bytesPerCell {
+			s = src[srci]
+			srci += 1
+		}
+
+		dest[i] = Cell{X: x, Y: y, Colour: c, Age: a, State: s}
 	}
 }
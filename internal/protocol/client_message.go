@@ -3,6 +3,7 @@ package protocol
 import (
 	"errors"
 	"fmt"
+	"math"
 )
 
 type clientMessageType uint8
@@ -11,6 +12,13 @@ const (
 	command clientMessageType = iota
 	setCells
 	setSpeed
+	setTopology
+	_ // the former Jump message, which is the StepN command now
+	randomise
+	killCells
+	clearRegion
+	editRegion
+	pasteClipboard
 )
 
 type ClientMessage interface {
@@ -27,6 +35,18 @@ func DecodeClientMessage(b []byte) (ClientMessage, error) {
 		msg = &SetCells{}
 	case byte(setSpeed):
 		msg = &SetSpeed{}
+	case byte(setTopology):
+		msg = &SetTopology{}
+	case byte(randomise):
+		msg = &Soup{}
+	case byte(killCells):
+		msg = &KillCells{}
+	case byte(clearRegion):
+		msg = &ClearRegion{}
+	case byte(editRegion):
+		msg = &EditRegion{}
+	case byte(pasteClipboard):
+		msg = &PasteClipboard{}
 	default:
 		return nil, fmt.Errorf("unknown client message type: %d", b[0])
 	}
@@ -45,16 +65,31 @@ const (
 	Pause
 	Clear
 	Randomise
+	Previous
+	StepN             // computes Arg generations without an output for each of them
+	JumpTo            // computes the generations up to generation Arg like StepN
+	CancelFastForward // stops StepN or JumpTo at the generation reached so far
+	Undo              // reverts the last edit of the session
+	Redo              // repeats the last edit of the session that was undone
 )
 
+// Command controls the engine. The argument follows the command type only if it is
+// not 0, so commands without one keep their length.
 type Command struct {
 	Cmd CommandType
+	Arg uint64
 }
 
 func (c *Command) Encode() []byte {
-	b := make([]byte, 2)
+	if c.Arg == 0 {
+		return []byte{byte(command), byte(c.Cmd)}
+	}
+	b := make([]byte, 10)
 	b[0] = byte(command)
 	b[1] = byte(c.Cmd)
+	for i := range 8 {
+		b[2+i] = byte(c.Arg >> (56 - 8*i))
+	}
 	return b
 }
 
@@ -63,20 +98,71 @@ func (c *Command) decode(b []byte) error {
 		return errors.New("[Command] too short")
 	}
 	c.Cmd = CommandType(b[1])
+	c.Arg = 0
+	if len(b) >= 10 {
+		for i := range 8 {
+			c.Arg = c.Arg<<8 | uint64(b[2+i])
+		}
+	}
 	return nil
 }
 
+// PasteMode decides how the cells of a SetCells message combine with the cells of the
+// world, like the paste modes of Golly.
+type PasteMode uint8
+
+const (
+	PasteReject    PasteMode = iota // fails if any of the cells is occupied
+	PasteOr                         // sets the cells that are free, keeps the occupied ones
+	PasteOverwrite                  // replaces all cells within the bounding box of the cells
+	PasteXor                        // sets the cells that are free, kills the occupied ones
+	PasteAnd                        // kills all cells within the bounding box but the given ones
+)
+
+var pasteModeNames = [...]string{
+	PasteReject:    "reject",
+	PasteOr:        "or",
+	PasteOverwrite: "overwrite",
+	PasteXor:       "xor",
+	PasteAnd:       "and",
+}
+
+// PasteModes lists all paste modes in the order of their values.
+func PasteModes() []PasteMode {
+	pms := make([]PasteMode, len(pasteModeNames))
+	for i := range pms {
+		pms[i] = PasteMode(i)
+	}
+	return pms
+}
+
+// Valid reports whether pm is one of the known paste modes.
+func (pm PasteMode) Valid() bool {
+	return int(pm) < len(pasteModeNames)
+}
+
+func (pm PasteMode) String() string {
+	if !pm.Valid() {
+		return fmt.Sprintf("PasteMode(%d)", pm)
+	}
+	return pasteModeNames[pm]
+}
+
+// SetCells places cells in the world. The paste mode follows the cells, so messages
+// without it reject occupied cells.
 type SetCells struct {
 	Count uint16
 	Cells []Cell
+	Mode  PasteMode
 }
 
 func (sc *SetCells) Encode() []byte {
-	b := make([]byte, 3+len(sc.Cells)*bytesPerCell)
+	b := make([]byte, 3+int(sc.Count)*bytesPerCell+1)
 	b[0] = byte(setCells)
 	b[1] = byte((sc.Count >> 8) & 0xff)
 	b[2] = byte(sc.Count & 0xff)
 	encodeCells(sc.Cells, uint32(sc.Count), b, 3)
+	b[len(b)-1] = byte(sc.Mode)
 	return b
 }
 
@@ -88,12 +174,17 @@ func (sc *SetCells) decode(b []byte) error {
 
 	sc.Count = ((uint16(b[1]) << 8) & 0xff00) | uint16(b[2])
 
-	if l < int(3+sc.Count*bytesPerCell) {
+	end := 3 + int(sc.Count)*bytesPerCell
+	if l < end {
 		return errors.New("[SetCells] byte length does not match cells count")
 	}
 
 	sc.Cells = make([]Cell, sc.Count)
-	decodeCells(b, sc.Cells, 3)
+	decodeCells(b, sc.Cells, 3, bytesPerCell)
+	sc.Mode = PasteReject
+	if l > end {
+		sc.Mode = PasteMode(b[end])
+	}
 	return nil
 }
 
@@ -116,3 +207,226 @@ func (sp *SetSpeed) decode(b []byte) error {
 	sp.Speed = ((uint16(b[1]) << 8) & 0xff00) | uint16(b[2])
 	return nil
 }
+
+type SetTopology struct {
+	Topology uint8
+}
+
+func (st *SetTopology) Encode() []byte {
+	return []byte{byte(setTopology), st.Topology}
+}
+
+func (st *SetTopology) decode(b []byte) error {
+	if len(b) < 2 {
+		return errors.New("[SetTopology] too short")
+	}
+	st.Topology = b[1]
+	return nil
+}
+
+// Soup fills a rectangle of the world with a random soup, which is the same for the
+// same seed. A width or height of 0 reaches to the edge of the world.
+type Soup struct {
+	Seed     uint64
+	Density  uint8 // percentage of alive cells
+	Symmetry uint8
+	X        uint16
+	Y        uint16
+	Width    uint16
+	Height   uint16
+	Palette  []uint32
+}
+
+func (s *Soup) Encode() []byte {
+	b := make([]byte, 20+len(s.Palette)*3)
+	b[0] = byte(randomise)
+	for i := range 8 {
+		b[1+i] = byte(s.Seed >> (56 - 8*i))
+	}
+	b[9] = s.Density
+	b[10] = s.Symmetry
+	for i, v := range [4]uint16{s.X, s.Y, s.Width, s.Height} {
+		b[11+2*i] = byte(v >> 8)
+		b[12+2*i] = byte(v)
+	}
+	b[19] = byte(len(s.Palette))
+	for i, c := range s.Palette {
+		b[20+3*i] = byte(c >> 16)
+		b[21+3*i] = byte(c >> 8)
+		b[22+3*i] = byte(c)
+	}
+	return b
+}
+
+func (s *Soup) decode(b []byte) error {
+	if len(b) < 20 {
+		return errors.New("[Soup] too short")
+	}
+	s.Seed = 0
+	for i := range 8 {
+		s.Seed = s.Seed<<8 | uint64(b[1+i])
+	}
+	s.Density = b[9]
+	s.Symmetry = b[10]
+	s.X = uint16(b[11])<<8 | uint16(b[12])
+	s.Y = uint16(b[13])<<8 | uint16(b[14])
+	s.Width = uint16(b[15])<<8 | uint16(b[16])
+	s.Height = uint16(b[17])<<8 | uint16(b[18])
+	count := int(b[19])
+	if len(b) < 20+count*3 {
+		return errors.New("[Soup] byte length does not match palette size")
+	}
+	s.Palette = make([]uint32, count)
+	for i := range s.Palette {
+		s.Palette[i] = uint32(b[20+3*i])<<16 | uint32(b[21+3*i])<<8 | uint32(b[22+3*i])
+	}
+	return nil
+}
+
+// KillCells kills the cells at the given coordinates. Coordinates without a cell are
+// ignored. Encode sends all coordinates, whatever the count says.
+type KillCells struct {
+	Count  uint16
+	Coords []Coord
+}
+
+func (kc *KillCells) Encode() []byte {
+	count := min(len(kc.Coords), math.MaxUint16)
+	b := make([]byte, 3+count*bytesPerCoord)
+	b[0] = byte(killCells)
+	b[1] = byte(count >> 8)
+	b[2] = byte(count)
+	for i, c := range kc.Coords[:count] {
+		b[3+i*bytesPerCoord] = byte(c.X >> 8)
+		b[4+i*bytesPerCoord] = byte(c.X)
+		b[5+i*bytesPerCoord] = byte(c.Y >> 8)
+		b[6+i*bytesPerCoord] = byte(c.Y)
+	}
+	return b
+}
+
+func (kc *KillCells) decode(b []byte) error {
+	if len(b) < 3 {
+		return errors.New("[KillCells] too short")
+	}
+	kc.Count = uint16(b[1])<<8 | uint16(b[2])
+	if len(b) < 3+int(kc.Count)*bytesPerCoord {
+		return errors.New("[KillCells] byte length does not match coordinates count")
+	}
+	kc.Coords = make([]Coord, kc.Count)
+	for i := range kc.Coords {
+		kc.Coords[i] = Coord{
+			X: uint16(b[3+i*bytesPerCoord])<<8 | uint16(b[4+i*bytesPerCoord]),
+			Y: uint16(b[5+i*bytesPerCoord])<<8 | uint16(b[6+i*bytesPerCoord]),
+		}
+	}
+	return nil
+}
+
+// ClearRegion kills all cells of a rectangle of the world.
+type ClearRegion struct {
+	X      uint16
+	Y      uint16
+	Width  uint16
+	Height uint16
+}
+
+func (cr *ClearRegion) Encode() []byte {
+	b := make([]byte, 9)
+	b[0] = byte(clearRegion)
+	for i, v := range [4]uint16{cr.X, cr.Y, cr.Width, cr.Height} {
+		b[1+2*i] = byte(v >> 8)
+		b[2+2*i] = byte(v)
+	}
+	return b
+}
+
+func (cr *ClearRegion) decode(b []byte) error {
+	if len(b) < 9 {
+		return errors.New("[ClearRegion] too short")
+	}
+	cr.X = uint16(b[1])<<8 | uint16(b[2])
+	cr.Y = uint16(b[3])<<8 | uint16(b[4])
+	cr.Width = uint16(b[5])<<8 | uint16(b[6])
+	cr.Height = uint16(b[7])<<8 | uint16(b[8])
+	return nil
+}
+
+// RegionOp is an operation on the cells of a rectangle of the world.
+type RegionOp uint8
+
+const (
+	RegionCopy           RegionOp = iota // copies the cells to the clipboard of the session
+	RegionCut                            // copies the cells to the clipboard and kills them
+	RegionMove                           // moves the cells by the offset
+	RegionRotateCW                       // rotates the cells by 90° clockwise around the centre
+	RegionRotateCCW                      // rotates the cells by 90° anticlockwise around the centre
+	RegionRotate180                      // rotates the cells by 180°
+	RegionFlipHorizontal                 // mirrors the cells left to right
+	RegionFlipVertical                   // mirrors the cells top to bottom
+	RegionRecolour                       // gives all cells the colour
+)
+
+// EditRegion applies an operation to the cells of a rectangle of the world, which
+// wraps around the edges of the world.
+type EditRegion struct {
+	Op     RegionOp
+	X      uint16
+	Y      uint16
+	Width  uint16
+	Height uint16
+	DX     int16  // offset of RegionMove
+	DY     int16  // offset of RegionMove
+	Colour uint32 // of RegionRecolour
+}
+
+func (er *EditRegion) Encode() []byte {
+	b := make([]byte, 17)
+	b[0] = byte(editRegion)
+	b[1] = byte(er.Op)
+	for i, v := range [6]uint16{er.X, er.Y, er.Width, er.Height, uint16(er.DX), uint16(er.DY)} {
+		b[2+2*i] = byte(v >> 8)
+		b[3+2*i] = byte(v)
+	}
+	b[14] = byte(er.Colour >> 16)
+	b[15] = byte(er.Colour >> 8)
+	b[16] = byte(er.Colour)
+	return b
+}
+
+func (er *EditRegion) decode(b []byte) error {
+	if len(b) < 17 {
+		return errors.New("[EditRegion] too short")
+	}
+	er.Op = RegionOp(b[1])
+	er.X = uint16(b[2])<<8 | uint16(b[3])
+	er.Y = uint16(b[4])<<8 | uint16(b[5])
+	er.Width = uint16(b[6])<<8 | uint16(b[7])
+	er.Height = uint16(b[8])<<8 | uint16(b[9])
+	er.DX = int16(uint16(b[10])<<8 | uint16(b[11]))
+	er.DY = int16(uint16(b[12])<<8 | uint16(b[13]))
+	er.Colour = uint32(b[14])<<16 | uint32(b[15])<<8 | uint32(b[16])
+	return nil
+}
+
+// PasteClipboard places the clipboard of the session with its top left corner at
+// (X, Y).
+type PasteClipboard struct {
+	X    uint16
+	Y    uint16
+	Mode PasteMode
+}
+
+func (pc *PasteClipboard) Encode() []byte {
+	return []byte{byte(pasteClipboard), byte(pc.X >> 8), byte(pc.X), byte(pc.Y >> 8), byte(pc.Y), byte(pc.Mode)}
+}
+
+func (pc *PasteClipboard) decode(b []byte) error {
+	if len(b) < 6 {
+		return errors.New("[PasteClipboard] too short")
+	}
+	pc.X = uint16(b[1])<<8 | uint16(b[2])
+	pc.Y = uint16(b[3])<<8 | uint16(b[4])
+	pc.Mode = PasteMode(b[5])
+	return nil
+}
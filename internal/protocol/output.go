@@ -4,22 +4,75 @@ import (
 	"errors"
 )
 
-const cellsOffset = 6
+const (
+	legacyCellsOffset = 6
+	cellsOffset       = 8 // the period follows the cells count
+)
+
+const (
+	flagPlaying    byte = 1 << iota
+	flagCellStates      // cells are encoded with their state byte
+	flagTopology        // the topology of the world is encoded in the bits above
+)
+
+const (
+	flagPeriod byte = 1 << 6 // the period of the world is encoded after the cells count
+	flagHeader byte = 1 << 7 // a versioned header follows the period
+)
+
+// OutputVersion is the version of the header encoded by this package. Later versions
+// only append fields, so older decoders skip what they do not know.
+const OutputVersion = 2
+
+// offsets of the header fields relative to cellsOffset
+const (
+	headerVersion    = 0
+	headerLength     = 1 // of the whole header, where the cells begin
+	headerGeneration = 3
+	headerSequence   = 11
+	headerWidth      = 15
+	headerHeight     = 19
+	headerRuleLength = 23
+	headerRule       = 25
+	// version 2 appends the generations per second after the rule
+	rateSize = 4
+)
+
+const (
+	topologyShift = 3
+	topologyMask  = 0x07
+)
 
 type Output struct {
 	Cells      []Cell
 	CellsCount uint32
 	Playing    bool
 	Speed      uint16
+	Topology   uint8 // 0 (torus) for outputs that predate topologies
+	// HasTopology reports whether the decoded output carried its topology. Outputs are
+	// always encoded with it.
+	HasTopology bool
+	Period      uint16 // 1 if the world is static or dead, 0 until it repeats
+
+	// the header fields are 0 or empty for outputs that predate the header
+
+	Version     uint8
+	Generation  uint64
+	Sequence    uint32 // number of the frame, counting all outputs of the engine
+	WorldWidth  uint32
+	WorldHeight uint32
+	Rule        string
+	// GenerationsPerSecond is the rate at which the engine computed the generations of
+	// the last second, from version 2 on.
+	GenerationsPerSecond uint32
 }
 
 func (o *Output) Encode(b []byte) {
 	// b := make([]byte, cellsOffset+cellsCount*bytesPerCell)
 
+	b[0] = flagCellStates | flagTopology | flagPeriod | flagHeader | (o.Topology&topologyMask)<<topologyShift
 	if o.Playing {
-		b[0] = 1
-	} else {
-		b[0] = 0
+		b[0] |= flagPlaying
 	}
 
 	b[1] = byte(o.Speed >> 8)
@@ -29,31 +82,125 @@ func (o *Output) Encode(b []byte) {
 	b[4] = byte((o.CellsCount & 0xff00) >> 8)
 	b[5] = byte(o.CellsCount & 0xff)
 
-	encodeCells(o.Cells, o.CellsCount, b, 6)
+	b[6] = byte(o.Period >> 8)
+	b[7] = byte(o.Period & 0x00ff)
+
+	h := b[cellsOffset:]
+	h[headerVersion] = OutputVersion
+	putUint(h[headerLength:headerGeneration], uint64(o.headerSize()))
+	putUint(h[headerGeneration:headerSequence], o.Generation)
+	putUint(h[headerSequence:headerWidth], uint64(o.Sequence))
+	putUint(h[headerWidth:headerHeight], uint64(o.WorldWidth))
+	putUint(h[headerHeight:headerRuleLength], uint64(o.WorldHeight))
+	putUint(h[headerRuleLength:headerRule], uint64(len(o.Rule)))
+	copy(h[headerRule:], o.Rule)
+	rate := headerRule + len(o.Rule)
+	putUint(h[rate:rate+rateSize], uint64(o.GenerationsPerSecond))
+
+	encodeCells(o.Cells, o.CellsCount, b, uint(cellsOffset+o.headerSize()))
+}
+
+// After reports whether the output was generated after the one with the sequence
+// number, allowing for the numbers to wrap around. Clients drop outputs that arrive out
+// of order.
+func (o *Output) After(sequence uint32) bool {
+	return int32(o.Sequence-sequence) > 0
 }
 
 func (o *Output) EncodeSize() uint32 {
-	return cellsOffset + o.CellsCount*bytesPerCell
+	return cellsOffset + o.headerSize() + o.CellsCount*bytesPerCell
+}
+
+func (o *Output) headerSize() uint32 {
+	return headerRule + uint32(len(o.Rule)) + rateSize
 }
 
 func (o *Output) Decode(b []byte) error {
 	l := len(b)
-	if l < cellsOffset {
+	if l < legacyCellsOffset {
 		return errors.New("too short")
 	}
-	if b[0] == 1 {
-		o.Playing = true
+	o.Playing = b[0]&flagPlaying != 0
+	o.Topology = 0
+	o.HasTopology = b[0]&flagTopology != 0
+	if o.HasTopology {
+		o.Topology = (b[0] >> topologyShift) & topologyMask
+	}
+	cellSize := uint32(legacyBytesPerCell)
+	if b[0]&flagCellStates != 0 {
+		cellSize = bytesPerCell
 	}
 	o.Speed = (uint16(b[1]) << 8) | uint16(b[2])
 	o.CellsCount = (uint32(b[3]) << 16) | (uint32(b[4]) << 8) | uint32(b[5])
+	offset := uint32(legacyCellsOffset)
+	o.Period = 0
+	if b[0]&flagPeriod != 0 {
+		offset = cellsOffset
+		if l < cellsOffset {
+			return errors.New("too short")
+		}
+		o.Period = (uint16(b[6]) << 8) | uint16(b[7])
+	}
+	o.Version, o.Generation, o.Sequence, o.WorldWidth, o.WorldHeight, o.Rule = 0, 0, 0, 0, 0, ""
+	o.GenerationsPerSecond = 0
+	if b[0]&flagPeriod != 0 && b[0]&flagHeader != 0 {
+		size, err := o.decodeHeader(b[offset:])
+		if err != nil {
+			return err
+		}
+		offset += size
+	}
 
-	if l < int(6+o.CellsCount*bytesPerCell) {
+	if l < int(offset+o.CellsCount*cellSize) {
 		return errors.New("byte length deos not match cells count")
 	}
 
 	o.Cells = make([]Cell, o.CellsCount)
 
-	decodeCells(b, o.Cells, cellsOffset)
+	decodeCells(b, o.Cells, uint(offset), uint(cellSize))
 
 	return nil
 }
+
+// decodeHeader decodes the fields of the header known to this version and returns the
+// size of the whole header.
+func (o *Output) decodeHeader(h []byte) (uint32, error) {
+	if len(h) < headerRule {
+		return 0, errors.New("header too short")
+	}
+	size := uint32(getUint(h[headerLength:headerGeneration]))
+	ruleLength := uint32(getUint(h[headerRuleLength:headerRule]))
+	if size < headerRule+ruleLength || uint32(len(h)) < size {
+		return 0, errors.New("header length does not match its fields")
+	}
+	o.Version = h[headerVersion]
+	o.Generation = getUint(h[headerGeneration:headerSequence])
+	o.Sequence = uint32(getUint(h[headerSequence:headerWidth]))
+	o.WorldWidth = uint32(getUint(h[headerWidth:headerHeight]))
+	o.WorldHeight = uint32(getUint(h[headerHeight:headerRuleLength]))
+	o.Rule = string(h[headerRule : headerRule+ruleLength])
+	if rate := headerRule + ruleLength; o.Version >= 2 {
+		if size < rate+rateSize {
+			return 0, errors.New("header length does not match its fields")
+		}
+		o.GenerationsPerSecond = uint32(getUint(h[rate : rate+rateSize]))
+	}
+	return size, nil
+}
+
+// putUint encodes v big-endian into all bytes of b.
+func putUint(b []byte, v uint64) {
+	for i := len(b) - 1; i >= 0; i-- {
+		b[i] = byte(v)
+		v >>= 8
+	}
+}
+
+// getUint decodes the big-endian number of all bytes of b.
+func getUint(b []byte) uint64 {
+	var v uint64
+	for _, c := range b {
+		v = v<<8 | uint64(c)
+	}
+	return v
+}
@@ -0,0 +1,14 @@
+package protocol
+
+// Progress tells how far StepN or JumpTo got. It is sent as a JSON text message next to
+// the stats, wrapped in a ProgressMessage.
+type Progress struct {
+	Generation uint64 `json:"generation"`
+	Target     uint64 `json:"target"`
+	Done       bool   `json:"done"` // the target was reached or the run was cancelled
+}
+
+// ProgressMessage wraps the progress, so that clients can tell it apart from the stats.
+type ProgressMessage struct {
+	FastForward *Progress `json:"fast_forward"`
+}
@@ -6,6 +6,8 @@ import (
 	"fmt"
 	"log"
 	"net/http"
+	"net/url"
+	"strconv"
 	"sync"
 	"sync/atomic"
 	"time"
@@ -15,6 +17,7 @@ import (
 	"github.com/JackWithOneEye/conwaymore/internal/engine"
 	"github.com/JackWithOneEye/conwaymore/internal/livereload"
 	"github.com/JackWithOneEye/conwaymore/internal/patterns"
+	"github.com/JackWithOneEye/conwaymore/internal/protocol"
 	"github.com/a-h/templ"
 	"github.com/coder/websocket"
 	"github.com/gin-gonic/gin"
@@ -22,29 +25,58 @@ import (
 
 type ServerConfig interface {
 	Port() uint
-	WorldSize() uint
+	WorldHeight() uint
+	WorldWidth() uint
 }
 
 type server struct {
-	cfg          ServerConfig
-	db           database.DatabaseService
-	engine       engine.Engine
+	cfg     ServerConfig
+	db      database.DatabaseService
+	manager engine.Manager
+	hubs    map[string]*hub
+	hubsMtx sync.RWMutex
+}
+
+// hub broadcasts the outputs of the engine of a room to its listeners.
+type hub struct {
+	room         *engine.Room
 	listeners    map[*listener]struct{}
 	listenersMtx sync.RWMutex
 	lastOutput   atomic.Value
 }
 
 type listener struct {
-	msgs chan []byte
+	msgs        chan message
+	stats       bool        // also receives the stats of each generation and the fast-forward progress
+	latestStats chan []byte // holds only the stats not written yet, so they never push out an output
+}
+
+// message is written to the websocket of a listener. Outputs are binary, stats and
+// progress are JSON text messages.
+type message struct {
+	typ  websocket.MessageType
+	data []byte
+}
+
+// roomInfo describes a room in the responses of /rooms.
+type roomInfo struct {
+	Name string `json:"name"`
+	engine.RoomConfig
 }
 
-func NewServer(cfg ServerConfig, db database.DatabaseService, engine engine.Engine, ctx context.Context) *http.Server {
+func NewServer(cfg ServerConfig, db database.DatabaseService, manager engine.Manager, ctx context.Context) *http.Server {
 	s := &server{
-		cfg:       cfg,
-		db:        db,
-		engine:    engine,
-		listeners: make(map[*listener]struct{}),
+		cfg:     cfg,
+		db:      db,
+		manager: manager,
+		hubs:    make(map[string]*hub),
+	}
+
+	for _, r := range manager.List() {
+		s.addHub(r)
 	}
+	s.restoreRooms()
+	s.restoreSchedules()
 
 	srv := &http.Server{
 		Addr:              fmt.Sprintf(":%d", cfg.Port()),
@@ -53,49 +85,199 @@ func NewServer(cfg ServerConfig, db database.DatabaseService, engine engine.Engi
 		ReadHeaderTimeout: 10 * time.Second,
 	}
 
+	return srv
+}
+
+// restoreRooms creates the rooms stored in the database.
+func (s *server) restoreRooms() {
+	rooms, err := s.db.GetRooms()
+	if err != nil {
+		log.Printf("could not get rooms: %s", err)
+		return
+	}
+	for _, dr := range rooms {
+		var rc engine.RoomConfig
+		if err := json.Unmarshal(dr.Config, &rc); err != nil {
+			log.Printf("could not decode config of room %q: %s", dr.Name, err)
+			continue
+		}
+		r, err := s.manager.Create(dr.Name, rc, dr.Seed)
+		if err != nil {
+			log.Printf("could not restore room %q: %s", dr.Name, err)
+			continue
+		}
+		s.addHub(r)
+	}
+}
+
+// restoreSchedules adds the schedules stored in the database to the engines of their
+// rooms.
+func (s *server) restoreSchedules() {
+	schedules, err := s.db.GetSchedules()
+	if err != nil {
+		log.Printf("could not get schedules: %s", err)
+		return
+	}
+	for _, ds := range schedules {
+		r, ok := s.manager.Get(ds.Room)
+		if !ok {
+			log.Printf("could not restore schedule %d: room %q does not exist", ds.ID, ds.Room)
+			continue
+		}
+		var sch engine.Schedule
+		if err := json.Unmarshal(ds.Schedule, &sch); err != nil {
+			log.Printf("could not decode schedule %d: %s", ds.ID, err)
+			continue
+		}
+		if err := sch.Validate(r.Engine.WorldWidth(), r.Engine.WorldHeight()); err != nil {
+			log.Printf("could not restore schedule %d: %s", ds.ID, err)
+			continue
+		}
+		sch.ID = ds.ID
+		r.Engine.AddSchedule(sch)
+	}
+}
+
+// addHub starts broadcasting the outputs of the room until its engine stops.
+func (s *server) addHub(r *engine.Room) *hub {
+	h := &hub{
+		room:      r,
+		listeners: make(map[*listener]struct{}),
+	}
+	s.hubsMtx.Lock()
+	s.hubs[r.Name] = h
+	s.hubsMtx.Unlock()
+
 	go func() {
+		output, progress := r.Engine.Output(), r.Engine.Progress()
 		for {
 			select {
-			case <-ctx.Done():
-				return
-			case o, ok := <-engine.Output():
+			case f, ok := <-output:
 				if !ok {
-					continue
+					return
 				}
-				s.lastOutput.Store(o)
-
-				s.listenersMtx.RLock()
-				for l := range s.listeners {
-					select {
-					case l.msgs <- o:
-					default:
-						<-l.msgs
-						l.msgs <- o
-						log.Println("TOO SLOW!!!")
-					}
+				h.broadcastFrame(f)
+			case p, ok := <-progress:
+				if !ok {
+					progress = nil
+					continue
 				}
-				s.listenersMtx.RUnlock()
+				h.broadcastProgress(p)
 			}
 		}
 	}()
-	go engine.Start()
 
-	return srv
+	return h
+}
+
+// roomHub returns the hub of the room named by the query, or of the default room.
+func (s *server) roomHub(c *gin.Context) (*hub, bool) {
+	name := c.Query("room")
+	if name == "" {
+		name = engine.DefaultRoom
+	}
+	s.hubsMtx.RLock()
+	defer s.hubsMtx.RUnlock()
+	h, ok := s.hubs[name]
+	if !ok {
+		c.String(http.StatusNotFound, "room %q does not exist", name)
+	}
+	return h, ok
 }
 
-func (s *server) addListener(l *listener) {
-	s.listenersMtx.Lock()
-	defer s.listenersMtx.Unlock()
-	s.listeners[l] = struct{}{}
-	if lo := s.lastOutput.Load(); lo != nil {
-		l.msgs <- lo.([]byte)
+func (s *server) globals(h *hub) web.Globals {
+	e := h.room.Engine
+	rule := e.Rule()
+	g := web.Globals{
+		Grid:         rule.Grid(),
+		Rule:         rule.String(),
+		StateColours: rule.StateColours(),
+		WorldHeight:  e.WorldHeight(),
+		WorldWidth:   e.WorldWidth(),
+	}
+	if h.room.Name != engine.DefaultRoom {
+		g.Room = h.room.Name
 	}
+	return g
 }
 
-func (s *server) removeListener(l *listener) {
-	s.listenersMtx.Lock()
-	defer s.listenersMtx.Unlock()
-	delete(s.listeners, l)
+func (h *hub) broadcastFrame(f engine.Frame) {
+	h.lastOutput.Store(f.Output)
+
+	h.listenersMtx.RLock()
+	defer h.listenersMtx.RUnlock()
+	for l := range h.listeners {
+		l.send(message{websocket.MessageBinary, f.Output})
+		if l.stats && f.Stats != nil {
+			l.sendStats(f.Stats)
+		}
+	}
+}
+
+func (h *hub) broadcastProgress(p protocol.Progress) {
+	d, err := json.Marshal(protocol.ProgressMessage{FastForward: &p})
+	if err != nil {
+		log.Printf("could not marshal progress: %s", err)
+		return
+	}
+
+	h.listenersMtx.RLock()
+	defer h.listenersMtx.RUnlock()
+	for l := range h.listeners {
+		if l.stats {
+			l.send(message{websocket.MessageText, d})
+		}
+	}
+}
+
+func (h *hub) addListener(l *listener) {
+	h.listenersMtx.Lock()
+	defer h.listenersMtx.Unlock()
+	h.listeners[l] = struct{}{}
+	if lo := h.lastOutput.Load(); lo != nil {
+		l.msgs <- message{websocket.MessageBinary, lo.([]byte)}
+	}
+	if l.stats {
+		h.room.Engine.WatchStats(true)
+		l.sendStats(h.latestStats())
+	}
+}
+
+// send queues a message, dropping the oldest one if the listener is too slow.
+func (l *listener) send(msg message) {
+	select {
+	case l.msgs <- msg:
+	default:
+		<-l.msgs
+		l.msgs <- msg
+		log.Println("TOO SLOW!!!")
+	}
+}
+
+// sendStats replaces the stats that were not written yet.
+func (l *listener) sendStats(d []byte) {
+	select {
+	case <-l.latestStats:
+	default:
+	}
+	l.latestStats <- d
+}
+
+func (h *hub) latestStats() []byte {
+	d, err := json.Marshal(h.room.Engine.LatestStats())
+	if err != nil {
+		log.Printf("could not marshal stats: %s", err)
+	}
+	return d
+}
+
+func (h *hub) removeListener(l *listener) {
+	h.listenersMtx.Lock()
+	defer h.listenersMtx.Unlock()
+	delete(h.listeners, l)
+	if l.stats {
+		h.room.Engine.WatchStats(false)
+	}
 }
 
 func (s *server) registerRoutes() http.Handler {
@@ -103,23 +285,42 @@ func (s *server) registerRoutes() http.Handler {
 
 	r.Static("/assets", "./cmd/web/assets")
 
-	globals := web.Globals{
-		WorldSize: s.cfg.WorldSize(),
-	}
-
 	r.GET("/_livereload", livereload.Handler)
 
 	r.GET("/", livereload.InjectScript("/_livereload", func(c *gin.Context) {
-		templ.Handler(web.Index("/game", &globals)).ServeHTTP(c.Writer, c.Request)
+		h, ok := s.roomHub(c)
+		if !ok {
+			return
+		}
+		globals := s.globals(h)
+		gamePath, savePath := "/game", "/save"
+		if globals.Room != "" {
+			query := "?room=" + url.QueryEscape(globals.Room)
+			gamePath, savePath = gamePath+query, savePath+query
+		}
+		templ.Handler(web.Index(gamePath, savePath, &globals)).ServeHTTP(c.Writer, c.Request)
 	}))
 
 	r.GET("/game", func(c *gin.Context) {
-		templ.Handler(web.Game("#ffffff", 30, float64(s.engine.Speed()), s.engine.Playing(), patterns.Patterns)).ServeHTTP(c.Writer, c.Request)
+		h, ok := s.roomHub(c)
+		if !ok {
+			return
+		}
+		e := h.room.Engine
+		var schedules []string
+		for _, sch := range e.Schedules() {
+			schedules = append(schedules, sch.String())
+		}
+		templ.Handler(web.Game("#ffffff", 30, float64(e.Speed()), e.Playing(), patterns.Patterns, schedules)).ServeHTTP(c.Writer, c.Request)
 	})
 
 	r.GET("/globals", func(c *gin.Context) {
+		h, ok := s.roomHub(c)
+		if !ok {
+			return
+		}
 		w := c.Writer
-		d, err := json.Marshal(globals)
+		d, err := json.Marshal(s.globals(h))
 		if err != nil {
 			log.Printf("could not marshal globals: %s", err)
 			c.String(http.StatusInternalServerError, "error")
@@ -142,10 +343,54 @@ func (s *server) registerRoutes() http.Handler {
 	// })
 
 	r.GET("/play", s.playHandler)
+	r.GET("/play/:room", s.playHandler)
+
+	r.GET("/rooms", func(c *gin.Context) {
+		rooms := []roomInfo{}
+		for _, r := range s.manager.List() {
+			rooms = append(rooms, roomInfo{r.Name, r.Config})
+		}
+		c.JSON(http.StatusOK, rooms)
+	})
+
+	r.POST("/rooms", s.createRoom)
+
+	r.DELETE("/rooms/:room", s.deleteRoom)
+
+	r.GET("/schedules", func(c *gin.Context) {
+		if h, ok := s.roomHub(c); ok {
+			c.JSON(http.StatusOK, h.room.Engine.Schedules())
+		}
+	})
+
+	r.POST("/schedules", s.createSchedule)
+
+	r.DELETE("/schedules/:id", s.deleteSchedule)
+
+	r.GET("/stats", func(c *gin.Context) {
+		if h, ok := s.roomHub(c); ok {
+			c.JSON(http.StatusOK, h.room.Engine.Stats())
+		}
+	})
+
+	r.GET("/census", func(c *gin.Context) {
+		if h, ok := s.roomHub(c); ok {
+			c.JSON(http.StatusOK, h.room.Engine.Census())
+		}
+	})
 
 	r.POST("/save", func(c *gin.Context) {
-		if lo := s.lastOutput.Load(); lo != nil {
-			err := s.db.WriteSeed(c, lo.([]byte))
+		h, ok := s.roomHub(c)
+		if !ok {
+			return
+		}
+		if lo := h.lastOutput.Load(); lo != nil {
+			var err error
+			if h.room.Name == engine.DefaultRoom {
+				err = s.db.WriteSeed(c, lo.([]byte))
+			} else {
+				err = s.db.WriteRoomSeed(c, h.room.Name, lo.([]byte))
+			}
 			if err != nil {
 				log.Printf("could not save seed: %s", err)
 				c.String(http.StatusInternalServerError, "could not save seed")
@@ -158,11 +403,134 @@ func (s *server) registerRoutes() http.Handler {
 	return r
 }
 
+func (s *server) createRoom(c *gin.Context) {
+	var req roomInfo
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.String(http.StatusBadRequest, "invalid room: %s", err)
+		return
+	}
+	config, err := json.Marshal(req.RoomConfig)
+	if err != nil {
+		log.Printf("could not marshal room config: %s", err)
+		c.String(http.StatusInternalServerError, "error")
+		return
+	}
+
+	r, err := s.manager.Create(req.Name, req.RoomConfig, nil)
+	if err != nil {
+		c.String(http.StatusBadRequest, "%s", err)
+		return
+	}
+	err = s.db.WriteRoom(c, r.Name, config)
+	if err != nil {
+		log.Printf("could not save room %q: %s", r.Name, err)
+		if err := s.manager.Delete(r.Name); err != nil {
+			log.Printf("could not delete room %q: %s", r.Name, err)
+		}
+		c.String(http.StatusInternalServerError, "could not save room")
+		return
+	}
+	s.addHub(r)
+
+	c.JSON(http.StatusCreated, roomInfo{r.Name, r.Config})
+}
+
+func (s *server) deleteRoom(c *gin.Context) {
+	name := c.Param("room")
+	err := s.manager.Delete(name)
+	if err != nil {
+		status := http.StatusNotFound
+		if name == engine.DefaultRoom {
+			status = http.StatusBadRequest
+		}
+		c.String(status, "%s", err)
+		return
+	}
+	s.hubsMtx.Lock()
+	delete(s.hubs, name)
+	s.hubsMtx.Unlock()
+
+	err = s.db.DeleteRoom(c, name)
+	if err != nil {
+		log.Printf("could not delete room %q: %s", name, err)
+		c.String(http.StatusInternalServerError, "could not delete room")
+		return
+	}
+	c.Status(http.StatusNoContent)
+}
+
+func (s *server) createSchedule(c *gin.Context) {
+	h, ok := s.roomHub(c)
+	if !ok {
+		return
+	}
+	var sch engine.Schedule
+	if err := c.ShouldBindJSON(&sch); err != nil {
+		c.String(http.StatusBadRequest, "invalid schedule: %s", err)
+		return
+	}
+	e := h.room.Engine
+	if err := sch.Validate(e.WorldWidth(), e.WorldHeight()); err != nil {
+		c.String(http.StatusBadRequest, "%s", err)
+		return
+	}
+	sch.ID = 0
+	d, err := json.Marshal(sch)
+	if err != nil {
+		log.Printf("could not marshal schedule: %s", err)
+		c.String(http.StatusInternalServerError, "error")
+		return
+	}
+
+	sch.ID, err = s.db.WriteSchedule(c, h.room.Name, d)
+	if err != nil {
+		log.Printf("could not save schedule: %s", err)
+		c.String(http.StatusInternalServerError, "could not save schedule")
+		return
+	}
+	e.AddSchedule(sch)
+
+	c.JSON(http.StatusCreated, sch)
+}
+
+func (s *server) deleteSchedule(c *gin.Context) {
+	h, ok := s.roomHub(c)
+	if !ok {
+		return
+	}
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil || !h.room.Engine.RemoveSchedule(id) {
+		c.String(http.StatusNotFound, "schedule %q does not exist", c.Param("id"))
+		return
+	}
+
+	err = s.db.DeleteSchedule(c, h.room.Name, id)
+	if err != nil {
+		log.Printf("could not delete schedule %d: %s", id, err)
+		c.String(http.StatusInternalServerError, "could not delete schedule")
+		return
+	}
+	c.Status(http.StatusNoContent)
+}
+
 func (s *server) playHandler(c *gin.Context) {
-	l := &listener{msgs: make(chan []byte, 4)}
-	s.addListener(l)
+	name := c.Param("room")
+	if name == "" {
+		name = engine.DefaultRoom
+	}
+	s.hubsMtx.RLock()
+	h, ok := s.hubs[name]
+	s.hubsMtx.RUnlock()
+	if !ok {
+		c.String(http.StatusNotFound, "room %q does not exist", name)
+		return
+	}
+
+	l := &listener{msgs: make(chan message, 4), stats: c.Query("stats") != "", latestStats: make(chan []byte, 1)}
+	session := engine.NewSession()
+	h.addListener(l)
 	defer func() {
-		s.removeListener(l)
+		h.removeListener(l)
 		close(l.msgs)
 	}()
 
@@ -181,6 +549,18 @@ func (s *server) playHandler(c *gin.Context) {
 	defer socket.CloseNow()
 
 	wsCtx, wsCancel := context.WithCancel(c.Request.Context())
+	// write reports whether the websocket is still open
+	write := func(msg message) bool {
+		err := socket.Write(wsCtx, msg.typ, msg.data)
+		if websocket.CloseStatus(err) == websocket.StatusNormalClosure || websocket.CloseStatus(err) == websocket.StatusGoingAway {
+			return false
+		}
+		if err != nil {
+			log.Printf("could not write to websocket: %s", err)
+			return false
+		}
+		return true
+	}
 	var wg sync.WaitGroup
 	wg.Add(1)
 
@@ -222,17 +602,19 @@ func (s *server) playHandler(c *gin.Context) {
 		select {
 		case <-wsCtx.Done():
 			return
-		case payload := <-l.msgs:
-			err := socket.Write(wsCtx, websocket.MessageBinary, payload)
-			if websocket.CloseStatus(err) == websocket.StatusNormalClosure || websocket.CloseStatus(err) == websocket.StatusGoingAway {
+		case <-h.room.Done():
+			socket.Close(websocket.StatusGoingAway, "room closed")
+			return
+		case msg := <-l.msgs:
+			if !write(msg) {
 				return
 			}
-			if err != nil {
-				log.Printf("could not write to websocket: %s", err)
+		case stats := <-l.latestStats:
+			if !write(message{websocket.MessageText, stats}) {
 				return
 			}
 		case msg := <-readerMsgChan:
-			err = s.engine.SubmitMessage(msg)
+			err = h.room.Engine.SubmitMessage(session, msg)
 			if err != nil {
 				log.Printf("websocket command produced an error: %s", err)
 			}
@@ -0,0 +1,102 @@
+package tui
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/JackWithOneEye/conwaymore/internal/conway"
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+)
+
+// censusRows is the number of objects listed in the census panel
+const censusRows = 20
+
+type CensusPanelModel struct {
+	census  *conway.Census
+	loading bool
+	err     error
+}
+
+func NewCensusPanelModel() *CensusPanelModel {
+	return &CensusPanelModel{}
+}
+
+func (m *CensusPanelModel) Init() tea.Cmd {
+	return nil
+}
+
+// SetLoading marks the census as being fetched
+func (m *CensusPanelModel) SetLoading() {
+	m.loading = true
+}
+
+func (m *CensusPanelModel) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
+	if msg, ok := msg.(censusResult); ok {
+		m.loading = false
+		m.census = msg.Census
+		m.err = msg.Err
+	}
+	return m, nil
+}
+
+func (m *CensusPanelModel) View() string {
+	var content strings.Builder
+	content.WriteString(lipgloss.NewStyle().Bold(true).Render("Object Census"))
+	content.WriteString("\n\n")
+
+	dimStyle := lipgloss.NewStyle().Foreground(lipgloss.Color("240"))
+	switch {
+	case m.err != nil:
+		content.WriteString(lipgloss.NewStyle().Foreground(errorFg).Render(fmt.Sprintf("Error: %s", m.err)))
+	case m.census == nil:
+		content.WriteString("Taking census...")
+	case len(m.census.Objects) == 0:
+		content.WriteString("The world is empty")
+	default:
+		fmt.Fprintf(&content, "Generation %d • %d islands\n\n", m.census.Generation, m.census.Islands)
+		fmt.Fprintf(&content, "%6s  %-24s %s\n", "Count", "Object", "Kind")
+		for _, obj := range m.census.Objects[:min(len(m.census.Objects), censusRows)] {
+			fmt.Fprintf(&content, "%6d  %-24s %s\n", obj.Count, truncate(objectLabel(obj), 24), objectKind(obj))
+		}
+		if more := len(m.census.Objects) - censusRows; more > 0 {
+			content.WriteString(dimStyle.Render(fmt.Sprintf("%6s  and %d more", "", more)))
+			content.WriteString("\n")
+		}
+	}
+
+	content.WriteString(dimStyle.Render("\nPress [o] to refresh, [Esc] to close"))
+
+	return modalStyle.
+		Width(60).
+		Align(lipgloss.Left).
+		Render(content.String())
+}
+
+// objectLabel names an object by its common name if it has one, else by its apgcode
+func objectLabel(obj conway.Object) string {
+	if obj.Name != "" {
+		return obj.Name
+	}
+	return obj.Code
+}
+
+// objectKind describes the kind of an object with its period and displacement
+func objectKind(obj conway.Object) string {
+	switch obj.Kind {
+	case conway.Oscillator:
+		return fmt.Sprintf("%s p%d", obj.Kind, obj.Period)
+	case conway.Spaceship:
+		return fmt.Sprintf("%s p%d (%d,%d)", obj.Kind, obj.Period, obj.DX, obj.DY)
+	}
+	return string(obj.Kind)
+}
+
+// truncate shortens s to at most n runes, ending with an ellipsis if it was cut
+func truncate(s string, n int) string {
+	r := []rune(s)
+	if len(r) <= n {
+		return s
+	}
+	return string(r[:n-1]) + "…"
+}
@@ -10,35 +10,50 @@ import (
 	"net/url"
 
 	"github.com/JackWithOneEye/conwaymore/cmd/web"
+	"github.com/JackWithOneEye/conwaymore/internal/conway"
 	"github.com/JackWithOneEye/conwaymore/internal/protocol"
 	tea "github.com/charmbracelet/bubbletea"
 	"github.com/coder/websocket"
 )
 
 type wsMessage struct {
-	Data []byte
-	Err  error
+	Data     []byte
+	Stats    *conway.Stats      // set instead of Data for the stats of a generation
+	Progress *protocol.Progress // set instead of Data for the progress of a fast-forward
+	Err      error
 }
 
 type connectionResult struct {
-	Conn      *websocket.Conn
-	Connected bool
-	Err       error
-	WorldSize uint
+	Conn         *websocket.Conn
+	Connected    bool
+	Err          error
+	Grid         conway.Grid
+	Rule         string
+	StateColours []uint32
+	WorldHeight  uint
+	WorldWidth   uint
 }
 
 type saveGameResult struct {
 	Err error
 }
 
-func connectToAPI(host string) tea.Cmd {
+type censusResult struct {
+	Census *conway.Census
+	Err    error
+}
+
+func connectToAPI(host, room string) tea.Cmd {
 	return func() tea.Msg {
-		worldSize, err := getWorldSize(host)
+		globals, err := getGlobals(host, room)
 		if err != nil {
-			return connectionResult{Conn: nil, Connected: false, Err: fmt.Errorf("could not get world size: %s", err)}
+			return connectionResult{Conn: nil, Connected: false, Err: fmt.Errorf("could not get globals: %s", err)}
 		}
 
-		u := url.URL{Scheme: "ws", Host: host, Path: "/play"}
+		u := url.URL{Scheme: "ws", Host: host, Path: "/play", RawQuery: "stats=1"}
+		if room != "" {
+			u.Path += "/" + room
+		}
 		conn, _, err := websocket.Dial(context.Background(), u.String(), nil)
 		if err != nil {
 			return connectionResult{Conn: nil, Connected: false, Err: fmt.Errorf("websocket connection failed: %s", err)}
@@ -46,16 +61,36 @@ func connectToAPI(host string) tea.Cmd {
 		// Set read limit to 32MB (same as WASM client)
 		conn.SetReadLimit(33554432) // 2^25
 
-		return connectionResult{Conn: conn, Connected: true, Err: nil, WorldSize: worldSize}
+		return connectionResult{
+			Conn:         conn,
+			Connected:    true,
+			Err:          nil,
+			Grid:         globals.Grid,
+			Rule:         globals.Rule,
+			StateColours: globals.StateColours,
+			WorldHeight:  globals.WorldHeight,
+			WorldWidth:   globals.WorldWidth,
+		}
 	}
 }
 
 func listenForMessages(conn *websocket.Conn) tea.Cmd {
 	return func() tea.Msg {
-		_, data, err := conn.Read(context.Background())
+		typ, data, err := conn.Read(context.Background())
 		if err != nil {
 			return wsMessage{Err: err}
 		}
+		if typ == websocket.MessageText {
+			var pm protocol.ProgressMessage
+			if err := json.Unmarshal(data, &pm); err == nil && pm.FastForward != nil {
+				return wsMessage{Progress: pm.FastForward}
+			}
+			var stats conway.Stats
+			if err := json.Unmarshal(data, &stats); err != nil {
+				return wsMessage{Err: fmt.Errorf("could not decode stats: %s", err)}
+			}
+			return wsMessage{Stats: &stats}
+		}
 		return wsMessage{Data: data}
 	}
 }
@@ -71,11 +106,23 @@ func sendCommand(conn *websocket.Conn, cmd protocol.CommandType) tea.Cmd {
 	}
 }
 
-func sendCells(conn *websocket.Conn, cells []protocol.Cell) tea.Cmd {
+func sendFastForward(conn *websocket.Conn, generations uint64) tea.Cmd {
+	return func() tea.Msg {
+		msg := &protocol.Command{Cmd: protocol.StepN, Arg: generations}
+		err := conn.Write(context.Background(), websocket.MessageBinary, msg.Encode())
+		if err != nil {
+			log.Printf("Error sending fast-forward: %v", err)
+		}
+		return nil
+	}
+}
+
+func sendCells(conn *websocket.Conn, cells []protocol.Cell, mode protocol.PasteMode) tea.Cmd {
 	return func() tea.Msg {
 		msg := &protocol.SetCells{
 			Count: uint16(len(cells)),
 			Cells: cells,
+			Mode:  mode,
 		}
 		err := conn.Write(context.Background(), websocket.MessageBinary, msg.Encode())
 		if err != nil {
@@ -85,6 +132,54 @@ func sendCells(conn *websocket.Conn, cells []protocol.Cell) tea.Cmd {
 	}
 }
 
+func sendKillCells(conn *websocket.Conn, coords []protocol.Coord) tea.Cmd {
+	return func() tea.Msg {
+		msg := &protocol.KillCells{
+			Count:  uint16(len(coords)),
+			Coords: coords,
+		}
+		err := conn.Write(context.Background(), websocket.MessageBinary, msg.Encode())
+		if err != nil {
+			log.Printf("Error killing cells: %v", err)
+		}
+		return nil
+	}
+}
+
+func sendClearRegions(conn *websocket.Conn, regions []protocol.ClearRegion) tea.Cmd {
+	return func() tea.Msg {
+		for _, msg := range regions {
+			err := conn.Write(context.Background(), websocket.MessageBinary, msg.Encode())
+			if err != nil {
+				log.Printf("Error clearing region: %v", err)
+				break
+			}
+		}
+		return nil
+	}
+}
+
+func sendEditRegion(conn *websocket.Conn, msg *protocol.EditRegion) tea.Cmd {
+	return func() tea.Msg {
+		err := conn.Write(context.Background(), websocket.MessageBinary, msg.Encode())
+		if err != nil {
+			log.Printf("Error editing region: %v", err)
+		}
+		return nil
+	}
+}
+
+func sendPasteClipboard(conn *websocket.Conn, x, y uint16, mode protocol.PasteMode) tea.Cmd {
+	return func() tea.Msg {
+		msg := &protocol.PasteClipboard{X: x, Y: y, Mode: mode}
+		err := conn.Write(context.Background(), websocket.MessageBinary, msg.Encode())
+		if err != nil {
+			log.Printf("Error pasting clipboard: %v", err)
+		}
+		return nil
+	}
+}
+
 func sendSpeed(conn *websocket.Conn, speed uint16) tea.Cmd {
 	return func() tea.Msg {
 		msg := &protocol.SetSpeed{Speed: speed}
@@ -96,9 +191,20 @@ func sendSpeed(conn *websocket.Conn, speed uint16) tea.Cmd {
 	}
 }
 
-func saveGame(host string) tea.Cmd {
+func sendTopology(conn *websocket.Conn, topology conway.Topology) tea.Cmd {
 	return func() tea.Msg {
-		u := url.URL{Scheme: "http", Host: host, Path: "/save"}
+		msg := &protocol.SetTopology{Topology: uint8(topology)}
+		err := conn.Write(context.Background(), websocket.MessageBinary, msg.Encode())
+		if err != nil {
+			log.Printf("Error sending topology: %v", err)
+		}
+		return nil
+	}
+}
+
+func saveGame(host, room string) tea.Cmd {
+	return func() tea.Msg {
+		u := url.URL{Scheme: "http", Host: host, Path: "/save", RawQuery: roomQuery(room)}
 		resp, err := http.DefaultClient.Post(u.String(), "", nil)
 		if err != nil {
 			log.Printf("Error saving game: %v", err)
@@ -109,31 +215,56 @@ func saveGame(host string) tea.Cmd {
 	}
 }
 
-func processServerMessage(data []byte) ([]protocol.Cell, bool, uint16, error) {
+func fetchCensus(host, room string) tea.Cmd {
+	return func() tea.Msg {
+		u := url.URL{Scheme: "http", Host: host, Path: "/census", RawQuery: roomQuery(room)}
+		resp, err := http.DefaultClient.Get(u.String())
+		if err != nil {
+			return censusResult{Err: err}
+		}
+		defer resp.Body.Close()
+		census := &conway.Census{}
+		err = json.NewDecoder(resp.Body).Decode(census)
+		if err != nil {
+			return censusResult{Err: fmt.Errorf("could not decode census: %s", err)}
+		}
+		return censusResult{Census: census}
+	}
+}
+
+func processServerMessage(data []byte) (*protocol.Output, error) {
 	var output protocol.Output
 	err := output.Decode(data)
 	if err != nil {
-		return nil, false, 0, fmt.Errorf("failed to decode server message: %w", err)
+		return nil, fmt.Errorf("failed to decode server message: %w", err)
 	}
 
-	return output.Cells, output.Playing, output.Speed, nil
+	return &output, nil
 }
 
-func getWorldSize(host string) (uint, error) {
-	u := url.URL{Scheme: "http", Host: host, Path: "/globals"}
+func getGlobals(host, room string) (*web.Globals, error) {
+	u := url.URL{Scheme: "http", Host: host, Path: "/globals", RawQuery: roomQuery(room)}
 	resp, err := http.DefaultClient.Get(u.String())
 	if err != nil {
-		return 0, err
+		return nil, err
 	}
 	defer resp.Body.Close()
 	d, err := io.ReadAll(resp.Body)
 	if err != nil {
-		return 0, err
+		return nil, err
 	}
 	g := &web.Globals{}
 	err = json.Unmarshal(d, g)
 	if err != nil {
-		return 0, err
+		return nil, err
+	}
+	return g, nil
+}
+
+// roomQuery returns the query selecting the room in the HTTP endpoints
+func roomQuery(room string) string {
+	if room == "" {
+		return ""
 	}
-	return g.WorldSize, nil
+	return url.Values{"room": {room}}.Encode()
 }
@@ -11,18 +11,21 @@ const (
 	Help foregroundType = iota
 	ColorPicker
 	PatternSelector
+	CensusPanel
 )
 
 type foregroundModel struct {
 	fgType          foregroundType
 	colorPicker     *ColorPickerModel
 	patternSelector *PatternSelectorModel
+	censusPanel     *CensusPanelModel
 	currentColor    uint32
 }
 
 func (h *foregroundModel) Init() tea.Cmd {
 	h.colorPicker = NewColorPickerModel()
 	h.patternSelector = NewPatternSelectorModel()
+	h.censusPanel = NewCensusPanelModel()
 	h.currentColor = 0xFFFFFF // Default white
 	return nil
 }
@@ -39,6 +42,9 @@ func (h *foregroundModel) Update(message tea.Msg) (tea.Model, tea.Cmd) {
 		case "ctrl+n":
 			h.fgType = PatternSelector
 			h.patternSelector.SetCurrentColor(h.currentColor)
+		case "o":
+			h.fgType = CensusPanel
+			h.censusPanel.SetLoading()
 		}
 		if h.fgType == ColorPicker {
 			_, cmd := h.colorPicker.Update(message)
@@ -48,6 +54,9 @@ func (h *foregroundModel) Update(message tea.Msg) (tea.Model, tea.Cmd) {
 			_, cmd := h.patternSelector.Update(message)
 			return h, cmd
 		}
+	case censusResult:
+		_, cmd := h.censusPanel.Update(message)
+		return h, cmd
 	}
 	return h, nil
 }
@@ -65,6 +74,8 @@ func (h *foregroundModel) View() string {
 		return h.colorPicker.View()
 	case PatternSelector:
 		return h.patternSelector.View()
+	case CensusPanel:
+		return h.censusPanel.View()
 	}
 	return ""
 }
@@ -76,11 +87,21 @@ Game Controls:
   [space]  Play/Pause simulation
   [r]      Randomize grid
   [x]      Clear grid  
+  [X]      Clear visible region
+  [u/U]    Undo/redo your last edit
+  [e]      Toggle eraser (clicks kill cells)
+  [v]      Toggle select tool (drag a region)
   [n]      Next step (when paused)
+  [p]      Previous step (when paused)
+  [g]      Jump 1000 generations (when paused)
+  [G]      Fast-forward 100000 generations/cancel
+  [t]      Cycle world topology
+  [c]      Cycle cell state (rule tables only)
+  [o]      Show object census
 
 Speed Control:
   [S]      Decrease speed (+ 1ms)
-  [s]      Increase speed (- 1ms)
+  [s]      Increase speed (- 1ms, 0 = max)
 
 Viewport Navigation:
   [h/←]    Move viewport left
@@ -92,11 +113,22 @@ Viewport Navigation:
   [K]      Move viewport up (10 units)
   [L]      Move viewport right (10 units)
 
+Selection:
+  [y]      Copy selection
+  [d]      Cut selection
+  [P]      Paste at selection or view corner
+  [R]      Rotate selection clockwise
+  [f/F]    Flip selection left/right, up/down
+  [C]      Recolor selection
+  [Alt+hjkl] Move selection
+  [Esc]    Clear selection
+
 Color Controls:
   [Ctrl+p] Open color picker
 
 Pattern Library:
   [Ctrl+n] Open pattern selector
+  [m]      Cycle paste mode (when placing)
 
 General:
   [?]      Show this help
@@ -5,7 +5,9 @@ import (
 	"strings"
 	"sync/atomic"
 	"time"
+	"unicode/utf8"
 
+	"github.com/JackWithOneEye/conwaymore/internal/conway"
 	"github.com/JackWithOneEye/conwaymore/internal/lrucache"
 	"github.com/JackWithOneEye/conwaymore/internal/patterns"
 	"github.com/JackWithOneEye/conwaymore/internal/protocol"
@@ -23,9 +25,37 @@ var sgrPrefixCache = lrucache.NewLruCache[uint64, string](2048)
 
 const emptyCell uint32 = 0xffffffff
 
+// jumpGenerations is the number of generations skipped by a single jump
+const jumpGenerations = 1000
+
+// fastForwardGenerations is the number of generations skipped by a single fast-forward
+const fastForwardGenerations = 100000
+
+// graphGenerations is the number of generations shown in the population graph
+const graphGenerations = 16
+
+// selectionColor is the color of empty cells within the selected region
+const selectionColor uint32 = 0x303030
+
+// selection is a region of the world, whose corner is given in world coordinates
+type selection struct {
+	x, y          int
+	width, height int
+}
+
 type gameModel struct {
-	worldSize    int
-	grid         [][]uint32 // value grid: 0 = empty, non-zero = color
+	worldWidth   int
+	worldHeight  int
+	rule         string
+	shape        conway.Grid // cell shape of the rule, square unless hexagonal or triangular
+	topology     conway.Topology
+	generation   uint64
+	sequence     uint32             // of the last output shown, 0 before the first one
+	period       uint16             // of the world, 0 until it repeats
+	rate         uint32             // generations per second computed by the engine
+	stats        []conway.Stats     // of the last generations, the oldest first
+	fastForward  *protocol.Progress // of the running fast-forward, nil if there is none
+	grid         [][]uint32         // value grid: emptyCell = empty, otherwise decay stage << 24 | color
 	cells        []protocol.Cell
 	width        int
 	height       int
@@ -34,19 +64,29 @@ type gameModel struct {
 	connected    bool
 	conn         *websocket.Conn
 	apiHost      string
+	room         string // empty for the default room
 	speed        atomic.Uint32
 	err          error
-	hasHalfCol   bool   // true if rightmost column should be drawn as half-width
-	termWidth    int    // terminal width for responsive layout
-	viewportX    int    // viewport offset X (camera position)
-	viewportY    int    // viewport offset Y (camera position)
-	currentColor uint32 // currently selected color for new cells
+	hasHalfCol   bool     // true if rightmost column should be drawn as half-width
+	termWidth    int      // terminal width for responsive layout
+	viewportX    int      // viewport offset X (camera position)
+	viewportY    int      // viewport offset Y (camera position)
+	currentColor uint32   // currently selected color for new cells
+	currentState uint8    // currently selected state for new cells, only for rule tables
+	erasing      bool     // true if clicks kill cells instead of setting them
+	stateColours []uint32 // color of each cell state, only for rule tables
 	spinner      spinner.Model
 
 	// Pattern placement mode
-	placingPattern  bool              // true when in pattern placement mode
-	currentPattern  *patterns.Pattern // pattern being placed
-	patternCanPlace bool              // true if pattern can be placed at current position
+	placingPattern  bool               // true when in pattern placement mode
+	currentPattern  *patterns.Pattern  // pattern being placed
+	patternCanPlace bool               // true if pattern can be placed at current position
+	pasteMode       protocol.PasteMode // how the pattern combines with the cells below it
+
+	// Region selection
+	selecting       bool       // true if clicks select a region instead of setting cells
+	selectionAnchor [2]int     // grid position where the mouse was pressed
+	selection       *selection // selected region, nil if there is none
 
 	// Performance optimizations
 	pendingData    []byte // latest WebSocket message data, processed on tick
@@ -94,7 +134,7 @@ func (m *gameModel) Init() tea.Cmd {
 	m.cellStyleCache = make(map[uint32]lipgloss.Style)
 	// Initialize all rows as dirty for first render
 	m.markAllRowsDirty()
-	return tea.Batch(connectToAPI(m.apiHost), tick())
+	return tea.Batch(connectToAPI(m.apiHost, m.room), tick())
 }
 
 func (m *gameModel) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
@@ -115,38 +155,47 @@ func (m *gameModel) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 			return m, nil
 		}
 
-		if msg.Action == tea.MouseActionRelease && msg.Button == tea.MouseButtonLeft && m.isConnected() {
-			// Calculate grid position from mouse click
-			// Account for header lines and frame borders
-			headerLines := 1
-			if m.err != nil {
-				headerLines = 2
+		if m.selecting && msg.Button == tea.MouseButtonLeft {
+			gridX, gridY, ok := m.mouseToGrid(msg)
+			if !ok {
+				return m, nil
 			}
-
-			// Check if click is within the grid area
-			clickY := msg.Y - headerLines - 1 // Subtract header and top border
-			clickX := msg.X - 1               // Subtract left border
-
-			if clickY >= 0 && clickY < m.height && clickX >= 0 {
-				// Convert screen coordinates to grid coordinates
-				gridX := clickX / 2 // Each cell is 2 characters wide (or 1 for half column)
-				if m.hasHalfCol && gridX >= m.width-1 {
-					gridX = m.width - 1
+			switch msg.Action {
+			case tea.MouseActionPress:
+				m.selectionAnchor = [2]int{gridX, gridY}
+			case tea.MouseActionRelease:
+				x0, y0 := min(gridX, m.selectionAnchor[0]), min(gridY, m.selectionAnchor[1])
+				worldX, worldY := m.viewportToWorld(x0, y0)
+				m.selection = &selection{
+					x:      worldX,
+					y:      worldY,
+					width:  max(gridX, m.selectionAnchor[0]) - x0 + 1,
+					height: max(gridY, m.selectionAnchor[1]) - y0 + 1,
 				}
-				gridY := clickY
+				m.markAllRowsDirty()
+			}
+			return m, nil
+		}
 
+		if msg.Action == tea.MouseActionRelease && msg.Button == tea.MouseButtonLeft && m.isConnected() {
+			if gridX, gridY, ok := m.mouseToGrid(msg); ok {
 				// Convert grid coordinates to world coordinates (apply viewport offset)
 				worldX, worldY := m.viewportToWorld(gridX, gridY)
 
+				if m.erasing {
+					return m, sendKillCells(m.conn, []protocol.Coord{{X: uint16(worldX), Y: uint16(worldY)}})
+				}
+
 				// Create a cell at this position with the current color
 				newCell := protocol.Cell{
 					X:      uint16(worldX),
 					Y:      uint16(worldY),
 					Colour: m.currentColor,
 					Age:    1, // New cell starts at age 1
+					State:  m.currentState,
 				}
 
-				return m, sendCells(m.conn, []protocol.Cell{newCell})
+				return m, sendCells(m.conn, []protocol.Cell{newCell}, protocol.PasteReject)
 			}
 		}
 	case tea.KeyMsg:
@@ -175,8 +224,11 @@ func (m *gameModel) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 					cells := m.getPatternCells()
 					m.placingPattern = false
 					m.markAllRowsDirty() // Remove dimmed effect
-					return m, sendCells(m.conn, cells)
+					return m, sendCells(m.conn, cells, m.pasteMode)
 				}
+			case "m":
+				m.pasteMode = (m.pasteMode + 1) % protocol.PasteMode(len(protocol.PasteModes()))
+				m.updatePatternCanPlace()
 			case "esc":
 				// Abort pattern placement
 				m.placingPattern = false
@@ -202,10 +254,83 @@ func (m *gameModel) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 			if m.isConnected() {
 				return m, sendCommand(m.conn, protocol.Clear)
 			}
+		case "X":
+			if m.isConnected() {
+				return m, sendClearRegions(m.conn, m.viewportRegions())
+			}
+		case "u":
+			if m.isConnected() {
+				return m, sendCommand(m.conn, protocol.Undo)
+			}
+		case "U":
+			if m.isConnected() {
+				return m, sendCommand(m.conn, protocol.Redo)
+			}
+		case "e":
+			m.erasing = !m.erasing
+			m.selecting = false
+		case "v":
+			m.selecting = !m.selecting
+			m.erasing = false
+		case "esc":
+			m.selection = nil
+			m.markAllRowsDirty()
+		case "y":
+			return m, m.editSelection(protocol.RegionCopy)
+		case "d":
+			return m, m.editSelection(protocol.RegionCut)
+		case "R":
+			return m, m.editSelection(protocol.RegionRotateCW)
+		case "f":
+			return m, m.editSelection(protocol.RegionFlipHorizontal)
+		case "F":
+			return m, m.editSelection(protocol.RegionFlipVertical)
+		case "C":
+			return m, m.editSelection(protocol.RegionRecolour)
+		case "alt+h", "alt+left":
+			return m, m.moveSelection(-1, 0)
+		case "alt+j", "alt+down":
+			return m, m.moveSelection(0, 1)
+		case "alt+k", "alt+up":
+			return m, m.moveSelection(0, -1)
+		case "alt+l", "alt+right":
+			return m, m.moveSelection(1, 0)
+		case "P":
+			if m.isConnected() {
+				x, y := m.viewportToWorld(0, 0)
+				if m.selection != nil {
+					x, y = m.selection.x, m.selection.y
+				}
+				return m, sendPasteClipboard(m.conn, uint16(x), uint16(y), m.pasteMode)
+			}
 		case "n":
 			if m.isConnected() {
 				return m, sendCommand(m.conn, protocol.Next)
 			}
+		case "p":
+			if m.isConnected() {
+				return m, sendCommand(m.conn, protocol.Previous)
+			}
+		case "g":
+			if m.isConnected() {
+				return m, sendFastForward(m.conn, jumpGenerations)
+			}
+		case "G":
+			if m.isConnected() {
+				if m.fastForward != nil {
+					return m, sendCommand(m.conn, protocol.CancelFastForward)
+				}
+				return m, sendFastForward(m.conn, fastForwardGenerations)
+			}
+		case "c":
+			if len(m.stateColours) > 0 {
+				m.currentState = (m.currentState + 1) % uint8(len(m.stateColours))
+			}
+		case "t":
+			if m.isConnected() {
+				next := (m.topology + 1) % conway.Topology(len(conway.Topologies()))
+				return m, sendTopology(m.conn, next)
+			}
 		case "h", "left":
 			m.moveViewport(-1, 0)
 		case "j", "down":
@@ -229,7 +354,7 @@ func (m *gameModel) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 			}
 		case "s":
 			speed := m.speed.Load()
-			if m.isConnected() && speed > 1 {
+			if m.isConnected() && speed > 0 {
 				speed -= 1
 				m.speed.Store(speed)
 				return m, sendSpeed(m.conn, uint16(speed))
@@ -237,7 +362,7 @@ func (m *gameModel) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 		case "ctrl+s":
 			if m.isConnected() {
 				m.saving = true
-				return m, tea.Batch(m.spinner.Tick, saveGame(m.apiHost))
+				return m, tea.Batch(m.spinner.Tick, saveGame(m.apiHost, m.room))
 			}
 		}
 	case quitMessage:
@@ -250,7 +375,12 @@ func (m *gameModel) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 		m.connected = msg.Connected
 		m.err = msg.Err
 		m.conn = msg.Conn
-		m.worldSize = int(msg.WorldSize)
+		m.sequence = 0
+		m.rule = msg.Rule
+		m.shape = msg.Grid
+		m.stateColours = msg.StateColours
+		m.worldWidth = int(msg.WorldWidth)
+		m.worldHeight = int(msg.WorldHeight)
 		if m.isConnected() {
 			// Start listening for messages
 			return m, listenForMessages(m.conn)
@@ -262,8 +392,17 @@ func (m *gameModel) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 			return m, nil
 		}
 
-		// Cache the latest data instead of processing immediately
-		m.pendingData = msg.Data
+		if msg.Stats != nil {
+			m.recordStats(*msg.Stats)
+		} else if msg.Progress != nil {
+			m.fastForward = msg.Progress
+			if msg.Progress.Done {
+				m.fastForward = nil
+			}
+		} else {
+			// Cache the latest data instead of processing immediately
+			m.pendingData = msg.Data
+		}
 
 		// Continue listening for messages
 		if m.isConnected() {
@@ -272,13 +411,24 @@ func (m *gameModel) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 	case tickMsg:
 		// Process pending data at 30 FPS
 		if m.pendingData != nil {
-			cells, playing, speed, err := processServerMessage(m.pendingData)
-			if err != nil {
+			output, err := processServerMessage(m.pendingData)
+			switch {
+			case err != nil:
 				m.err = err
-			} else {
-				m.cells = cells
-				m.running = playing
-				m.speed.Store(uint32(speed))
+			case m.sequence != 0 && !output.After(m.sequence):
+				// an older output that arrived late
+			default:
+				m.sequence = output.Sequence
+				m.generation = output.Generation
+				if output.Rule != "" {
+					m.rule = output.Rule
+				}
+				m.cells = output.Cells
+				m.running = output.Playing
+				m.speed.Store(uint32(output.Speed))
+				m.topology = conway.Topology(output.Topology)
+				m.period = output.Period
+				m.rate = output.GenerationsPerSecond
 				m.updateGrid()
 				m.lastUpdate = time.Now()
 			}
@@ -336,7 +486,20 @@ func (m *gameModel) View() string {
 	if m.saving {
 		title += fmt.Sprintf(" %s", m.spinner.View())
 	}
-	colorSwatch := styleFor(m.currentColor, m.cellStyleCache).Render("██")
+	swatchColor := m.currentColor
+	if len(m.stateColours) > 0 {
+		swatchColor = m.stateColours[m.currentState]
+	}
+	tool := "Color: " + styleFor(swatchColor, m.cellStyleCache).Render("██")
+	if m.erasing {
+		tool = "Tool: Eraser"
+	}
+	if m.selecting {
+		tool = "Tool: Select"
+	}
+	if m.selection != nil {
+		tool += fmt.Sprintf(" • Selection: %dx%d at (%d,%d)", m.selection.width, m.selection.height, m.selection.x, m.selection.y)
+	}
 
 	statusText := ""
 	if m.placingPattern {
@@ -344,18 +507,24 @@ func (m *gameModel) View() string {
 		if !m.patternCanPlace {
 			placementStatus = "✗ Cannot Place"
 		}
-		statusText = fmt.Sprintf("Placing: %s • %s • Viewport: (%d,%d) • [Arrow/hjkl] Move View [Enter] Place [Esc] Cancel",
+		statusText = fmt.Sprintf("Placing: %s • Mode: %s • %s • Viewport: (%d,%d) • [Arrow/hjkl] Move View [m] Mode [Enter] Place [Esc] Cancel",
 			m.currentPattern.Name,
+			m.pasteMode,
 			placementStatus,
 			m.viewportX, m.viewportY)
 	} else {
-		statusText = fmt.Sprintf("Size: %dx%d • %s • %s • Speed: %d ms • View: (%d,%d) • Color: %s",
+		statusText = fmt.Sprintf("Size: %dx%d • Rule: %s • Gen: %d • Topology: %s • %s • %s • %s • %s • Speed: %s • View: (%d,%d) • %s",
 			m.width, m.height,
-			runningStatus(m.running),
+			m.rule,
+			m.generation,
+			m.topology,
+			runningStatus(m.running, m.fastForward),
+			worldStatus(m.period, len(m.cells)),
+			populationStatus(m.stats),
 			connectedStatus(m.connected),
-			m.speed.Load(),
+			speedStatus(m.speed.Load(), m.rate),
 			m.viewportX, m.viewportY,
-			colorSwatch)
+			tool)
 	}
 
 	status := statusStyle.Render(statusText)
@@ -396,11 +565,7 @@ func (m *gameModel) View() string {
 
 	grid := lipgloss.JoinVertical(lipgloss.Left, m.renderedRows...)
 	// Ensure the grid has a fixed width so the frame doesn't collapse when rows are empty or unchanged
-	gridWidthChars := m.width * 2
-	if m.hasHalfCol {
-		gridWidthChars = (m.width-1)*2 + 1
-	}
-	grid = lipgloss.NewStyle().Width(gridWidthChars).Render(grid)
+	grid = lipgloss.NewStyle().Width(m.gridWidthChars()).Render(grid)
 	framedGrid := frameStyle.Render(grid)
 	s.WriteString(framedGrid)
 
@@ -413,33 +578,31 @@ func (m *gameModel) renderRowRLE(y int) string {
 	// Rough capacity: 2 chars per cell + some ANSI overhead
 	b.Grow(m.width*2 + 64)
 
+	// Characters left in this row; staggered rows start with a half-width gap
+	remaining := m.gridWidthChars()
+	if m.isStaggered(y) {
+		b.WriteByte(' ')
+		remaining -= 1
+	}
+
 	currentColor := emptyCell
-	currentHalf := false
-	runLen := 0
+	var run strings.Builder
 
 	flush := func() {
-		if runLen == 0 {
+		if run.Len() == 0 {
 			return
 		}
 		if currentColor == emptyCell {
-			if currentHalf {
-				b.WriteString(strings.Repeat(" ", runLen))
-			} else {
-				b.WriteString(strings.Repeat("  ", runLen))
-			}
+			b.WriteString(run.String())
 		} else {
 			b.WriteString(getSGRPrefix(currentColor))
-			if currentHalf {
-				b.WriteString(strings.Repeat("█", runLen))
-			} else {
-				b.WriteString(strings.Repeat("██", runLen))
-			}
+			b.WriteString(run.String())
 			b.WriteString("\x1b[0m")
 		}
-		runLen = 0
+		run.Reset()
 	}
 
-	for x := 0; x < m.width; x++ {
+	for x := 0; x < m.width && remaining > 0; x++ {
 		displayColor := emptyCell
 		if m.placingPattern && m.isPatternCell(x, y) {
 			if m.patternCanPlace {
@@ -451,34 +614,72 @@ func (m *gameModel) renderRowRLE(y int) string {
 			cell := m.grid[y][x]
 			if cell != emptyCell {
 				if m.placingPattern {
-					displayColor = dimColor(cell)
+					displayColor = dimColor(fadeColor(cell))
 				} else {
-					displayColor = cell
+					displayColor = fadeColor(cell)
 				}
+			} else if !m.placingPattern && m.isSelected(x, y) {
+				displayColor = selectionColor
 			}
 		}
-		half := m.hasHalfCol && x == m.width-1
-
-		if runLen == 0 {
-			currentColor = displayColor
-			currentHalf = half
-			runLen = 1
-			continue
-		}
-		if displayColor == currentColor && half == currentHalf {
-			runLen++
+		half := remaining == 1
+		if half {
+			remaining -= 1
 		} else {
+			remaining -= 2
+		}
+
+		if displayColor != currentColor {
 			flush()
 			currentColor = displayColor
-			currentHalf = half
-			runLen = 1
 		}
+		run.WriteString(m.cellGlyph(x, y, displayColor == emptyCell, half))
 	}
 	flush()
 
 	return b.String()
 }
 
+// cellGlyph returns the characters used to draw the cell at the given grid position
+func (m *gameModel) cellGlyph(x, y int, empty, half bool) string {
+	glyph := "██"
+	switch {
+	case empty:
+		glyph = "  "
+	case m.shape == conway.TriangularGrid:
+		// Triangles point up on even and down on odd world cells
+		worldX, worldY := m.viewportToWorld(x, y)
+		if (worldX+worldY)&1 == 0 {
+			glyph = "◢◣"
+		} else {
+			glyph = "◥◤"
+		}
+	}
+	if half {
+		_, size := utf8.DecodeRuneInString(glyph)
+		return glyph[:size]
+	}
+	return glyph
+}
+
+// gridWidthChars returns the width of the grid in characters
+func (m *gameModel) gridWidthChars() int {
+	if m.hasHalfCol {
+		return (m.width-1)*2 + 1
+	}
+	return m.width * 2
+}
+
+// isStaggered reports whether the given grid row is shifted right by half a cell,
+// which is the case for odd world rows of a hexagonal grid
+func (m *gameModel) isStaggered(y int) bool {
+	if m.shape != conway.HexagonalGrid {
+		return false
+	}
+	_, worldY := m.viewportToWorld(0, y)
+	return worldY&1 == 1
+}
+
 // getPatternCells converts the current pattern to protocol.Cell slice for placement
 func (m *gameModel) getPatternCells() []protocol.Cell {
 	positions := m.getPatternPositions()
@@ -492,6 +693,7 @@ func (m *gameModel) getPatternCells() []protocol.Cell {
 			X:      uint16(worldX),
 			Y:      uint16(worldY),
 			Colour: m.currentColor,
+			State:  m.currentState,
 		}
 
 		cells = append(cells, cell)
@@ -542,6 +744,17 @@ func (m *gameModel) isConnected() bool {
 	return m.connected && m.conn != nil
 }
 
+// isSelected checks if the given grid position lies within the selected region
+func (m *gameModel) isSelected(gridX, gridY int) bool {
+	if m.selection == nil {
+		return false
+	}
+	worldX, worldY := m.viewportToWorld(gridX, gridY)
+	dx := (worldX - m.selection.x + m.worldWidth) % m.worldWidth
+	dy := (worldY - m.selection.y + m.worldHeight) % m.worldHeight
+	return dx < m.selection.width && dy < m.selection.height
+}
+
 // isPatternCell checks if the given grid position contains a pattern cell
 func (m *gameModel) isPatternCell(gridX, gridY int) bool {
 	positions := m.getPatternPositions()
@@ -553,20 +766,90 @@ func (m *gameModel) isPatternCell(gridX, gridY int) bool {
 	return false
 }
 
+// mouseToGrid converts the screen position of a mouse event to grid coordinates,
+// returning false if it lies outside of the grid
+func (m *gameModel) mouseToGrid(msg tea.MouseMsg) (gridX, gridY int, ok bool) {
+	// Account for header lines and frame borders
+	headerLines := 1
+	if m.err != nil {
+		headerLines = 2
+	}
+
+	// Check if click is within the grid area
+	clickY := msg.Y - headerLines - 1 // Subtract header and top border
+	clickX := msg.X - 1               // Subtract left border
+	if clickY < 0 || clickY >= m.height || clickX < 0 {
+		return 0, 0, false
+	}
+
+	// Convert screen coordinates to grid coordinates
+	gridY = clickY
+	if m.isStaggered(gridY) {
+		clickX = max(clickX-1, 0) // Staggered rows start with a half-width gap
+	}
+	gridX = clickX / 2 // Each cell is 2 characters wide (or 1 for half column)
+	if gridX >= m.width-1 {
+		gridX = m.width - 1
+	}
+	return gridX, gridY, true
+}
+
+// editSelection applies a region operation to the selected region
+func (m *gameModel) editSelection(op protocol.RegionOp) tea.Cmd {
+	if m.selection == nil || !m.isConnected() {
+		return nil
+	}
+	msg := m.selection.region(op)
+	msg.Colour = m.currentColor
+	if op == protocol.RegionRotateCW {
+		// the server rotates the region around its centre
+		w, h := m.selection.width, m.selection.height
+		m.selection.x = (m.selection.x + (w-h)/2 + m.worldWidth) % m.worldWidth
+		m.selection.y = (m.selection.y + (h-w)/2 + m.worldHeight) % m.worldHeight
+		m.selection.width, m.selection.height = h, w
+		m.markAllRowsDirty()
+	}
+	return sendEditRegion(m.conn, msg)
+}
+
+// moveSelection moves the cells of the selected region and the selection with them
+func (m *gameModel) moveSelection(deltaX, deltaY int) tea.Cmd {
+	if m.selection == nil || !m.isConnected() {
+		return nil
+	}
+	msg := m.selection.region(protocol.RegionMove)
+	msg.DX, msg.DY = int16(deltaX), int16(deltaY)
+	m.selection.x = (m.selection.x + deltaX + m.worldWidth) % m.worldWidth
+	m.selection.y = (m.selection.y + deltaY + m.worldHeight) % m.worldHeight
+	m.markAllRowsDirty()
+	return sendEditRegion(m.conn, msg)
+}
+
+// region returns the message applying a region operation to the selection
+func (s *selection) region(op protocol.RegionOp) *protocol.EditRegion {
+	return &protocol.EditRegion{
+		Op:     op,
+		X:      uint16(s.x),
+		Y:      uint16(s.y),
+		Width:  uint16(s.width),
+		Height: uint16(s.height),
+	}
+}
+
 // moveViewport moves the viewport by the given delta and handles wrapping
 func (m *gameModel) moveViewport(deltaX, deltaY int) {
 	m.viewportX += deltaX
 	if m.viewportX < 0 {
-		m.viewportX += m.worldSize
-	} else if m.viewportX >= m.worldSize {
-		m.viewportX -= m.worldSize
+		m.viewportX += m.worldWidth
+	} else if m.viewportX >= m.worldWidth {
+		m.viewportX -= m.worldWidth
 	}
 
 	m.viewportY += deltaY
 	if m.viewportY < 0 {
-		m.viewportY += m.worldSize
-	} else if m.viewportY >= m.worldSize {
-		m.viewportY -= m.worldSize
+		m.viewportY += m.worldHeight
+	} else if m.viewportY >= m.worldHeight {
+		m.viewportY -= m.worldHeight
 	}
 
 	m.updateGrid()
@@ -610,7 +893,12 @@ func (m *gameModel) updateGrid() {
 		// Only track cells within the visible viewport
 		if screenX >= 0 && screenX < m.width && screenY >= 0 && screenY < m.height {
 			key := uint64(screenX)<<32 | uint64(screenY)
-			m.currentCells[key] = cell.Colour
+			if len(m.stateColours) > 0 {
+				// cells of rule tables are colored by their state
+				m.currentCells[key] = gridValue(m.stateColours[min(int(cell.State), len(m.stateColours)-1)], 0)
+			} else {
+				m.currentCells[key] = gridValue(cell.Colour, cell.State)
+			}
 		}
 	}
 
@@ -655,8 +943,10 @@ func (m *gameModel) updatePatternCanPlace() {
 		// Only check for overlap with existing cells within viewport bounds
 		// Allow patterns to extend beyond viewport - they just won't be visible
 		if pos.x >= 0 && pos.x < m.width && pos.y >= 0 && pos.y < m.height {
-			// Check if there's already a cell at this position
-			m.patternCanPlace = m.grid[pos.y][pos.x] == emptyCell
+			// Only the reject mode fails on cells that are already there
+			if m.pasteMode == protocol.PasteReject && m.grid[pos.y][pos.x] != emptyCell {
+				m.patternCanPlace = false
+			}
 			m.rowDirty[pos.y] = true
 		}
 	}
@@ -664,22 +954,63 @@ func (m *gameModel) updatePatternCanPlace() {
 
 // viewportToWorld converts viewport coordinates to world coordinates
 func (m *gameModel) viewportToWorld(viewportX, viewportY int) (worldX, worldY int) {
-	worldX = (viewportX + m.viewportX) % m.worldSize
-	worldY = (viewportY + m.viewportY) % m.worldSize
+	worldX = (viewportX + m.viewportX) % m.worldWidth
+	worldY = (viewportY + m.viewportY) % m.worldHeight
 	return worldX, worldY
 }
 
+// viewportRegions returns the regions of the world shown in the viewport, which is
+// split where it wraps around the edges of the world
+func (m *gameModel) viewportRegions() []protocol.ClearRegion {
+	x0, y0 := m.viewportToWorld(0, 0)
+	w, h := min(m.width, m.worldWidth), min(m.height, m.worldHeight)
+
+	xs := [][2]int{{x0, min(w, m.worldWidth-x0)}}
+	if x0+w > m.worldWidth {
+		xs = append(xs, [2]int{0, x0 + w - m.worldWidth})
+	}
+	ys := [][2]int{{y0, min(h, m.worldHeight-y0)}}
+	if y0+h > m.worldHeight {
+		ys = append(ys, [2]int{0, y0 + h - m.worldHeight})
+	}
+
+	var regions []protocol.ClearRegion
+	for _, x := range xs {
+		for _, y := range ys {
+			regions = append(regions, protocol.ClearRegion{
+				X:      uint16(x[0]),
+				Y:      uint16(y[0]),
+				Width:  uint16(x[1]),
+				Height: uint16(y[1]),
+			})
+		}
+	}
+	return regions
+}
+
 // worldToViewport converts world coordinates to viewport coordinates
 func (m *gameModel) worldToViewport(worldX, worldY int) (viewportX, viewportY int) {
 	viewportX = worldX - m.viewportX
 	if viewportX < 0 {
-		viewportX += m.worldSize
+		viewportX += m.worldWidth
 	}
 
 	viewportY = worldY - m.viewportY
 	if viewportY < 0 {
-		viewportY += m.worldSize
+		viewportY += m.worldHeight
 	}
 
 	return viewportX, viewportY
 }
+
+// recordStats keeps the stats of the last generations for the population graph
+func (m *gameModel) recordStats(stats conway.Stats) {
+	// later generations are gone when the world was changed or went back
+	for len(m.stats) > 0 && m.stats[len(m.stats)-1].Generation >= stats.Generation {
+		m.stats = m.stats[:len(m.stats)-1]
+	}
+	m.stats = append(m.stats, stats)
+	if len(m.stats) > graphGenerations {
+		m.stats = m.stats[1:]
+	}
+}
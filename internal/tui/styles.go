@@ -2,7 +2,10 @@ package tui
 
 import (
 	"fmt"
+	"strings"
 
+	"github.com/JackWithOneEye/conwaymore/internal/conway"
+	"github.com/JackWithOneEye/conwaymore/internal/protocol"
 	"github.com/charmbracelet/lipgloss"
 )
 
@@ -101,6 +104,27 @@ func dimColor(color uint32) uint32 {
 	return (r << 16) | (g << 8) | b
 }
 
+// gridValue packs a cell's decay stage into the unused upper byte of its color
+func gridValue(color uint32, stage uint8) uint32 {
+	return uint32(min(stage, 0xfe))<<24 | color&0xffffff
+}
+
+// fadeColor returns the display color of a grid value, fading decaying cells towards the background
+func fadeColor(value uint32) uint32 {
+	stage := value >> 24
+	color := value & 0xffffff
+	if stage == 0 {
+		return color
+	}
+
+	d := stage + 1
+	r := ((color >> 16) & 0xFF) / d
+	g := ((color >> 8) & 0xFF) / d
+	b := (color & 0xFF) / d
+
+	return (r << 16) | (g << 8) | b
+}
+
 // dimStyleFor returns a dimmed version of the style for the given color
 func dimStyleFor(color uint32, cache map[uint32]lipgloss.Style) lipgloss.Style {
 	dimmedColor := dimColor(color)
@@ -120,14 +144,61 @@ func boolToIcon(b bool) string {
 	return lipgloss.NewStyle().Foreground(errorFg).Render("○")
 }
 
-// runningStatus returns a styled status indicator for running state
-func runningStatus(running bool) string {
+// runningStatus returns a styled status indicator for running state, or the progress
+// of the running fast-forward
+func runningStatus(running bool, fastForward *protocol.Progress) string {
+	if fastForward != nil {
+		return lipgloss.NewStyle().Foreground(successFg).Render(fmt.Sprintf("⏩ Gen %d/%d", fastForward.Generation, fastForward.Target))
+	}
 	if running {
 		return lipgloss.NewStyle().Foreground(successFg).Render("▶ Running")
 	}
 	return lipgloss.NewStyle().Foreground(statusFg).Render("⏸ Paused")
 }
 
+// worldStatus returns a styled status indicator for the period of the world
+func worldStatus(period uint16, cellsCount int) string {
+	style := lipgloss.NewStyle().Foreground(statusFg)
+	switch {
+	case period == 0:
+		return style.Render("∿ Evolving")
+	case cellsCount == 0:
+		return style.Render("∅ Dead")
+	case period == 1:
+		return style.Render("■ Static")
+	}
+	return style.Render(fmt.Sprintf("↻ Period %d", period))
+}
+
+// populationStatus returns the generation, population, births and deaths followed by a
+// graph of the population of the last generations
+func populationStatus(stats []conway.Stats) string {
+	if len(stats) == 0 {
+		return ""
+	}
+	bars := []rune("▁▂▃▄▅▆▇█")
+	highest := uint(1)
+	for _, s := range stats {
+		highest = max(highest, s.Population)
+	}
+	var graph strings.Builder
+	for _, s := range stats {
+		graph.WriteRune(bars[s.Population*uint(len(bars)-1)/highest])
+	}
+	latest := stats[len(stats)-1]
+	return lipgloss.NewStyle().Foreground(statusFg).Render(fmt.Sprintf("Gen %d • Pop %d (+%d −%d) %s",
+		latest.Generation, latest.Population, latest.Births, latest.Deaths, graph.String()))
+}
+
+// speedStatus returns the delay between the generations, 0 running as fast as possible,
+// and the generations per second the engine actually computes
+func speedStatus(speed uint32, rate uint32) string {
+	if speed == 0 {
+		return fmt.Sprintf("max (%d gen/s)", rate)
+	}
+	return fmt.Sprintf("%d ms (%d gen/s)", speed, rate)
+}
+
 // connectedStatus returns a styled status indicator for connection state
 func connectedStatus(connected bool) string {
 	if connected {
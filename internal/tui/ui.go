@@ -14,6 +14,7 @@ import (
 type quitMessage struct{}
 
 type UIModel struct {
+	Room              string // name of the room to join, empty for the default room
 	game              tea.Model
 	foreground        tea.Model
 	overlay           tea.Model
@@ -32,6 +33,7 @@ func (m *UIModel) Init() tea.Cmd {
 		saving:       false,
 		connected:    false,
 		apiHost:      "localhost:8080",
+		room:         m.Room,
 		speed:        atomic.Uint32{},
 		termWidth:    80,
 		currentColor: 0xFFFFFF, // Default to white
@@ -83,8 +85,14 @@ func (m *UIModel) Update(message tea.Msg) (tea.Model, tea.Cmd) {
 			// Always pass escape to game in case it's in pattern placement mode
 			passToGame()
 			return m, tea.Batch(cmds...)
-		case "?", "ctrl+p", "ctrl+n":
+		case "?", "ctrl+p", "ctrl+n", "o":
 			m.foregroundVisible = true
+			// Take a fresh census each time the census panel is opened or refreshed
+			if msg.String() == "o" {
+				if gm, ok := m.game.(*gameModel); ok {
+					cmds = append(cmds, fetchCensus(gm.apiHost, gm.room))
+				}
+			}
 			// Sync current color from game to foreground when opening pattern selector
 			if msg.String() == "ctrl+n" {
 				if gm, ok := m.game.(*gameModel); ok {
@@ -0,0 +1,45 @@
+package api_test
+
+import (
+	"context"
+	"net/http/httptest"
+	"time"
+
+	"github.com/JackWithOneEye/conwaymore/internal/engine"
+	"github.com/JackWithOneEye/conwaymore/internal/protocol"
+	"github.com/JackWithOneEye/conwaymore/internal/server"
+)
+
+func (suite *APITestSuite) TestBroadcastFPS() {
+	const fps = 10
+	cfg := &testConfig{backend: "auto", broadcastFPS: fps, colourInheritance: "channel-mix", historyDepth: 0, maxRooms: 4, maxRoomSize: 1024, port: 8080, rule: "B3/S23", topology: "torus", worldHeight: 1024, worldWidth: 1024}
+	ctx, cancel := context.WithCancel(suite.ctx)
+	defer cancel()
+	manager := engine.NewManager(cfg, ctx)
+	_, err := manager.Create(engine.DefaultRoom, engine.RoomConfig{}, nil)
+	suite.Require().NoError(err)
+	ts := httptest.NewServer(server.NewServer(cfg, suite.db, manager, ctx).Handler)
+	defer ts.Close()
+	c := suite.dialPlay(ts)
+	defer c.close()
+	c.reset(glider(10, 10))
+
+	// as fast as possible
+	c.send(&protocol.SetSpeed{Speed: 0})
+	c.readOutput()
+	c.send(&protocol.Command{Cmd: protocol.Play})
+	c.readOutput()
+
+	frames, rate := 0, uint32(0)
+	deadline := time.Now().Add(1500 * time.Millisecond)
+	for time.Now().Before(deadline) {
+		o := c.readOutput()
+		frames++
+		rate = max(rate, o.GenerationsPerSecond)
+	}
+	c.send(&protocol.Command{Cmd: protocol.Pause})
+
+	// the outputs are capped, the generations are not
+	suite.LessOrEqual(frames, 2*fps)
+	suite.Greater(rate, uint32(fps))
+}
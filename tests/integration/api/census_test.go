@@ -0,0 +1,42 @@
+package api_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+
+	"github.com/JackWithOneEye/conwaymore/internal/conway"
+	"github.com/JackWithOneEye/conwaymore/internal/protocol"
+)
+
+func (suite *APITestSuite) TestCensus() {
+	ts := httptest.NewServer(suite.server.Handler)
+	defer ts.Close()
+	c := suite.dialPlay(ts)
+	defer c.close()
+
+	census := func() conway.Census {
+		w := httptest.NewRecorder()
+		suite.server.Handler.ServeHTTP(w, httptest.NewRequest("GET", "/census", nil))
+		suite.Require().Equal(http.StatusOK, w.Code)
+		var census conway.Census
+		suite.Require().NoError(json.Unmarshal(w.Body.Bytes(), &census))
+		return census
+	}
+
+	c.reset(glider(10, 10))
+	c.send(&protocol.Command{Cmd: protocol.Next})
+	c.readOutput()
+	first := census()
+	suite.Equal(uint64(1), first.Generation)
+	suite.Equal(uint(1), first.Islands)
+	suite.Equal(first, census())
+
+	// an edit changes the world without a new generation
+	block := []protocol.Cell{cell(40, 40, 1), cell(41, 40, 1), cell(40, 41, 1), cell(41, 41, 1)}
+	c.send(&protocol.SetCells{Count: uint16(len(block)), Cells: block})
+	c.readOutput()
+	second := census()
+	suite.Equal(uint64(1), second.Generation)
+	suite.Equal(uint(2), second.Islands)
+}
@@ -0,0 +1,87 @@
+package api_test
+
+import (
+	"net/http/httptest"
+	"net/url"
+	"sort"
+
+	"github.com/JackWithOneEye/conwaymore/internal/protocol"
+	"github.com/gorilla/websocket"
+)
+
+// playClient is a websocket client of /play that reads the world after each message.
+type playClient struct {
+	suite *APITestSuite
+	conn  *websocket.Conn
+}
+
+// dialPlay connects to /play and skips the initial game state.
+func (suite *APITestSuite) dialPlay(ts *httptest.Server) *playClient {
+	u, err := url.Parse(ts.URL)
+	suite.Require().NoError(err)
+	u.Scheme = "ws"
+	u.Path = "/play"
+
+	conn, _, err := websocket.DefaultDialer.Dial(u.String(), nil)
+	suite.Require().NoError(err)
+	_, _, err = conn.ReadMessage()
+	suite.Require().NoError(err)
+	return &playClient{suite, conn}
+}
+
+func (c *playClient) send(msg protocol.ClientMessage) {
+	c.suite.Require().NoError(c.conn.WriteMessage(websocket.BinaryMessage, msg.Encode()))
+}
+
+// readOutput reads the next output.
+func (c *playClient) readOutput() protocol.Output {
+	_, msg, err := c.conn.ReadMessage()
+	c.suite.Require().NoError(err)
+	var output protocol.Output
+	c.suite.Require().NoError(output.Decode(msg))
+	return output
+}
+
+// readCells reads the next output and returns its cells row by row, without their age.
+func (c *playClient) readCells() []protocol.Cell {
+	cells := c.readOutput().Cells
+	for i := range cells {
+		cells[i].Age = 0
+	}
+	sortCells(cells)
+	return cells
+}
+
+// reset clears the world and sets the cells.
+func (c *playClient) reset(cells []protocol.Cell) {
+	c.send(&protocol.Command{Cmd: protocol.Clear})
+	c.readCells()
+	c.send(&protocol.SetCells{Count: uint16(len(cells)), Cells: cells})
+	c.readCells()
+}
+
+// sync reads the world after an edit that changes nothing, as messages that fail send
+// no output.
+func (c *playClient) sync() []protocol.Cell {
+	c.send(&protocol.ClearRegion{X: 500, Y: 500, Width: 1, Height: 1})
+	return c.readCells()
+}
+
+func (c *playClient) close() {
+	c.conn.Close()
+}
+
+func cell(x, y uint16, colour uint32) protocol.Cell {
+	return protocol.Cell{X: x, Y: y, Colour: colour}
+}
+
+// sortCells sorts cells row by row.
+func sortCells(cells []protocol.Cell) {
+	sort.Slice(cells, func(i, j int) bool {
+		a, b := cells[i], cells[j]
+		if a.Y != b.Y {
+			return a.Y < b.Y
+		}
+		return a.X < b.X
+	})
+}
@@ -0,0 +1,20 @@
+package api_test
+
+import (
+	"net/http/httptest"
+
+	"github.com/JackWithOneEye/conwaymore/internal/protocol"
+)
+
+func (suite *APITestSuite) TestKillCells() {
+	ts := httptest.NewServer(suite.server.Handler)
+	defer ts.Close()
+	c := suite.dialPlay(ts)
+	defer c.close()
+
+	c.reset([]protocol.Cell{cell(1, 1, 1), cell(2, 1, 1), cell(9, 9, 1)})
+
+	// the count is derived from the coordinates
+	c.send(&protocol.KillCells{Coords: []protocol.Coord{{X: 1, Y: 1}, {X: 9, Y: 9}, {X: 5, Y: 5}}})
+	suite.Equal([]protocol.Cell{cell(2, 1, 1)}, c.readCells())
+}
@@ -0,0 +1,107 @@
+package api_test
+
+import (
+	"context"
+	"encoding/json"
+	"net/http/httptest"
+	"net/url"
+
+	"github.com/JackWithOneEye/conwaymore/internal/engine"
+	"github.com/JackWithOneEye/conwaymore/internal/protocol"
+	"github.com/gorilla/websocket"
+)
+
+// glider returns a glider moving down and to the right with its top left corner at (x, y).
+func glider(x, y uint16) []protocol.Cell {
+	return []protocol.Cell{cell(x+1, y, 1), cell(x+2, y+1, 1), cell(x, y+2, 1), cell(x+1, y+2, 1), cell(x+2, y+2, 1)}
+}
+
+func (suite *APITestSuite) TestFastForward() {
+	ts := httptest.NewServer(suite.server.Handler)
+	defer ts.Close()
+	c := suite.dialPlay(ts)
+	defer c.close()
+
+	c.reset(glider(10, 10))
+
+	// a glider moves by one cell every 4 generations
+	c.send(&protocol.Command{Cmd: protocol.JumpTo, Arg: 8})
+	suite.Equal(glider(12, 12), c.readCells())
+	c.send(&protocol.Command{Cmd: protocol.StepN, Arg: 400})
+	suite.Equal(glider(112, 112), c.readCells())
+	c.send(&protocol.Command{Cmd: protocol.Previous})
+	suite.Equal(glider(12, 12), c.readCells())
+
+	c.send(&protocol.Command{Cmd: protocol.StepN, Arg: 4})
+	suite.Equal(glider(13, 13), c.readCells())
+
+	// cannot jump back or step zero generations
+	c.send(&protocol.Command{Cmd: protocol.JumpTo, Arg: 8})
+	c.send(&protocol.Command{Cmd: protocol.StepN})
+	suite.Equal(glider(13, 13), c.sync())
+}
+
+func (suite *APITestSuite) TestCancelFastForward() {
+	ts := httptest.NewServer(suite.server.Handler)
+	defer ts.Close()
+	c := suite.dialPlay(ts)
+	defer c.close()
+	c.reset(glider(10, 10))
+
+	u, err := url.Parse(ts.URL)
+	suite.Require().NoError(err)
+	u.Scheme = "ws"
+	u.Path = "/play"
+	u.RawQuery = "stats=1"
+	listener, _, err := websocket.DefaultDialer.Dial(u.String(), nil)
+	suite.Require().NoError(err)
+	defer listener.Close()
+
+	// progress reports are text messages next to the stats
+	readProgress := func() protocol.Progress {
+		for {
+			typ, msg, err := listener.ReadMessage()
+			suite.Require().NoError(err)
+			if typ != websocket.TextMessage {
+				continue
+			}
+			var pm protocol.ProgressMessage
+			suite.Require().NoError(json.Unmarshal(msg, &pm))
+			if pm.FastForward != nil {
+				return *pm.FastForward
+			}
+		}
+	}
+
+	const target = 1 << 40
+	c.send(&protocol.Command{Cmd: protocol.StepN, Arg: target})
+	p := readProgress()
+	suite.False(p.Done)
+	suite.Equal(uint64(target), p.Target)
+
+	// the world cannot be changed meanwhile
+	c.send(&protocol.Command{Cmd: protocol.Clear})
+	c.send(&protocol.Command{Cmd: protocol.CancelFastForward})
+	for !p.Done {
+		p = readProgress()
+	}
+	suite.Less(p.Generation, uint64(target))
+
+	cells := c.readCells()
+	suite.Len(cells, 5)
+}
+
+func (suite *APITestSuite) TestEditWhileFastForwarding() {
+	cfg := &testConfig{backend: "auto", colourInheritance: "channel-mix", historyDepth: 100, maxRooms: 4, maxRoomSize: 1024, rule: "B3/S23", topology: "torus", worldHeight: 64, worldWidth: 64}
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	room, err := engine.NewManager(cfg, ctx).Create(engine.DefaultRoom, engine.RoomConfig{}, nil)
+	suite.Require().NoError(err)
+	s := engine.NewSession()
+
+	suite.Require().NoError(room.Engine.SubmitMessage(s, (&protocol.Command{Cmd: protocol.StepN, Arg: 1 << 40}).Encode()))
+	err = room.Engine.SubmitMessage(s, (&protocol.Command{Cmd: protocol.Clear}).Encode())
+	suite.ErrorIs(err, engine.ErrFastForwarding)
+	suite.Equal("handle command error: cannot change the world while fast-forwarding", err.Error())
+	suite.NoError(room.Engine.SubmitMessage(s, (&protocol.Command{Cmd: protocol.CancelFastForward}).Encode()))
+}
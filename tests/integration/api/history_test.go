@@ -0,0 +1,35 @@
+package api_test
+
+import (
+	"net/http/httptest"
+
+	"github.com/JackWithOneEye/conwaymore/internal/protocol"
+)
+
+func (suite *APITestSuite) TestPrevious() {
+	ts := httptest.NewServer(suite.server.Handler)
+	defer ts.Close()
+	c := suite.dialPlay(ts)
+	defer c.close()
+
+	// the R-pentomino changes for a long time
+	rPentomino := []protocol.Cell{cell(101, 100, 1), cell(102, 100, 2), cell(100, 101, 3), cell(101, 101, 4), cell(101, 102, 5)}
+	c.reset(rPentomino)
+	worlds := [][]protocol.Cell{c.sync()}
+	for range 30 {
+		c.send(&protocol.Command{Cmd: protocol.Next})
+		worlds = append(worlds, c.readCells())
+	}
+	c.send(&protocol.Command{Cmd: protocol.StepN, Arg: 20})
+	c.readCells()
+
+	c.send(&protocol.Command{Cmd: protocol.Previous})
+	suite.Equal(worlds[30], c.readCells())
+	for i := 29; i >= 0; i-- {
+		c.send(&protocol.Command{Cmd: protocol.Previous})
+		suite.Require().Equal(worlds[i], c.readCells(), "generation %d", i)
+	}
+	// the history starts with the edit
+	c.send(&protocol.Command{Cmd: protocol.Previous})
+	suite.Equal(worlds[0], c.sync())
+}
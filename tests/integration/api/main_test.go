@@ -43,12 +43,14 @@ func (suite *APITestSuite) SetupTest() {
 	dbFile := tmpFile.Name()
 	tmpFile.Close()
 
-	cfg := &testConfig{port: 8080, worldSize: 1024}
+	cfg := &testConfig{backend: "auto", colourInheritance: "channel-mix", historyDepth: 100, maxRooms: 4, maxRoomSize: 1024, port: 8080, rule: "B3/S23", topology: "torus", worldHeight: 1024, worldWidth: 1024}
 	dbCfg := &testDatabaseConfig{dbUrl: dbFile}
 	db := database.NewDatabaseService(dbCfg)
 	ctx, cancel := context.WithCancel(context.Background())
-	eng := engine.NewEngine(cfg, nil, ctx)
-	suite.server = server.NewServer(cfg, db, eng, ctx)
+	manager := engine.NewManager(cfg, ctx)
+	_, err = manager.Create(engine.DefaultRoom, engine.RoomConfig{}, nil)
+	suite.Require().NoError(err)
+	suite.server = server.NewServer(cfg, db, manager, ctx)
 	suite.db = db
 	suite.dbFile = dbFile
 	suite.ctx = ctx
@@ -277,9 +279,29 @@ func TestAPISuite(t *testing.T) {
 }
 
 type testConfig struct {
-	port      uint
-	worldSize uint
+	autoPause         bool
+	backend           string
+	broadcastFPS      uint
+	colourInheritance string
+	historyDepth      uint
+	maxRooms          uint
+	maxRoomSize       uint
+	port              uint
+	rule              string
+	topology          string
+	worldHeight       uint
+	worldWidth        uint
 }
 
-func (c *testConfig) Port() uint      { return c.port }
-func (c *testConfig) WorldSize() uint { return c.worldSize }
+func (c *testConfig) AutoPause() bool           { return c.autoPause }
+func (c *testConfig) Backend() string           { return c.backend }
+func (c *testConfig) BroadcastFPS() uint        { return c.broadcastFPS }
+func (c *testConfig) ColourInheritance() string { return c.colourInheritance }
+func (c *testConfig) HistoryDepth() uint        { return c.historyDepth }
+func (c *testConfig) MaxRooms() uint            { return c.maxRooms }
+func (c *testConfig) MaxRoomSize() uint         { return c.maxRoomSize }
+func (c *testConfig) Port() uint                { return c.port }
+func (c *testConfig) Rule() string              { return c.rule }
+func (c *testConfig) Topology() string          { return c.topology }
+func (c *testConfig) WorldHeight() uint         { return c.worldHeight }
+func (c *testConfig) WorldWidth() uint          { return c.worldWidth }
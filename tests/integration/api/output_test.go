@@ -0,0 +1,139 @@
+package api_test
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+
+	"github.com/JackWithOneEye/conwaymore/internal/conway"
+	"github.com/JackWithOneEye/conwaymore/internal/engine"
+	"github.com/JackWithOneEye/conwaymore/internal/protocol"
+)
+
+func (suite *APITestSuite) TestOutputHeader() {
+	ts := httptest.NewServer(suite.server.Handler)
+	defer ts.Close()
+	c := suite.dialPlay(ts)
+	defer c.close()
+	c.reset(glider(10, 10))
+
+	c.send(&protocol.Command{Cmd: protocol.JumpTo, Arg: 12})
+	o := c.readOutput()
+	suite.Equal(uint8(protocol.OutputVersion), o.Version)
+	suite.Equal(uint64(12), o.Generation)
+	suite.Equal("B3/S23", o.Rule)
+	suite.Equal(uint32(1024), o.WorldWidth)
+	suite.Equal(uint32(1024), o.WorldHeight)
+
+	c.send(&protocol.Command{Cmd: protocol.Next})
+	next := c.readOutput()
+	suite.Equal(uint64(13), next.Generation)
+	suite.Equal(o.Sequence+1, next.Sequence)
+
+	// the saved seed says which world it came from
+	w := httptest.NewRecorder()
+	suite.server.Handler.ServeHTTP(w, httptest.NewRequest("POST", "/save", nil))
+	suite.Equal(http.StatusOK, w.Code)
+	seed, err := suite.db.GetSeed()
+	suite.Require().NoError(err)
+	var saved protocol.Output
+	suite.Require().NoError(saved.Decode(seed))
+	suite.Equal(uint64(13), saved.Generation)
+	suite.Equal("B3/S23", saved.Rule)
+	suite.Len(saved.Cells, 5)
+}
+
+func (suite *APITestSuite) TestDecodeLegacySeeds() {
+	tests := []struct {
+		name     string
+		seed     []byte
+		expected protocol.Output
+	}{
+		{
+			name: "without cell states",
+			seed: []byte{0x01, 0x00, 0x64, 0x00, 0x00, 0x01, 0x00, 0x02, 0x00, 0x03, 0xff, 0x00, 0x00, 0x00, 0x05},
+			expected: protocol.Output{
+				Cells:      []protocol.Cell{{X: 2, Y: 3, Colour: 0xff0000, Age: 5}},
+				CellsCount: 1,
+				Playing:    true,
+				Speed:      100,
+			},
+		},
+		{
+			name: "with topology and period",
+			seed: []byte{0x02 | 0x04 | 0x40 | 2<<3, 0x00, 0x0a, 0x00, 0x00, 0x01, 0x00, 0x04, 0x00, 0x02, 0x00, 0x03, 0x00, 0xff, 0x00, 0x00, 0x05, 0x01},
+			expected: protocol.Output{
+				Cells:       []protocol.Cell{{X: 2, Y: 3, Colour: 0xff00, Age: 5, State: 1}},
+				CellsCount:  1,
+				Speed:       10,
+				Topology:    2,
+				HasTopology: true,
+				Period:      4,
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		suite.Run(tt.name, func() {
+			var o protocol.Output
+			suite.Require().NoError(o.Decode(tt.seed))
+			suite.Equal(tt.expected, o)
+		})
+	}
+}
+
+func (suite *APITestSuite) TestRestoreSeedTopology() {
+	cfg := &testConfig{backend: "auto", colourInheritance: "channel-mix", historyDepth: 100, maxRooms: 4, maxRoomSize: 1024, rule: "B3/S23", topology: "torus", worldHeight: 64, worldWidth: 64}
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	manager := engine.NewManager(cfg, ctx)
+
+	tests := []struct {
+		name     string
+		seed     []byte
+		expected conway.Topology
+	}{
+		{
+			name:     "legacy seed keeps the configured topology",
+			seed:     []byte{0x00, 0x00, 0x64, 0x00, 0x00, 0x01, 0x00, 0x02, 0x00, 0x03, 0xff, 0x00, 0x00, 0x00, 0x05},
+			expected: conway.Plane,
+		},
+		{
+			name:     "seed with topology restores it",
+			seed:     []byte{0x02 | 0x04 | 2<<3, 0x00, 0x64, 0x00, 0x00, 0x01, 0x00, 0x02, 0x00, 0x03, 0x00, 0xff, 0x00, 0x00, 0x05, 0x01},
+			expected: conway.Topology(2),
+		},
+	}
+
+	for i, tt := range tests {
+		suite.Run(tt.name, func() {
+			room, err := manager.Create(fmt.Sprintf("seed-%d", i), engine.RoomConfig{Topology: "plane"}, tt.seed)
+			suite.Require().NoError(err)
+			// a no-op to get an output
+			suite.Require().NoError(room.Engine.SubmitMessage(engine.NewSession(), (&protocol.ClearRegion{X: 60, Y: 60, Width: 1, Height: 1}).Encode()))
+			var o protocol.Output
+			suite.Require().NoError(o.Decode((<-room.Engine.Output()).Output))
+			suite.Equal(tt.expected, conway.Topology(o.Topology))
+		})
+	}
+}
+
+func (suite *APITestSuite) TestOutputOrder() {
+	tests := []struct {
+		sequence, last uint32
+		after          bool
+	}{
+		{2, 1, true},
+		{1, 2, false},
+		{5, 5, false},
+		// the sequence numbers wrap around
+		{0, 1<<32 - 1, true},
+		{3, 1<<32 - 2, true},
+		{1<<32 - 2, 3, false},
+	}
+	for _, tt := range tests {
+		o := protocol.Output{Sequence: tt.sequence}
+		suite.Equal(tt.after, o.After(tt.last), "%d after %d", tt.sequence, tt.last)
+	}
+}
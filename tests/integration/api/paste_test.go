@@ -0,0 +1,91 @@
+package api_test
+
+import (
+	"net/http/httptest"
+	"slices"
+
+	"github.com/JackWithOneEye/conwaymore/internal/protocol"
+)
+
+func (suite *APITestSuite) TestPasteModes() {
+	ts := httptest.NewServer(suite.server.Handler)
+	defer ts.Close()
+	c := suite.dialPlay(ts)
+	defer c.close()
+
+	world := []protocol.Cell{cell(1, 1, 1), cell(2, 1, 1), cell(2, 2, 1), cell(9, 9, 1), cell(1023, 0, 1)}
+	pattern := []protocol.Cell{cell(1, 1, 2), cell(3, 2, 2)}
+	// bounding box from (1022, 1023) to (1, 1) across both edges of the world
+	wrapped := []protocol.Cell{cell(1022, 1023, 3), cell(1, 1, 3)}
+
+	tests := []struct {
+		name     string
+		mode     protocol.PasteMode
+		pattern  []protocol.Cell
+		rejected bool
+		expected []protocol.Cell
+	}{
+		{
+			name:     "reject free cells",
+			mode:     protocol.PasteReject,
+			pattern:  []protocol.Cell{cell(5, 5, 2)},
+			expected: []protocol.Cell{cell(1, 1, 1), cell(2, 1, 1), cell(2, 2, 1), cell(5, 5, 2), cell(9, 9, 1), cell(1023, 0, 1)},
+		},
+		{
+			name:     "reject occupied cells",
+			mode:     protocol.PasteReject,
+			pattern:  pattern,
+			rejected: true,
+			expected: world,
+		},
+		{
+			name:     "or",
+			mode:     protocol.PasteOr,
+			pattern:  pattern,
+			expected: []protocol.Cell{cell(1, 1, 1), cell(2, 1, 1), cell(2, 2, 1), cell(3, 2, 2), cell(9, 9, 1), cell(1023, 0, 1)},
+		},
+		{
+			name:     "overwrite",
+			mode:     protocol.PasteOverwrite,
+			pattern:  pattern,
+			expected: []protocol.Cell{cell(1, 1, 2), cell(3, 2, 2), cell(9, 9, 1), cell(1023, 0, 1)},
+		},
+		{
+			name:     "xor",
+			mode:     protocol.PasteXor,
+			pattern:  pattern,
+			expected: []protocol.Cell{cell(2, 1, 1), cell(2, 2, 1), cell(3, 2, 2), cell(9, 9, 1), cell(1023, 0, 1)},
+		},
+		{
+			name:     "and",
+			mode:     protocol.PasteAnd,
+			pattern:  pattern,
+			expected: []protocol.Cell{cell(1, 1, 1), cell(9, 9, 1), cell(1023, 0, 1)},
+		},
+		{
+			name:     "overwrite across the edges",
+			mode:     protocol.PasteOverwrite,
+			pattern:  wrapped,
+			expected: []protocol.Cell{cell(1, 1, 3), cell(2, 1, 1), cell(2, 2, 1), cell(9, 9, 1), cell(1022, 1023, 3)},
+		},
+		{
+			name:     "and across the edges",
+			mode:     protocol.PasteAnd,
+			pattern:  wrapped,
+			expected: []protocol.Cell{cell(1, 1, 1), cell(2, 1, 1), cell(2, 2, 1), cell(9, 9, 1)},
+		},
+	}
+
+	for _, tt := range tests {
+		suite.Run(tt.name, func() {
+			c.reset(world)
+			c.send(&protocol.SetCells{Count: uint16(len(tt.pattern)), Cells: tt.pattern, Mode: tt.mode})
+			if !tt.rejected {
+				c.readCells()
+			}
+			expected := slices.Clone(tt.expected)
+			sortCells(expected)
+			suite.Equal(expected, c.sync())
+		})
+	}
+}
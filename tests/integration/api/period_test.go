@@ -0,0 +1,128 @@
+package api_test
+
+import (
+	"context"
+	"net/http/httptest"
+	"time"
+
+	"github.com/JackWithOneEye/conwaymore/internal/engine"
+	"github.com/JackWithOneEye/conwaymore/internal/protocol"
+	"github.com/JackWithOneEye/conwaymore/internal/server"
+)
+
+// blinkers returns n blinkers in rows of 40.
+func blinkers(n int) []protocol.Cell {
+	var cells []protocol.Cell
+	for i := range n {
+		x, y := uint16(i%40*5), uint16(i/40*5)
+		cells = append(cells, cell(x, y+1, 1), cell(x+1, y+1, 1), cell(x+2, y+1, 1))
+	}
+	return cells
+}
+
+func (suite *APITestSuite) TestPeriod() {
+	ts := httptest.NewServer(suite.server.Handler)
+	defer ts.Close()
+	c := suite.dialPlay(ts)
+	defer c.close()
+
+	tests := []struct {
+		name     string
+		cells    []protocol.Cell
+		steps    int // until the period is known
+		expected uint16
+	}{
+		{name: "dead", steps: 1, expected: 1},
+		{name: "block", cells: []protocol.Cell{cell(1, 1, 1), cell(2, 1, 1), cell(1, 2, 1), cell(2, 2, 1)}, steps: 1, expected: 1},
+		{name: "blinker", cells: blinkers(1), steps: 2, expected: 2},
+		// hashed every other generation, then each generation once a sample repeated
+		{name: "many blinkers", cells: blinkers(2000), steps: 4, expected: 2},
+		{name: "glider", cells: glider(10, 10), steps: 300, expected: 0},
+	}
+
+	for _, tt := range tests {
+		suite.Run(tt.name, func() {
+			c.send(&protocol.Command{Cmd: protocol.Clear})
+			c.readOutput()
+			for cells := tt.cells; len(cells) > 0; {
+				n := min(len(cells), 1000)
+				c.send(&protocol.SetCells{Count: uint16(n), Cells: cells[:n]})
+				c.readOutput()
+				cells = cells[n:]
+			}
+
+			for range tt.steps - 1 {
+				c.send(&protocol.Command{Cmd: protocol.Next})
+				suite.Zero(c.readOutput().Period)
+			}
+			c.send(&protocol.Command{Cmd: protocol.Next})
+			suite.Equal(tt.expected, c.readOutput().Period)
+		})
+	}
+}
+
+func (suite *APITestSuite) TestStaticWorldOutputs() {
+	ts := httptest.NewServer(suite.server.Handler)
+	defer ts.Close()
+	c := suite.dialPlay(ts)
+	defer c.close()
+	c.reset([]protocol.Cell{cell(1, 1, 1), cell(2, 1, 1), cell(1, 2, 1), cell(2, 2, 1)})
+
+	c.send(&protocol.SetSpeed{Speed: 1})
+	c.readOutput()
+	c.send(&protocol.Command{Cmd: protocol.Play})
+	c.readOutput()
+
+	// the block is stepped every millisecond, but only sent once a second
+	frames := 0
+	suite.Require().NoError(c.conn.SetReadDeadline(time.Now().Add(1500 * time.Millisecond)))
+	for {
+		if _, _, err := c.conn.ReadMessage(); err != nil {
+			break
+		}
+		frames++
+	}
+	suite.LessOrEqual(frames, 3)
+}
+
+func (suite *APITestSuite) TestAutoPause() {
+	cfg := &testConfig{autoPause: true, backend: "auto", colourInheritance: "channel-mix", historyDepth: 100, maxRooms: 4, maxRoomSize: 1024, port: 8080, rule: "B3/S23", topology: "torus", worldHeight: 1024, worldWidth: 1024}
+	ctx, cancel := context.WithCancel(suite.ctx)
+	defer cancel()
+	manager := engine.NewManager(cfg, ctx)
+	_, err := manager.Create(engine.DefaultRoom, engine.RoomConfig{}, nil)
+	suite.Require().NoError(err)
+	ts := httptest.NewServer(server.NewServer(cfg, suite.db, manager, ctx).Handler)
+	defer ts.Close()
+	c := suite.dialPlay(ts)
+	defer c.close()
+	c.reset(blinkers(1))
+	c.send(&protocol.SetSpeed{Speed: 1})
+	c.readOutput()
+
+	// reads the outputs until the world is paused and returns how many were playing
+	untilPaused := func() int {
+		n := 0
+		for c.readOutput().Playing {
+			n++
+		}
+		return n
+	}
+
+	c.send(&protocol.Command{Cmd: protocol.Play})
+	suite.Less(untilPaused(), 5)
+
+	// played again, the blinker keeps blinking
+	c.send(&protocol.Command{Cmd: protocol.Play})
+	for range 50 {
+		suite.Require().True(c.readOutput().Playing)
+	}
+	c.send(&protocol.Command{Cmd: protocol.Pause})
+	untilPaused()
+
+	// but pauses again once it was changed
+	c.send(&protocol.SetCells{Count: 1, Cells: []protocol.Cell{cell(100, 100, 1)}})
+	c.readOutput()
+	c.send(&protocol.Command{Cmd: protocol.Play})
+	suite.Less(untilPaused(), 5)
+}
@@ -0,0 +1,94 @@
+package api_test
+
+import (
+	"net/http/httptest"
+	"slices"
+
+	"github.com/JackWithOneEye/conwaymore/internal/protocol"
+)
+
+func (suite *APITestSuite) TestRegionOperations() {
+	ts := httptest.NewServer(suite.server.Handler)
+	defer ts.Close()
+	c := suite.dialPlay(ts)
+	defer c.close()
+
+	// an L of 3x2 cells at (10, 10) and a cell outside of it
+	world := []protocol.Cell{cell(10, 10, 1), cell(11, 10, 1), cell(12, 10, 1), cell(10, 11, 1), cell(20, 20, 1)}
+	edit := func(op protocol.RegionOp) *protocol.EditRegion {
+		return &protocol.EditRegion{Op: op, X: 10, Y: 10, Width: 3, Height: 2}
+	}
+
+	suite.Run("paste an empty clipboard", func() {
+		c.reset(world)
+		c.send(&protocol.PasteClipboard{X: 0, Y: 0})
+		suite.Equal(world, c.sync())
+	})
+
+	tests := []struct {
+		name     string
+		msgs     []protocol.ClientMessage
+		expected []protocol.Cell
+	}{
+		{
+			name:     "copy and paste",
+			msgs:     []protocol.ClientMessage{edit(protocol.RegionCopy), &protocol.PasteClipboard{X: 30, Y: 30}},
+			expected: append(slices.Clone(world), cell(30, 30, 1), cell(31, 30, 1), cell(32, 30, 1), cell(30, 31, 1)),
+		},
+		{
+			name:     "cut and paste",
+			msgs:     []protocol.ClientMessage{edit(protocol.RegionCut), &protocol.PasteClipboard{X: 0, Y: 0}},
+			expected: []protocol.Cell{cell(0, 0, 1), cell(1, 0, 1), cell(2, 0, 1), cell(0, 1, 1), cell(20, 20, 1)},
+		},
+		{
+			name: "move across the edges",
+			msgs: []protocol.ClientMessage{&protocol.EditRegion{Op: protocol.RegionMove, X: 10, Y: 10, Width: 3, Height: 2, DX: -11, DY: -11}},
+			expected: []protocol.Cell{
+				cell(1023, 1023, 1), cell(0, 1023, 1), cell(1, 1023, 1), cell(1023, 0, 1), cell(20, 20, 1),
+			},
+		},
+		{
+			name:     "rotate clockwise",
+			msgs:     []protocol.ClientMessage{edit(protocol.RegionRotateCW)},
+			expected: []protocol.Cell{cell(10, 10, 1), cell(11, 10, 1), cell(11, 11, 1), cell(11, 12, 1), cell(20, 20, 1)},
+		},
+		{
+			name:     "rotate anticlockwise",
+			msgs:     []protocol.ClientMessage{edit(protocol.RegionRotateCCW)},
+			expected: []protocol.Cell{cell(10, 10, 1), cell(10, 11, 1), cell(10, 12, 1), cell(11, 12, 1), cell(20, 20, 1)},
+		},
+		{
+			name:     "rotate by 180°",
+			msgs:     []protocol.ClientMessage{edit(protocol.RegionRotate180)},
+			expected: []protocol.Cell{cell(12, 10, 1), cell(10, 11, 1), cell(11, 11, 1), cell(12, 11, 1), cell(20, 20, 1)},
+		},
+		{
+			name:     "flip horizontally",
+			msgs:     []protocol.ClientMessage{edit(protocol.RegionFlipHorizontal)},
+			expected: []protocol.Cell{cell(10, 10, 1), cell(11, 10, 1), cell(12, 10, 1), cell(12, 11, 1), cell(20, 20, 1)},
+		},
+		{
+			name:     "flip vertically",
+			msgs:     []protocol.ClientMessage{edit(protocol.RegionFlipVertical)},
+			expected: []protocol.Cell{cell(10, 10, 1), cell(10, 11, 1), cell(11, 11, 1), cell(12, 11, 1), cell(20, 20, 1)},
+		},
+		{
+			name:     "recolour",
+			msgs:     []protocol.ClientMessage{&protocol.EditRegion{Op: protocol.RegionRecolour, X: 10, Y: 10, Width: 3, Height: 2, Colour: 5}},
+			expected: []protocol.Cell{cell(10, 10, 5), cell(11, 10, 5), cell(12, 10, 5), cell(10, 11, 5), cell(20, 20, 1)},
+		},
+	}
+
+	for _, tt := range tests {
+		suite.Run(tt.name, func() {
+			c.reset(world)
+			for _, msg := range tt.msgs {
+				c.send(msg)
+				c.readCells()
+			}
+			expected := slices.Clone(tt.expected)
+			sortCells(expected)
+			suite.Equal(expected, c.sync())
+		})
+	}
+}
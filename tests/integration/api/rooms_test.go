@@ -0,0 +1,127 @@
+package api_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/JackWithOneEye/conwaymore/internal/protocol"
+	"github.com/gorilla/websocket"
+)
+
+func (suite *APITestSuite) TestRooms() {
+	ts := httptest.NewServer(suite.server.Handler)
+	defer ts.Close()
+
+	request := func(method, path, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, path, strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		w := httptest.NewRecorder()
+		suite.server.Handler.ServeHTTP(w, req)
+		return w
+	}
+
+	suite.Run("create", func() {
+		w := request("POST", "/rooms", `{"name":"lab","rule":"B36/S23","width":64,"height":32}`)
+		suite.Equal(http.StatusCreated, w.Code, w.Body.String())
+
+		suite.Equal(http.StatusBadRequest, request("POST", "/rooms", `{"name":"lab"}`).Code, "duplicate name")
+		suite.Equal(http.StatusBadRequest, request("POST", "/rooms", `{"name":"Bad Name"}`).Code, "invalid name")
+		suite.Equal(http.StatusBadRequest, request("POST", "/rooms", `{"name":"x","rule":"nope"}`).Code, "invalid rule")
+		// a valid rule table on the server
+		ruleFile := filepath.Join(suite.T().TempDir(), "Spread.rule")
+		suite.Require().NoError(os.WriteFile(ruleFile, []byte("@RULE Spread\n@TABLE\nn_states:2\nneighborhood:vonNeumann\n0,1,0,0,0,1\n"), 0o600))
+		body, err := json.Marshal(map[string]string{"name": "x", "rule": ruleFile})
+		suite.Require().NoError(err)
+		suite.Equal(http.StatusBadRequest, request("POST", "/rooms", string(body)).Code, "rule file")
+		suite.Equal(http.StatusBadRequest, request("POST", "/rooms", `{"name":"x","width":65535,"height":65535}`).Code, "too large")
+		suite.Equal(http.StatusCreated, request("POST", "/rooms", `{"name":"wires","rule":"WireWorld","width":1024}`).Code, "built-in rule table")
+	})
+
+	suite.Run("list", func() {
+		w := request("GET", "/rooms", "")
+		suite.Equal(http.StatusOK, w.Code)
+		var rooms []map[string]any
+		suite.Require().NoError(json.Unmarshal(w.Body.Bytes(), &rooms))
+		suite.Require().Len(rooms, 3)
+		suite.Equal("default", rooms[0]["name"])
+		suite.Equal("lab", rooms[1]["name"])
+		suite.Equal("B36/S23", rooms[1]["rule"])
+		suite.Equal("wires", rooms[2]["name"])
+
+		stored, err := suite.db.GetRooms()
+		suite.Require().NoError(err)
+		suite.Require().Len(stored, 2)
+		suite.Equal("lab", stored[0].Name)
+	})
+
+	suite.Run("limit", func() {
+		suite.Equal(http.StatusCreated, request("POST", "/rooms", `{"name":"third","width":16,"height":16}`).Code)
+		suite.Equal(http.StatusCreated, request("POST", "/rooms", `{"name":"fourth","width":16,"height":16}`).Code)
+		suite.Equal(http.StatusBadRequest, request("POST", "/rooms", `{"name":"fifth","width":16,"height":16}`).Code)
+		suite.Equal(http.StatusNoContent, request("DELETE", "/rooms/third", "").Code)
+		suite.Equal(http.StatusNoContent, request("DELETE", "/rooms/fourth", "").Code)
+		suite.Equal(http.StatusNoContent, request("DELETE", "/rooms/wires", "").Code)
+	})
+
+	suite.Run("globals", func() {
+		w := request("GET", "/globals?room=lab", "")
+		suite.Equal(http.StatusOK, w.Code)
+		suite.Contains(w.Body.String(), `"WorldWidth":64`)
+		suite.Contains(w.Body.String(), `"Room":"lab"`)
+
+		suite.Equal(http.StatusNotFound, request("GET", "/globals?room=nope", "").Code)
+	})
+
+	suite.Run("play in separate worlds", func() {
+		lab := suite.dialRoom(ts, "lab")
+		defer lab.close()
+		main := suite.dialPlay(ts)
+		defer main.close()
+
+		lab.send(&protocol.SetCells{Count: 1, Cells: []protocol.Cell{cell(63, 31, 1)}})
+		suite.Equal([]protocol.Cell{cell(63, 31, 1)}, lab.readCells())
+		suite.Empty(main.sync())
+
+		w := request("POST", "/save?room=lab", "")
+		suite.Equal(http.StatusOK, w.Code)
+		stored, err := suite.db.GetRooms()
+		suite.Require().NoError(err)
+		suite.NotEmpty(stored[0].Seed)
+	})
+
+	suite.Run("delete", func() {
+		lab := suite.dialRoom(ts, "lab")
+		defer lab.close()
+
+		suite.Equal(http.StatusBadRequest, request("DELETE", "/rooms/default", "").Code)
+		suite.Equal(http.StatusNoContent, request("DELETE", "/rooms/lab", "").Code)
+		suite.Equal(http.StatusNotFound, request("DELETE", "/rooms/lab", "").Code)
+
+		// the clients of the room are disconnected
+		_, _, err := lab.conn.ReadMessage()
+		suite.Error(err)
+
+		stored, err := suite.db.GetRooms()
+		suite.Require().NoError(err)
+		suite.Empty(stored)
+	})
+}
+
+// dialRoom connects to /play/:room and skips the initial game state.
+func (suite *APITestSuite) dialRoom(ts *httptest.Server, room string) *playClient {
+	u, err := url.Parse(ts.URL)
+	suite.Require().NoError(err)
+	u.Scheme = "ws"
+	u.Path = "/play/" + room
+
+	conn, _, err := websocket.DefaultDialer.Dial(u.String(), nil)
+	suite.Require().NoError(err)
+	_, _, err = conn.ReadMessage()
+	suite.Require().NoError(err)
+	return &playClient{suite, conn}
+}
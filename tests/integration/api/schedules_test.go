@@ -0,0 +1,99 @@
+package api_test
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+
+	"github.com/JackWithOneEye/conwaymore/internal/engine"
+	"github.com/JackWithOneEye/conwaymore/internal/protocol"
+)
+
+// libraryGlider returns the glider of the pattern library with its top left corner at
+// (x, y), coloured 1.
+func libraryGlider(x, y uint16) []protocol.Cell {
+	return []protocol.Cell{cell(x+2, y, 1), cell(x, y+1, 1), cell(x+2, y+1, 1), cell(x+1, y+2, 1), cell(x+2, y+2, 1)}
+}
+
+func (suite *APITestSuite) TestSchedules() {
+	ts := httptest.NewServer(suite.server.Handler)
+	defer ts.Close()
+
+	request := func(method, path, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, path, strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		w := httptest.NewRecorder()
+		suite.server.Handler.ServeHTTP(w, req)
+		return w
+	}
+
+	var created engine.Schedule
+	suite.Run("create", func() {
+		w := request("POST", "/schedules", `{"at_generation":4,"action":{"type":"pattern","pattern":"glider","x":100,"y":100,"colour":1}}`)
+		suite.Require().Equal(http.StatusCreated, w.Code, w.Body.String())
+		suite.Require().NoError(json.Unmarshal(w.Body.Bytes(), &created))
+		suite.NotZero(created.ID)
+
+		invalid := []string{
+			`{"action":{"type":"clear"}}`,
+			`{"at_generation":4,"every_generations":10,"action":{"type":"clear"}}`,
+			`{"daily_at":"25:00","action":{"type":"clear"}}`,
+			`{"at_generation":4,"action":{"type":"nope"}}`,
+			`{"at_generation":4,"action":{"type":"pattern","pattern":"nope"}}`,
+			`{"at_generation":4,"action":{"type":"soup","x":5000}}`,
+		}
+		for _, body := range invalid {
+			suite.Equal(http.StatusBadRequest, request("POST", "/schedules", body).Code, body)
+		}
+		suite.Equal(http.StatusNotFound, request("POST", "/schedules?room=nope", `{"at_generation":4,"action":{"type":"clear"}}`).Code)
+	})
+
+	suite.Run("list", func() {
+		w := request("GET", "/schedules", "")
+		suite.Equal(http.StatusOK, w.Code)
+		var schedules []engine.Schedule
+		suite.Require().NoError(json.Unmarshal(w.Body.Bytes(), &schedules))
+		suite.Equal([]engine.Schedule{created}, schedules)
+
+		stored, err := suite.db.GetSchedules()
+		suite.Require().NoError(err)
+		suite.Require().Len(stored, 1)
+		suite.Equal("default", stored[0].Room)
+
+		suite.Contains(request("GET", "/game", "").Body.String(), "at gen 4: place glider at (100, 100)")
+	})
+
+	suite.Run("run when stepping", func() {
+		c := suite.dialPlay(ts)
+		defer c.close()
+		c.reset(nil)
+		for range 3 {
+			c.send(&protocol.Command{Cmd: protocol.Next})
+			suite.Empty(c.readCells())
+		}
+		c.send(&protocol.Command{Cmd: protocol.Next})
+		suite.Equal(libraryGlider(100, 100), c.readCells())
+	})
+
+	suite.Run("run when jumping over the generation", func() {
+		c := suite.dialPlay(ts)
+		defer c.close()
+		c.reset(nil)
+		// placed at generation 4, then moved by one cell in 4 generations
+		c.send(&protocol.Command{Cmd: protocol.StepN, Arg: 8})
+		suite.Equal(libraryGlider(101, 101), c.readCells())
+	})
+
+	suite.Run("delete", func() {
+		path := fmt.Sprintf("/schedules/%d", created.ID)
+		suite.Equal(http.StatusNoContent, request("DELETE", path, "").Code)
+		suite.Equal(http.StatusNotFound, request("DELETE", path, "").Code)
+		suite.Equal("[]", request("GET", "/schedules", "").Body.String())
+
+		stored, err := suite.db.GetSchedules()
+		suite.Require().NoError(err)
+		suite.Empty(stored)
+	})
+}
@@ -0,0 +1,86 @@
+package api_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"time"
+
+	"github.com/JackWithOneEye/conwaymore/internal/conway"
+	"github.com/JackWithOneEye/conwaymore/internal/protocol"
+	"github.com/gorilla/websocket"
+)
+
+func (suite *APITestSuite) TestStats() {
+	ts := httptest.NewServer(suite.server.Handler)
+	defer ts.Close()
+	c := suite.dialPlay(ts)
+	defer c.close()
+
+	c.reset(glider(10, 10))
+	for range 4 {
+		c.send(&protocol.Command{Cmd: protocol.Next})
+		c.readOutput()
+	}
+
+	w := httptest.NewRecorder()
+	suite.server.Handler.ServeHTTP(w, httptest.NewRequest("GET", "/stats", nil))
+	suite.Require().Equal(http.StatusOK, w.Code)
+	var stats []conway.Stats
+	suite.Require().NoError(json.Unmarshal(w.Body.Bytes(), &stats))
+	suite.Require().Len(stats, 5)
+
+	// the counts are kept for each generation
+	for i, s := range stats {
+		suite.Equal(uint64(i), s.Generation)
+		suite.Equal(uint(5), s.Population)
+	}
+	suite.Equal(uint(2), stats[1].Births)
+	suite.Equal(uint(2), stats[1].Deaths)
+
+	// the rest only for the current one
+	suite.Empty(stats[3].Colours)
+	latest := stats[4]
+	suite.Equal(conway.BoundingBox{X: 11, Y: 11, Width: 3, Height: 3}, latest.BoundingBox)
+	suite.Equal([]conway.ColourCount{{Colour: 1, Count: 5}}, latest.Colours)
+	suite.Equal(uint(5), latest.Ages[0]+latest.Ages[1]+latest.Ages[2]+latest.Ages[3])
+}
+
+func (suite *APITestSuite) TestStatsWithOutputs() {
+	ts := httptest.NewServer(suite.server.Handler)
+	defer ts.Close()
+	c := suite.dialPlay(ts)
+	defer c.close()
+	c.reset(glider(10, 10))
+
+	u, err := url.Parse(ts.URL)
+	suite.Require().NoError(err)
+	u.Scheme = "ws"
+	u.Path = "/play"
+	u.RawQuery = "stats=1"
+	listener, _, err := websocket.DefaultDialer.Dial(u.String(), nil)
+	suite.Require().NoError(err)
+	defer listener.Close()
+
+	// all stats are collected once for each output
+	for range 8 {
+		c.send(&protocol.Command{Cmd: protocol.Next})
+		c.readOutput()
+	}
+
+	suite.Require().NoError(listener.SetReadDeadline(time.Now().Add(5 * time.Second)))
+	var output protocol.Output
+	var stats conway.Stats
+	for output.Generation != 8 || stats.Generation != 8 {
+		typ, msg, err := listener.ReadMessage()
+		suite.Require().NoError(err)
+		if typ == websocket.BinaryMessage {
+			suite.Require().NoError(output.Decode(msg))
+		} else {
+			suite.Require().NoError(json.Unmarshal(msg, &stats))
+		}
+	}
+	suite.Equal(uint(5), stats.Population)
+	suite.Equal([]conway.ColourCount{{Colour: 1, Count: 5}}, stats.Colours)
+}
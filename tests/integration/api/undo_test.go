@@ -0,0 +1,103 @@
+package api_test
+
+import (
+	"net/http/httptest"
+
+	"github.com/JackWithOneEye/conwaymore/internal/protocol"
+)
+
+func (suite *APITestSuite) TestUndoRedo() {
+	ts := httptest.NewServer(suite.server.Handler)
+	defer ts.Close()
+	a := suite.dialPlay(ts)
+	defer a.close()
+
+	world := []protocol.Cell{cell(1, 1, 1), cell(2, 1, 1)}
+	a.reset(world)
+
+	suite.Run("set cells", func() {
+		a.send(&protocol.SetCells{Count: 1, Cells: []protocol.Cell{cell(5, 5, 2)}})
+		suite.Equal([]protocol.Cell{cell(1, 1, 1), cell(2, 1, 1), cell(5, 5, 2)}, a.readCells())
+		a.send(&protocol.Command{Cmd: protocol.Undo})
+		suite.Equal(world, a.readCells())
+		a.send(&protocol.Command{Cmd: protocol.Redo})
+		suite.Equal([]protocol.Cell{cell(1, 1, 1), cell(2, 1, 1), cell(5, 5, 2)}, a.readCells())
+		a.send(&protocol.Command{Cmd: protocol.Undo})
+		suite.Equal(world, a.readCells())
+	})
+
+	suite.Run("erase", func() {
+		a.send(&protocol.KillCells{Count: 1, Coords: []protocol.Coord{{X: 2, Y: 1}}})
+		suite.Equal([]protocol.Cell{cell(1, 1, 1)}, a.readCells())
+		a.send(&protocol.Command{Cmd: protocol.Undo})
+		suite.Equal(world, a.readCells())
+	})
+
+	suite.Run("only the cells the edit touched", func() {
+		msgs := []protocol.ClientMessage{
+			&protocol.SetCells{Count: 2, Mode: protocol.PasteOverwrite, Cells: []protocol.Cell{cell(1, 1, 3), cell(3, 2, 3)}},
+			&protocol.SetCells{Count: 2, Mode: protocol.PasteAnd, Cells: []protocol.Cell{cell(1, 1, 3), cell(2, 2, 3)}},
+			&protocol.ClearRegion{X: 2, Y: 0, Width: 5, Height: 5},
+			&protocol.EditRegion{Op: protocol.RegionMove, X: 1, Y: 1, Width: 2, Height: 1, DX: -3, DY: -2},
+			&protocol.EditRegion{Op: protocol.RegionRotateCW, X: 1, Y: 0, Width: 2, Height: 2},
+			&protocol.EditRegion{Op: protocol.RegionCut, X: 0, Y: 0, Width: 2, Height: 2},
+			&protocol.EditRegion{Op: protocol.RegionRecolour, X: 0, Y: 0, Width: 4, Height: 4, Colour: 5},
+		}
+		for _, msg := range msgs {
+			a.send(msg)
+			suite.NotEqual(world, a.readCells())
+			a.send(&protocol.Command{Cmd: protocol.Undo})
+			suite.Equal(world, a.readCells())
+		}
+	})
+
+	suite.Run("clear", func() {
+		a.send(&protocol.Command{Cmd: protocol.Clear})
+		suite.Empty(a.readCells())
+		a.send(&protocol.Command{Cmd: protocol.Undo})
+		suite.Equal(world, a.readCells())
+	})
+
+	suite.Run("randomise", func() {
+		a.send(&protocol.Command{Cmd: protocol.Randomise})
+		suite.NotEqual(world, a.readCells())
+		a.send(&protocol.Command{Cmd: protocol.Undo})
+		suite.Equal(world, a.readCells())
+		// nothing was undone in between
+		a.send(&protocol.Command{Cmd: protocol.Redo})
+		suite.NotEqual(world, a.readCells())
+		a.send(&protocol.Command{Cmd: protocol.Undo})
+		suite.Equal(world, a.readCells())
+	})
+
+	suite.Run("only the own edits", func() {
+		b := suite.dialPlay(ts)
+		defer b.close()
+
+		a.send(&protocol.SetCells{Count: 1, Cells: []protocol.Cell{cell(7, 7, 2)}})
+		a.readCells()
+		b.readCells()
+		b.send(&protocol.SetCells{Count: 1, Cells: []protocol.Cell{cell(9, 9, 3)}})
+		a.readCells()
+		b.readCells()
+
+		a.send(&protocol.Command{Cmd: protocol.Undo})
+		b.readCells()
+		suite.Equal([]protocol.Cell{cell(1, 1, 1), cell(2, 1, 1), cell(9, 9, 3)}, a.readCells())
+
+		// the cells of the next edit of a were changed by b since
+		a.send(&protocol.SetCells{Count: 1, Cells: []protocol.Cell{cell(7, 7, 2)}})
+		a.readCells()
+		b.readCells()
+		b.send(&protocol.KillCells{Count: 1, Coords: []protocol.Coord{{X: 7, Y: 7}}})
+		a.readCells()
+		b.readCells()
+		a.send(&protocol.Command{Cmd: protocol.Undo})
+		suite.Equal([]protocol.Cell{cell(1, 1, 1), cell(2, 1, 1), cell(9, 9, 3)}, a.sync())
+		b.readCells()
+
+		// b has nothing to redo
+		b.send(&protocol.Command{Cmd: protocol.Redo})
+		suite.Equal([]protocol.Cell{cell(1, 1, 1), cell(2, 1, 1), cell(9, 9, 3)}, b.sync())
+	})
+}